@@ -17,7 +17,11 @@ package commands
 import (
 	"context"
 
+	"github.com/GoogleContainerTools/kpt/internal/cmdfndescribe"
 	"github.com/GoogleContainerTools/kpt/internal/cmdfndoc"
+	"github.com/GoogleContainerTools/kpt/internal/cmdfnlist"
+	"github.com/GoogleContainerTools/kpt/internal/cmdfnpin"
+	"github.com/GoogleContainerTools/kpt/internal/cmdfnpull"
 	"github.com/GoogleContainerTools/kpt/internal/cmdrender"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
 	"github.com/GoogleContainerTools/kpt/thirdparty/cmdconfig/commands/cmdeval"
@@ -48,6 +52,10 @@ func GetFnCommand(ctx context.Context, name string) *cobra.Command {
 		cmdeval.EvalCommand(ctx, name),
 		cmdrender.NewCommand(ctx, name),
 		cmdfndoc.NewCommand(ctx, name),
+		cmdfnlist.NewCommand(ctx, name),
+		cmdfndescribe.NewCommand(ctx, name),
+		cmdfnpin.NewCommand(ctx, name),
+		cmdfnpull.NewCommand(ctx, name),
 		cmdsource.NewCommand(ctx, name),
 		cmdsink.NewCommand(ctx, name),
 	)
@@ -3,11 +3,14 @@ module github.com/GoogleContainerTools/kpt
 go 1.16
 
 require (
+	github.com/Masterminds/semver/v3 v3.1.1
 	github.com/cpuguy83/go-md2man/v2 v2.0.0
 	github.com/go-errors/errors v1.4.0
+	github.com/google/go-containerregistry v0.5.1
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 	github.com/igorsobreira/titlecase v0.0.0-20140109233139-4156b5b858ac
 	github.com/philopon/go-toposort v0.0.0-20170620085441-9be86dbd762f
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/posener/complete/v2 v2.0.1-alpha.12
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/pflag v1.0.5
@@ -23,6 +26,6 @@ require (
 	k8s.io/kubectl v0.21.1
 	k8s.io/utils v0.0.0-20210707171843-4b05e18ac7d9
 	sigs.k8s.io/cli-utils v0.25.1-0.20210702190410-c1a7c2d0409d
-	sigs.k8s.io/kustomize/api v0.8.10 // indirect
+	sigs.k8s.io/kustomize/api v0.8.10
 	sigs.k8s.io/kustomize/kyaml v0.11.1-0.20210715213702-35d1c3f9b418
 )
@@ -0,0 +1,144 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const setterCommentPrefix = "kpt-set:"
+
+// setterRef matches references to setters in a setter comment,
+// e.g. ${image} in `# kpt-set: ${image}:${tag}`.
+var setterRef = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// applySetters implements gcr.io/kpt-fn/apply-setters. Fields are marked
+// as setters with a line comment such as `# kpt-set: ${name}`, and the
+// setter values are read from the data of the function config. A field is
+// only updated if values are provided for all the setters it references.
+func applySetters(rl *framework.ResourceList) error {
+	setters, err := configData(rl.FunctionConfig)
+	if err != nil {
+		return err
+	}
+	for _, item := range rl.Items {
+		if err := visitSetters(item.YNode(), setters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitSetters walks the node tree and applies setters to every field
+// with a setter comment.
+func visitSetters(node *yaml.Node, setters map[string]string) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			if err := visitSetters(n, setters); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			comment := value.LineComment
+			if comment == "" && value.Kind == yaml.SequenceNode {
+				// the comment for a sequence is attached to the key
+				comment = key.LineComment
+			}
+			if err := applySetter(value, comment, setters); err != nil {
+				return err
+			}
+			if err := visitSetters(value, setters); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			if n.Kind == yaml.ScalarNode {
+				if err := applySetter(n, n.LineComment, setters); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := visitSetters(n, setters); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applySetter sets the value of node based on the setter pattern in
+// comment.
+func applySetter(node *yaml.Node, comment string, setters map[string]string) error {
+	pattern, found := setterPattern(comment)
+	if !found {
+		return nil
+	}
+	refs := setterRef.FindAllStringSubmatch(pattern, -1)
+	for _, ref := range refs {
+		if _, found := setters[ref[1]]; !found {
+			// skip fields which reference setters without values
+			return nil
+		}
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		node.Value = setterRef.ReplaceAllStringFunc(pattern, func(s string) string {
+			return setters[setterRef.FindStringSubmatch(s)[1]]
+		})
+		if node.Tag != yaml.NodeTagString {
+			// let the encoder resolve the type of the new value
+			node.Tag = ""
+		}
+	case yaml.SequenceNode:
+		if len(refs) != 1 || refs[0][0] != pattern {
+			return fmt.Errorf("array setter %q must reference exactly one setter", pattern)
+		}
+		values, err := yaml.Parse(setters[refs[0][1]])
+		if err != nil {
+			return fmt.Errorf("value of array setter %q must be a list: %w", refs[0][1], err)
+		}
+		if values.YNode().Kind != yaml.SequenceNode {
+			return fmt.Errorf("value of array setter %q must be a list", refs[0][1])
+		}
+		var content []*yaml.Node
+		for _, v := range values.YNode().Content {
+			content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Value: v.Value})
+		}
+		node.Content = content
+		// always use block style for the updated list
+		node.Style = 0
+	}
+	return nil
+}
+
+// setterPattern returns the pattern in a setter comment,
+// e.g. `${image}:${tag}` for `# kpt-set: ${image}:${tag}`.
+func setterPattern(comment string) (string, bool) {
+	comment = strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	if !strings.HasPrefix(comment, setterCommentPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(comment, setterCommentPrefix)), true
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package builtins contains in-process implementations of commonly used
+// KRM functions. A builtin function is registered under the exact image
+// name of the container function it replaces and must produce the same
+// output as that container.
+package builtins
+
+import (
+	"fmt"
+	"io"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// RunFn runs a builtin function. It reads the ResourceList from r and
+// writes the resulting ResourceList to w, just like a container function
+// reads from stdin and writes to stdout.
+type RunFn func(r io.Reader, w io.Writer) error
+
+// registry maps fully qualified function images to the builtin
+// implementation. Only the exact versions whose output the builtin is
+// known to match are registered, so a function pinned to any other
+// version, or to a tag which may be moved, always runs in a container.
+var registry = map[string]framework.ResourceListProcessorFunc{
+	"gcr.io/kpt-fn/set-namespace:v0.1.3":   setNamespace,
+	"gcr.io/kpt-fn/set-labels:v0.1.4":      setLabels,
+	"gcr.io/kpt-fn/set-annotations:v0.1.3": setAnnotations,
+	"gcr.io/kpt-fn/apply-setters:v0.1.1":   applySetters,
+}
+
+// Lookup returns the builtin function registered for the given image. The
+// last return value is false if there is no builtin for the image.
+func Lookup(image string) (RunFn, bool) {
+	p, found := registry[image]
+	if !found {
+		return nil, false
+	}
+	return func(r io.Reader, w io.Writer) error {
+		return framework.Execute(p, &kio.ByteReadWriter{
+			Reader:                r,
+			Writer:                w,
+			KeepReaderAnnotations: true,
+		})
+	}, true
+}
+
+// configData returns the data field of a ConfigMap function config.
+func configData(fnConfig *yaml.RNode) (map[string]string, error) {
+	if fnConfig == nil {
+		return nil, fmt.Errorf("function config is required")
+	}
+	meta, err := fnConfig.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	if meta.Kind != "ConfigMap" {
+		return nil, fmt.Errorf("function config must be a ConfigMap, got %q", meta.Kind)
+	}
+	return fnConfig.GetDataMap(), nil
+}
+
+// fieldSpecs returns the default kustomize field specs stored under key
+// in the given field spec configuration.
+func fieldSpecs(config, key string) (types.FsSlice, error) {
+	var fsm map[string]types.FsSlice
+	if err := yaml.Unmarshal([]byte(config), &fsm); err != nil {
+		return nil, err
+	}
+	fss, found := fsm[key]
+	if !found {
+		return nil, fmt.Errorf("missing field specs %q", key)
+	}
+	return fss, nil
+}
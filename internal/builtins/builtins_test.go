@@ -0,0 +1,268 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	_, found := Lookup("gcr.io/kpt-fn/set-namespace:v0.1.3")
+	assert.True(t, found)
+
+	// only exact image names have a builtin
+	for _, image := range []string{
+		"gcr.io/kpt-fn/set-namespace",
+		"gcr.io/kpt-fn/set-namespace:v0.1",
+		"gcr.io/kpt-fn/set-namespace:v0.2",
+		"set-namespace:v0.1.3",
+		"example.com/kpt-fn/set-namespace:v0.1.3",
+	} {
+		_, found = Lookup(image)
+		assert.False(t, found, image)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	testCases := map[string]struct {
+		image    string
+		input    string
+		expected string
+		errMsg   string
+	}{
+		"set-namespace": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.1.3",
+			input: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    namespace: old
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: reader
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    namespace: new
+`,
+			expected: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx
+    namespace: new
+- apiVersion: rbac.authorization.k8s.io/v1
+  kind: ClusterRole
+  metadata:
+    name: reader
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    namespace: new
+`,
+		},
+		"set-namespace missing namespace": {
+			image: "gcr.io/kpt-fn/set-namespace:v0.1.3",
+			input: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items: []
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data: {}
+`,
+			errMsg: "`data.namespace` must be specified",
+		},
+		"set-labels": {
+			image: "gcr.io/kpt-fn/set-labels:v0.1.4",
+			input: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: nginx
+  spec:
+    selector:
+      app: nginx
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    color: blue
+`,
+			expected: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: nginx
+    labels:
+      color: blue
+  spec:
+    selector:
+      app: nginx
+      color: blue
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    color: blue
+`,
+		},
+		"set-annotations": {
+			image: "gcr.io/kpt-fn/set-annotations:v0.1.3",
+			input: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    owner: me
+`,
+			expected: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+    annotations:
+      owner: me
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    owner: me
+`,
+		},
+		"apply-setters": {
+			image: "gcr.io/kpt-fn/apply-setters:v0.1.1",
+			input: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: nginx # kpt-set: ${name}
+  spec:
+    replicas: 1 # kpt-set: ${replicas}
+    template:
+      spec:
+        containers:
+        - name: nginx
+          image: nginx:1.14 # kpt-set: nginx:${tag}
+          args: # kpt-set: ${args}
+          - a
+        - name: sidecar
+          image: busybox # kpt-set: ${unknown}
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    name: my-nginx
+    replicas: "3"
+    tag: "1.21"
+    args: "[b, c]"
+`,
+			expected: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: my-nginx # kpt-set: ${name}
+  spec:
+    replicas: 3 # kpt-set: ${replicas}
+    template:
+      spec:
+        containers:
+        - name: nginx
+          image: nginx:1.21 # kpt-set: nginx:${tag}
+          args: # kpt-set: ${args}
+          - b
+          - c
+        - name: sidecar
+          image: busybox # kpt-set: ${unknown}
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: function-input
+  data:
+    name: my-nginx
+    replicas: "3"
+    tag: "1.21"
+    args: "[b, c]"
+`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			run, found := Lookup(tc.image)
+			if !assert.True(t, found) {
+				t.FailNow()
+			}
+			out := &bytes.Buffer{}
+			err := run(strings.NewReader(tc.input), out)
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdrender"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+)
+
+// TestGolden renders the packages in testdata with the builtin functions and
+// compares the result with the output of the container images. The packages
+// are the e2e tests of kpt fn render (e2e/testdata/fn-render), unchanged, and
+// the expected files are those packages with the diff captured by running the
+// gcr.io/kpt-fn images applied, so they use the exact versions which are
+// registered as builtins. The apply-setters package is the
+// resource-has-pkgname-prefix package with apply-setters in its pipelines.
+func TestGolden(t *testing.T) {
+	dirs, err := ioutil.ReadDir("testdata")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, d := range dirs {
+		name := d.Name()
+		t.Run(name, func(t *testing.T) {
+			pkgDir := filepath.Join(t.TempDir(), name)
+			if !assert.NoError(t, copyutil.CopyDir(filepath.Join("testdata", name, "pkg"), pkgDir)) {
+				t.FailNow()
+			}
+
+			e := &cmdrender.Executor{PkgPath: pkgDir}
+			if !assert.NoError(t, e.Execute(fake.CtxWithDefaultPrinter())) {
+				t.FailNow()
+			}
+
+			expectedDir := filepath.Join("testdata", name, "expected")
+			err := filepath.Walk(expectedDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(expectedDir, path)
+				if err != nil {
+					return err
+				}
+				expected, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				actual, err := ioutil.ReadFile(filepath.Join(pkgDir, rel))
+				if err != nil {
+					return err
+				}
+				assert.Equal(t, string(expected), string(actual), rel)
+				return nil
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builtins
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/filters/annotations"
+	"sigs.k8s.io/kustomize/api/filters/labels"
+	"sigs.k8s.io/kustomize/api/filters/namespace"
+	"sigs.k8s.io/kustomize/api/konfig/builtinpluginconsts"
+	"sigs.k8s.io/kustomize/kyaml/fn/framework"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// setNamespace implements gcr.io/kpt-fn/set-namespace. It sets the
+// namespace of all namespace-scoped resources to data.namespace of the
+// function config.
+func setNamespace(rl *framework.ResourceList) error {
+	data, err := configData(rl.FunctionConfig)
+	if err != nil {
+		return err
+	}
+	ns, found := data["namespace"]
+	if !found || ns == "" {
+		return fmt.Errorf("`data.namespace` must be specified in the function config")
+	}
+	fss, err := fieldSpecs(builtinpluginconsts.GetDefaultFieldSpecsAsMap()["namespace"], "namespace")
+	if err != nil {
+		return err
+	}
+	return rl.Filter(namespace.Filter{Namespace: ns, FsSlice: fss})
+}
+
+// setLabels implements gcr.io/kpt-fn/set-labels. Every entry in the data
+// of the function config is set as a label on all resources, including
+// selectors and pod templates.
+func setLabels(rl *framework.ResourceList) error {
+	data, err := configData(rl.FunctionConfig)
+	if err != nil {
+		return err
+	}
+	fss, err := fieldSpecs(builtinpluginconsts.GetDefaultFieldSpecsAsMap()["commonlabels"], "commonLabels")
+	if err != nil {
+		return err
+	}
+	return rl.Filter(labels.Filter{Labels: data, FsSlice: fss})
+}
+
+// setAnnotations implements gcr.io/kpt-fn/set-annotations. Every entry in
+// the data of the function config is set as an annotation on all resources,
+// including pod templates.
+func setAnnotations(rl *framework.ResourceList) error {
+	data, err := configData(rl.FunctionConfig)
+	if err != nil {
+		return err
+	}
+	fss, err := fieldSpecs(builtinpluginconsts.GetDefaultFieldSpecsAsMap()["commonannotations"], "commonAnnotations")
+	if err != nil {
+		return err
+	}
+	if err := rl.Filter(annotations.Filter{Annotations: data, FsSlice: fss}); err != nil {
+		return err
+	}
+	// the container sets all the annotations of a resource again, so the
+	// existing values are written as plain strings
+	for _, item := range rl.Items {
+		a, err := item.Pipe(yaml.Lookup(yaml.MetadataField, yaml.AnnotationsField))
+		if err != nil {
+			return err
+		}
+		if a == nil || a.YNode().Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(a.YNode().Content); i += 2 {
+			v := a.YNode().Content[i]
+			if v.Kind == yaml.ScalarNode {
+				v.Style = 0
+				v.Tag = yaml.NodeTagString
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdfndescribe_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdfndescribe"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	catalog := filepath.Join(t.TempDir(), "catalog.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(catalog, []byte(`apiVersion: kpt.dev/v1
+kind: FunctionCatalog
+functions:
+- name: set-labels
+  image: gcr.io/kpt-fn/set-labels
+  versions:
+  - v0.1.5
+  description: Add or update labels of resources.
+- name: generator
+  image: registry.example.com/fns/generate-config
+`), 0600)) {
+		t.FailNow()
+	}
+	fnConfig := filepath.Join(t.TempDir(), "fn-config.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(fnConfig, []byte(`apiVersion: kpt.dev/v1
+kind: FunctionRuntimeConfig
+defaultImagePrefix: registry.example.com/fns/
+`), 0600)) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		arg      string
+		fnConfig string
+		expected string
+		errMsg   string
+	}{
+		"name": {
+			arg: "set-labels",
+			expected: `Name: set-labels
+Image: gcr.io/kpt-fn/set-labels
+Versions: v0.1.5
+Description:
+  Add or update labels of resources.
+`,
+		},
+		"image without default prefix": {
+			arg: "set-labels:v0.1",
+			expected: `Name: set-labels
+Image: gcr.io/kpt-fn/set-labels
+Versions: v0.1.5
+Description:
+  Add or update labels of resources.
+`,
+		},
+		"image with configured default prefix": {
+			arg:      "generate-config:v1",
+			fnConfig: fnConfig,
+			expected: `Name: generator
+Image: registry.example.com/fns/generate-config
+`,
+		},
+		"not found": {
+			arg:    "set-namespace",
+			errMsg: `function "set-namespace" not found in catalog`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			defer os.Setenv(fnruntime.RuntimeConfigEnv, os.Getenv(fnruntime.RuntimeConfigEnv))
+			os.Setenv(fnruntime.RuntimeConfigEnv, tc.fnConfig)
+
+			out := &bytes.Buffer{}
+			c := cmdfndescribe.NewCommand(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+			c.SetArgs([]string{tc.arg, "--catalog", catalog})
+			c.SilenceErrors = true
+			c.SilenceUsage = true
+			err := c.Execute()
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
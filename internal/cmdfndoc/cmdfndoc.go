@@ -23,6 +23,7 @@ import (
 	"os/exec"
 
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/fncatalog"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
@@ -43,6 +44,8 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 	}
 	r.Command = c
 	c.Flags().StringVarP(&r.Image, "image", "i", "", "kpt function image name")
+	c.Flags().StringVar(&r.Catalog, "catalog", "",
+		fmt.Sprintf("location of the function catalog. Defaults to the value of %s.", fncatalog.CatalogEnv))
 	cmdutil.FixDocs("kpt", parent, c)
 	return r
 }
@@ -53,6 +56,7 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 
 type Runner struct {
 	Image   string
+	Catalog string
 	Command *cobra.Command
 	Ctx     context.Context
 }
@@ -61,7 +65,30 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	if r.Image == "" {
 		return errors.New("image must be specified")
 	}
-	r.Image = fnruntime.AddDefaultImagePathPrefix(r.Image)
+	runtimeConfigs, err := fnruntime.LoadRuntimeConfigs(".")
+	if err != nil {
+		return err
+	}
+	r.Image = runtimeConfigs.AddDefaultImagePathPrefix(r.Image)
+	pr := printer.FromContextOrDie(r.Ctx)
+
+	// prefer the documentation of the function catalog, which doesn't
+	// require running the image
+	if location := fncatalog.Location(r.Catalog); location != "" {
+		catalog, err := fncatalog.Load(r.Ctx, location)
+		if err != nil {
+			return err
+		}
+		if fn, found := catalog.Lookup(r.Image); found {
+			return fn.Describe(pr.OutStream())
+		}
+	}
+
+	if err := runtimeConfigs.CheckImage(r.Image); err != nil {
+		return err
+	}
+	r.Image = runtimeConfigs.RewriteImage(r.Image)
+
 	var out, errout bytes.Buffer
 	dockerRunArgs := []string{
 		"run",
@@ -73,8 +100,7 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 	cmd := exec.Command("docker", dockerRunArgs...)
 	cmd.Stdout = &out
 	cmd.Stderr = &errout
-	err := cmd.Run()
-	pr := printer.FromContextOrDie(r.Ctx)
+	err = cmd.Run()
 	if err != nil {
 		pr.Printf(errout.String())
 		return fmt.Errorf("please ensure the container has an entrypoint and it supports --help flag: %w", err)
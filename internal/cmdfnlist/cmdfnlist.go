@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdfnlist contains the list command
+package cmdfnlist
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/fncatalog"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "list [flags]",
+		Args:    cobra.NoArgs,
+		Short:   docs.ListShort,
+		Long:    docs.ListShort + "\n" + docs.ListLong,
+		Example: docs.ListExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.catalog, "catalog", "",
+		fmt.Sprintf("location of the function catalog. Defaults to the value of %s.", fncatalog.CatalogEnv))
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function for the list command
+type Runner struct {
+	catalog string
+	Command *cobra.Command
+	ctx     context.Context
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	location := fncatalog.Location(r.catalog)
+	if location == "" {
+		return fmt.Errorf("no function catalog configured, use --catalog or set %s", fncatalog.CatalogEnv)
+	}
+	catalog, err := fncatalog.Load(r.ctx, location)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(printer.FromContextOrDie(r.ctx).OutStream(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLATEST\tDESCRIPTION")
+	for _, fn := range catalog.Functions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", fn.Name, fn.LatestVersion(), strings.SplitN(fn.Description, "\n", 2)[0])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdfnlist_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdfnlist"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	catalog := filepath.Join(t.TempDir(), "catalog.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(catalog, []byte(`apiVersion: kpt.dev/v1
+kind: FunctionCatalog
+functions:
+- name: set-labels
+  image: gcr.io/kpt-fn/set-labels
+  versions:
+  - v0.1.4
+  - v0.1.5
+  description: |
+    Add or update labels of resources.
+    Labels are also added to selectors.
+- name: generate-config
+  image: registry.example.com/fns/generate-config
+`), 0600)) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	c := cmdfnlist.NewCommand(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	c.SetArgs([]string{"--catalog", catalog})
+	if !assert.NoError(t, c.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, `NAME             LATEST  DESCRIPTION
+set-labels       v0.1.5  Add or update labels of resources.
+generate-config          
+`, out.String())
+
+	c = cmdfnlist.NewCommand(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	c.SetArgs([]string{"--catalog", filepath.Join(filepath.Dir(catalog), "missing.yaml")})
+	assert.Error(t, c.Execute())
+}
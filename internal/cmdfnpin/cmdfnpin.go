@@ -0,0 +1,272 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdfnpin contains the pin command
+package cmdfnpin
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx:           ctx,
+		resolveDigest: fnruntime.ResolveImageDigest,
+	}
+	c := &cobra.Command{
+		Use:     "pin [PKG_PATH]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.PinShort,
+		Long:    docs.PinShort + "\n" + docs.PinLong,
+		Example: docs.PinExamples,
+		RunE:    r.runE,
+		PreRunE: r.preRunE,
+	}
+	c.Flags().BoolVar(&r.noBuiltins, "no-builtins", false,
+		"also pin the functions which kpt runs with a builtin implementation.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function for the pin command
+type Runner struct {
+	pkgPath    string
+	noBuiltins bool
+	Command    *cobra.Command
+	ctx        context.Context
+
+	// resolveDigest returns the reference to the image pinned by digest.
+	resolveDigest func(ctx context.Context, image string, configs fnruntime.RuntimeConfigs) (string, error)
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		// no pkg path specified, default to current working dir
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.pkgPath = wd
+	} else {
+		r.pkgPath = args[0]
+	}
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	if err := cmdutil.DockerCmdAvailable(); err != nil {
+		return err
+	}
+	return r.pin()
+}
+
+// pin replaces the image of every function in the pipelines of the package
+// and all of its subpackages, and in the local pipeline fragments they
+// include, with a reference to the image by digest. Functions with a builtin
+// implementation are left unchanged, since they don't run the image. The refs
+// of the pipeline fragments in git repositories are pinned by recording the
+// commits they resolve to in the Kptfile. The functions of fragments in git
+// repositories can't be pinned, so an error
+// listing them is returned after the other functions are pinned.
+func (r *Runner) pin() error {
+	const op errors.Op = "fn.pin"
+	pr := printer.FromContextOrDie(r.ctx)
+
+	rootPkg, err := pkg.New(r.pkgPath)
+	if err != nil {
+		return errors.E(op, types.UniquePath(r.pkgPath), err)
+	}
+	pkgPaths := []string{rootPkg.UniquePath.String()}
+	subPkgPaths, err := pkg.Subpackages(rootPkg.UniquePath.String(), pkg.All, true)
+	if err != nil {
+		return errors.E(op, rootPkg.UniquePath, err)
+	}
+	for _, spp := range subPkgPaths {
+		// sub package paths are all relative to the root package
+		pkgPaths = append(pkgPaths, filepath.Join(rootPkg.UniquePath.String(), spp))
+	}
+
+	runtimeConfigs, err := fnruntime.LoadRuntimeConfigs(rootPkg.UniquePath.String())
+	if err != nil {
+		return errors.E(op, rootPkg.UniquePath, err)
+	}
+
+	// images are resolved once, even if they are used in several packages
+	digests := make(map[string]string)
+	pinFns := func(p *pkg.Pkg, pl *kptfilev1.Pipeline) (bool, error) {
+		var pinned bool
+		for _, fns := range [][]kptfilev1.Function{pl.Mutators, pl.Validators} {
+			for i := range fns {
+				fn := &fns[i]
+				if !r.needsPin(fn, runtimeConfigs) {
+					continue
+				}
+				image := runtimeConfigs.AddDefaultImagePathPrefix(fn.Image)
+				digest, found := digests[image]
+				if !found {
+					digest, err = r.resolveDigest(r.ctx, image, runtimeConfigs)
+					if err != nil {
+						return false, errors.E(op, p.UniquePath, errors.Fn(image), err)
+					}
+					digests[image] = digest
+				}
+				pr.OptPrintf(printer.NewOpt().Pkg(p.UniquePath), "pinned %q to %q\n", fn.Image, digest)
+				fn.Image = digest
+				pinned = true
+			}
+		}
+		return pinned, nil
+	}
+
+	var unpinned []string
+	for _, pkgPath := range pkgPaths {
+		p, err := pkg.New(pkgPath)
+		if err != nil {
+			return errors.E(op, types.UniquePath(pkgPath), err)
+		}
+		kf, err := p.Kptfile()
+		if err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		if kf.Pipeline == nil {
+			continue
+		}
+		if err := p.RecordIncludeCommits(r.ctx); err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		pinned, err := pinFns(p, kf.Pipeline)
+		if err != nil {
+			return err
+		}
+		if pinned {
+			if err := setImages(filepath.Join(p.UniquePath.String(), kptfilev1.KptFileName), kf.Pipeline); err != nil {
+				return errors.E(op, p.UniquePath, fmt.Errorf("failed to update Kptfile: %w", err))
+			}
+		}
+
+		// the fragments are read after the Kptfile is written, so local
+		// fragments included by several packages are only pinned once
+		fragments, err := p.Fragments(r.ctx)
+		if err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		for i := range fragments {
+			f := &fragments[i]
+			if f.Path == "" {
+				for _, fns := range [][]kptfilev1.Function{f.Pipeline.Mutators, f.Pipeline.Validators} {
+					for i := range fns {
+						if r.needsPin(&fns[i], runtimeConfigs) {
+							unpinned = append(unpinned, fmt.Sprintf("%q in %s", fns[i].Image, f.Location))
+						}
+					}
+				}
+				continue
+			}
+			pinned, err := pinFns(p, &f.Pipeline)
+			if err != nil {
+				return err
+			}
+			if !pinned {
+				continue
+			}
+			if err := setImages(f.Path, &f.Pipeline); err != nil {
+				return errors.E(op, p.UniquePath, fmt.Errorf("failed to update pipeline fragment %s: %w", f.Location, err))
+			}
+		}
+	}
+	if len(unpinned) > 0 {
+		return errors.E(op, rootPkg.UniquePath, fmt.Errorf(
+			"functions of pipeline fragments in git repositories can't be pinned, pin them in the repositories of the fragments: %s",
+			strings.Join(unpinned, ", ")))
+	}
+	return nil
+}
+
+// needsPin returns true if the function runs an image which isn't pinned to
+// a digest.
+func (r *Runner) needsPin(fn *kptfilev1.Function, runtimeConfigs fnruntime.RuntimeConfigs) bool {
+	if fn.Starlark != nil || fn.Image == "" || fnruntime.HasImageDigest(fn.Image) {
+		return false
+	}
+	// keep in sync with the choice of the runner in render
+	builtin := len(fn.Env) == 0 && fnruntime.IsBuiltin(runtimeConfigs.AddDefaultImagePathPrefix(fn.Image))
+	return r.noBuiltins || !builtin
+}
+
+// setImages updates the images of the functions in the Kptfile or pipeline
+// fragment file in path to the images of the functions of pl. The file is
+// updated in place to preserve comments and formatting.
+func setImages(path string, pl *kptfilev1.Pipeline) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	node, err := yaml.Parse(string(b))
+	if err != nil {
+		return err
+	}
+	for field, fns := range map[string][]kptfilev1.Function{
+		"mutators":   pl.Mutators,
+		"validators": pl.Validators,
+	} {
+		list, err := node.Pipe(yaml.Lookup("pipeline", field))
+		if err != nil {
+			return err
+		}
+		if list == nil {
+			continue
+		}
+		elements, err := list.Elements()
+		if err != nil {
+			return err
+		}
+		for i, e := range elements {
+			if i >= len(fns) || fns[i].Image == "" {
+				continue
+			}
+			if err := e.PipeE(yaml.SetField("image", yaml.NewScalarRNode(fns[i].Image))); err != nil {
+				return err
+			}
+		}
+	}
+	out, err := yaml.MarshalWithOptions(node.Document(), &yaml.EncoderOptions{
+		SeqIndent: yaml.SequenceIndentStyle(yaml.DeriveSeqIndentStyle(string(b))),
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, out, 0600)
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdfnpin
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+const digest = "@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestPin(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    # label the resources of the app
+    - image: set-labels:v0.1
+      configMap:
+        app: foo
+    - starlark:
+        source: print(ctx.resource_list)
+`)
+	writeKptfile(t, filepath.Join(dir, "sub"), `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+    - image: gcr.io/kpt-fn/set-labels:v0.1
+  validators:
+    - image: gcr.io/kpt-fn/kubeval`+digest+`
+`)
+
+	var resolved []string
+	r := NewRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+	r.pkgPath = dir
+	r.noBuiltins = true
+	r.resolveDigest = func(_ context.Context, image string, _ fnruntime.RuntimeConfigs) (string, error) {
+		resolved = append(resolved, image)
+		return image + digest, nil
+	}
+	if !assert.NoError(t, r.pin()) {
+		t.FailNow()
+	}
+	// images are only resolved once
+	assert.Equal(t, []string{"gcr.io/kpt-fn/set-labels:v0.1"}, resolved)
+
+	kf, err := pkg.ReadKptfile(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1"+digest, kf.Pipeline.Mutators[0].Image)
+	assert.Equal(t, map[string]string{"app": "foo"}, kf.Pipeline.Mutators[0].ConfigMap)
+	assert.Equal(t, "", kf.Pipeline.Mutators[1].Image)
+	// the Kptfile is updated in place
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "    # label the resources of the app\n    - image: gcr.io/kpt-fn/set-labels:v0.1"+digest+"\n")
+
+	kf, err = pkg.ReadKptfile(filepath.Join(dir, "sub"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1"+digest, kf.Pipeline.Mutators[0].Image)
+	assert.Equal(t, "gcr.io/kpt-fn/kubeval"+digest, kf.Pipeline.Validators[0].Image)
+}
+
+func TestPin_builtinsAndFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  include:
+    - path: ../fragments/validators.yaml
+  mutators:
+    - image: gcr.io/kpt-fn/set-labels:v0.1.4
+`)
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, "..", "fragments"), 0700)) {
+		t.FailNow()
+	}
+	fragmentPath := filepath.Join(dir, "..", "fragments", "validators.yaml")
+	err := ioutil.WriteFile(fragmentPath, []byte(`apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: validators
+pipeline:
+  validators:
+  # validate the schemas
+  - image: gcr.io/kpt-fn/kubeval:v0.1
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var resolved []string
+	r := NewRunner(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}), "kpt")
+	r.pkgPath = dir
+	r.resolveDigest = func(_ context.Context, image string, _ fnruntime.RuntimeConfigs) (string, error) {
+		resolved = append(resolved, image)
+		return image + digest, nil
+	}
+	if !assert.NoError(t, r.pin()) {
+		t.FailNow()
+	}
+	// the builtin isn't pinned
+	assert.Equal(t, []string{"gcr.io/kpt-fn/kubeval:v0.1"}, resolved)
+	kf, err := pkg.ReadKptfile(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "gcr.io/kpt-fn/set-labels:v0.1.4", kf.Pipeline.Mutators[0].Image)
+
+	// the local fragment is pinned in place
+	b, err := ioutil.ReadFile(fragmentPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: validators
+pipeline:
+  validators:
+  # validate the schemas
+  - image: gcr.io/kpt-fn/kubeval:v0.1`+digest+`
+`, string(b))
+}
+
+func TestPin_gitFragments(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  include:
+    - git:
+        repo: https://github.com/example/policies
+        path: validators.yaml
+  mutators:
+    - image: gcr.io/kpt-fn/set-namespace:v0.2
+`)
+	ctx := pkg.WithGitFileReader(fake.CtxWithPrinter(&bytes.Buffer{}, &bytes.Buffer{}),
+		func(_ context.Context, _, _, _ string) ([]byte, string, error) {
+			return []byte(`apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: validators
+pipeline:
+  validators:
+    - image: gcr.io/kpt-fn/kubeval:v0.1
+`), "abc123", nil
+		})
+
+	r := NewRunner(ctx, "kpt")
+	r.pkgPath = dir
+	r.resolveDigest = func(_ context.Context, image string, _ fnruntime.RuntimeConfigs) (string, error) {
+		return "gcr.io/kpt-fn/set-namespace" + digest, nil
+	}
+	err := r.pin()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `functions of pipeline fragments in git repositories can't be pinned`)
+		assert.Contains(t, err.Error(), `"gcr.io/kpt-fn/kubeval:v0.1" in https://github.com/example/policies/validators.yaml@abc123`)
+	}
+
+	// the other functions are pinned
+	kf, err := pkg.ReadKptfile(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "gcr.io/kpt-fn/set-namespace"+digest, kf.Pipeline.Mutators[0].Image)
+	// the commits of the fragments are recorded
+	assert.Equal(t, "abc123", kf.Pipeline.Include[0].Git.Commit)
+}
+
+func writeKptfile(t *testing.T, dir, content string) {
+	if !assert.NoError(t, os.MkdirAll(dir, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(content), 0600)) {
+		t.FailNow()
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdfnpull contains the pull command
+package cmdfnpull
+
+import (
+	"context"
+	"os"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdrender"
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{ctx: ctx}
+	c := &cobra.Command{
+		Use:     "pull [PKG_PATH] [flags]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.PullShort,
+		Long:    docs.PullShort + "\n" + docs.PullLong,
+		Example: docs.PullExamples,
+		RunE:    r.runE,
+		PreRunE: r.preRunE,
+	}
+	c.Flags().StringVar(&r.imagePullPolicy, "image-pull-policy", "always",
+		"pull image before running the container. It should be one of always, ifNotPresent and never.")
+	c.Flags().BoolVar(&r.noBuiltins, "no-builtins", false,
+		"pull the images of all functions, even if kpt has a builtin implementation of the function.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function for the pull command
+type Runner struct {
+	pkgPath         string
+	imagePullPolicy string
+	noBuiltins      bool
+	Command         *cobra.Command
+	ctx             context.Context
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		// no pkg path specified, default to current working dir
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.pkgPath = wd
+	} else {
+		r.pkgPath = args[0]
+	}
+	return cmdutil.ValidateImagePullPolicyValue(r.imagePullPolicy)
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	if err := cmdutil.DockerCmdAvailable(); err != nil {
+		return err
+	}
+	executor := cmdrender.Executor{
+		PkgPath:         r.pkgPath,
+		ImagePullPolicy: cmdutil.StringToImagePullPolicy(r.imagePullPolicy),
+		NoBuiltins:      r.noBuiltins,
+	}
+	return executor.PullImages(r.ctx)
+}
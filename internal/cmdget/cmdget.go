@@ -36,7 +36,7 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		ctx: ctx,
 	}
 	c := &cobra.Command{
-		Use:        "get REPO_URI[.git]/PKG_PATH[@VERSION] [LOCAL_DEST_DIRECTORY]",
+		Use:        "get {REPO_URI[.git]/PKG_PATH[@VERSION] | oci://IMAGE[:TAG] | LOCAL_PATH} [LOCAL_DEST_DIRECTORY]",
 		Args:       cobra.MinimumNArgs(1),
 		Short:      docs.GetShort,
 		Long:       docs.GetShort + "\n" + docs.GetLong,
@@ -70,15 +70,34 @@ func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
 	if len(args) == 1 {
 		args = append(args, pkg.CurDir)
 	}
-	t, err := parse.GitParseArgs(r.ctx, args)
-	if err != nil {
-		return errors.E(op, err)
+	var destination string
+	switch {
+	case strings.HasPrefix(args[0], parse.OciPrefix):
+		t, err := parse.OciParseArgs(args)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.Get.Oci = &t.Oci
+		destination = t.Destination
+	case parse.IsLocalPath(args[0]):
+		t, err := parse.LocalParseArgs(args)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.Get.Local = &t.Local
+		destination = t.Destination
+	default:
+		t, err := parse.GitParseArgs(r.ctx, args)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		r.Get.Git = &t.Git
+		destination = t.Destination
 	}
 
-	r.Get.Git = &t.Git
-	p, err := pkg.New(t.Destination)
+	p, err := pkg.New(destination)
 	if err != nil {
-		return errors.E(op, types.UniquePath(t.Destination), err)
+		return errors.E(op, types.UniquePath(destination), err)
 	}
 	r.Get.Destination = string(p.UniquePath)
 
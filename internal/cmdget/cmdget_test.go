@@ -338,7 +338,7 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 		},
 		"invalid repo": {
 			argsFunc: func(repo, dir string) []string {
-				return []string{"/", filepath.Join(dir, "package", "my-app")}
+				return []string{"foo/bar", filepath.Join(dir, "package", "my-app")}
 			},
 			runE: failRun,
 			validations: func(repo, dir string, r *cmdget.Runner, err error) {
@@ -346,6 +346,16 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 				assert.Contains(t, err.Error(), "specify '.git'")
 			},
 		},
+		"local directory without a Kptfile": {
+			argsFunc: func(repo, dir string) []string {
+				return []string{"/", filepath.Join(dir, "package", "my-app")}
+			},
+			runE: failRun,
+			validations: func(repo, dir string, r *cmdget.Runner, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), `"/" is not a kpt package: it has no Kptfile`)
+			},
+		},
 		"valid strategy provided": {
 			argsFunc: func(repo, dir string) []string {
 				return []string{fmt.Sprintf("file://%s.git", repo), filepath.Join(dir, "package"), "--strategy=fast-forward"}
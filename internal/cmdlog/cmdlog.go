@@ -0,0 +1,167 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdlog contains the log command
+package cmdlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/changelog"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "log [PKG_PATH@VERSION]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.LogShort,
+		Long:    docs.LogShort + "\n" + docs.LogLong,
+		Example: docs.LogExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	c.Flags().BoolVar(&r.subpackages, "subpackages", false,
+		"also show the upstream changes of the remote subpackages of the package")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx         context.Context
+	Command     *cobra.Command
+	subpackages bool
+	pkg         *pkg.Pkg
+	ref         string
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdlog.preRunE"
+	if len(args) == 0 {
+		args = append(args, pkg.CurDir)
+	}
+	parts := strings.Split(args[0], "@")
+	if len(parts) > 2 {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("at most 1 version permitted"))
+	}
+	p, err := pkg.New(parts[0])
+	if err != nil {
+		return errors.E(op, types.UniquePath(parts[0]), err)
+	}
+	r.pkg = p
+	if len(parts) > 1 {
+		r.ref = parts[1]
+	}
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	const op errors.Op = "cmdlog.runE"
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+
+	// the version only applies to the package itself, the subpackages are
+	// compared against the refs in their own Kptfiles
+	kf, err := r.pkg.Kptfile()
+	if err != nil {
+		return errors.E(op, r.pkg.UniquePath, err)
+	}
+	l, err := changelog.Get(r.ctx, kf, r.ref)
+	if err != nil {
+		return errors.E(op, r.pkg.UniquePath, err)
+	}
+	printLog(out, string(r.pkg.DisplayPath), l)
+
+	if !r.subpackages {
+		return nil
+	}
+	paths, err := pkg.Subpackages(r.pkg.UniquePath.String(), pkg.Remote, true)
+	if err != nil {
+		return errors.E(op, r.pkg.UniquePath, err)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		pkgPath := filepath.Join(r.pkg.UniquePath.String(), p)
+		displayPath := filepath.Join(string(r.pkg.DisplayPath), p)
+		kf, err := pkg.ReadKptfile(pkgPath)
+		if err != nil {
+			return errors.E(op, types.UniquePath(pkgPath), err)
+		}
+		switch {
+		case kf.Upstream == nil || kf.Upstream.Git == nil:
+			fmt.Fprintf(out, "\nPackage %q:\nUpstream is not a git repository.\n", displayPath)
+			continue
+		case kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil:
+			fmt.Fprintf(out, "\nPackage %q:\nPackage has not been fetched.\n", displayPath)
+			continue
+		}
+		l, err := changelog.Get(r.ctx, kf, "")
+		if err != nil {
+			return errors.E(op, types.UniquePath(pkgPath), err)
+		}
+		fmt.Fprintln(out)
+		printLog(out, displayPath, l)
+	}
+	return nil
+}
+
+// printLog prints the upstream changes of the package at displayPath.
+func printLog(out io.Writer, displayPath string, l *changelog.Log) {
+	fmt.Fprintf(out, "Package %q: %s..%s (%s)\n", displayPath, short(l.From), short(l.To), l.Ref)
+	if len(l.Commits) == 0 {
+		fmt.Fprintln(out, "No upstream changes.")
+		return
+	}
+	for _, c := range l.Commits {
+		fmt.Fprintf(out, "\ncommit %s\n", c.Commit)
+		fmt.Fprintf(out, "Author: %s\n", c.Author)
+		fmt.Fprintf(out, "Date:   %s\n", c.Date)
+		fmt.Fprintf(out, "\n    %s\n\n", c.Subject)
+		for _, f := range c.Files {
+			if f.Binary {
+				fmt.Fprintf(out, "    %8s  %s\n", "binary", f.Path)
+				continue
+			}
+			fmt.Fprintf(out, "    %8s  %s\n", fmt.Sprintf("+%d -%d", f.Added, f.Deleted), f.Path)
+		}
+	}
+}
+
+// short abbreviates commits for display.
+func short(commit string) string {
+	if len(commit) == 40 {
+		return commit[:7]
+	}
+	return commit
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdlog_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdlog"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+func TestCmd(t *testing.T) {
+	testCases := map[string]struct {
+		version          string
+		args             []string
+		expectedOutput   []string
+		unexpectedOutput []string
+		expectedErrMsg   string
+	}{
+		"changes up to the ref in the Kptfile": {
+			expectedOutput: []string{"(master)", "commit ", "Author: ", "+"},
+		},
+		"changes up to a version": {
+			version:          "@v1.0.0",
+			expectedOutput:   []string{"(v1.0.0)", "No upstream changes."},
+			unexpectedOutput: []string{"commit "},
+		},
+		"subpackages": {
+			args:           []string{"--subpackages"},
+			expectedOutput: []string{"(master)", "commit "},
+		},
+		"too many versions": {
+			version:        "@v1.0.0@master",
+			expectedErrMsg: "at most 1 version permitted",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:      t,
+				GetRef: "v1.0.0",
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset1,
+							Branch: "master",
+							Tag:    "v1.0.0",
+						},
+						{
+							Data: testutil.Dataset2,
+						},
+					},
+				},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+			// track the master branch, so that there are changes upstream
+			// of the fetched version
+			if !assert.NoError(t, setRef(g.LocalWorkspace.FullPackagePath(), "master")) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			r := cmdlog.NewRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SetArgs(append([]string{g.LocalWorkspace.FullPackagePath() + tc.version}, tc.args...))
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			err := r.Command.Execute()
+
+			if tc.expectedErrMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, s := range tc.expectedOutput {
+				assert.Contains(t, out.String(), s)
+			}
+			for _, s := range tc.unexpectedOutput {
+				assert.NotContains(t, out.String(), s)
+			}
+		})
+	}
+}
+
+func setRef(path, ref string) error {
+	kf, err := pkg.ReadKptfile(path)
+	if err != nil {
+		return err
+	}
+	kf.Upstream.Git.Ref = ref
+	return kptfileutil.WriteFile(path, kf)
+}
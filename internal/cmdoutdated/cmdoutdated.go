@@ -0,0 +1,145 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdoutdated contains the outdated command
+package cmdoutdated
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "outdated [PKG_PATH]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   docs.OutdatedShort,
+		Long:    docs.OutdatedShort + "\n" + docs.OutdatedLong,
+		Example: docs.OutdatedExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.output, "output", outputTable,
+		"output format -- must be one of: table,json")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+	output  string
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, _ []string) error {
+	if r.output != outputTable && r.output != outputJSON {
+		return fmt.Errorf("unknown output format %q, must be one of: table,json", r.output)
+	}
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdoutdated.runE"
+	if len(args) == 0 {
+		args = append(args, pkg.CurDir)
+	}
+	p, err := pkg.New(args[0])
+	if err != nil {
+		return errors.E(op, types.UniquePath(args[0]), err)
+	}
+
+	results, err := outdated.Check(r.ctx, p)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+
+	out := printer.FromContextOrDie(r.ctx).OutStream()
+	if r.output == outputJSON {
+		if results == nil {
+			results = []outdated.Result{}
+		}
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return errors.E(op, err)
+		}
+		fmt.Fprintln(out, string(b))
+	} else {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PACKAGE\tUPSTREAM\tREF\tCURRENT\tLATEST\tNEWER VERSIONS")
+		for _, res := range results {
+			current := short(res.Current)
+			if current == "" {
+				current = "<not fetched>"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", res.Package, res.Upstream, res.Ref,
+				current, short(res.Latest), strings.Join(res.NewerVersions, ","))
+		}
+		if err := w.Flush(); err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	// outdated packages result in a non-zero exit code, so that the command
+	// can be used to check packages in CI
+	count := 0
+	for _, res := range results {
+		if res.Outdated {
+			count++
+		}
+	}
+	if count > 0 {
+		return errors.E(op, p.UniquePath, fmt.Errorf("%d package(s) are outdated", count))
+	}
+	return nil
+}
+
+// short abbreviates commits, digests and hashes for display.
+func short(v string) string {
+	if i := strings.Index(v, ":"); i >= 0 && len(v) > i+13 {
+		// sha256:0123456789ab...
+		return v[:i+13]
+	}
+	if len(v) == 40 {
+		// git commit
+		return v[:7]
+	}
+	return v
+}
@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdoutdated_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdoutdated"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+func TestCmd(t *testing.T) {
+	testCases := map[string]struct {
+		args             []string
+		expectedOutput   []string
+		expectedJSON     bool
+		expectedOutdated bool
+		expectedErrMsg   string
+	}{
+		"table": {
+			expectedOutput:   []string{"PACKAGE", "UPSTREAM", "NEWER VERSIONS", "master"},
+			expectedOutdated: true,
+		},
+		"json": {
+			args:             []string{"--output", "json"},
+			expectedJSON:     true,
+			expectedOutdated: true,
+		},
+		"invalid output": {
+			args:           []string{"--output", "yaml"},
+			expectedErrMsg: "unknown output format",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset1,
+							Branch: "master",
+						},
+						{
+							Data: testutil.Dataset2,
+						},
+					},
+				},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+
+			out := &bytes.Buffer{}
+			r := cmdoutdated.NewRunner(fake.CtxWithPrinter(out, out), "kpt")
+			r.Command.SetArgs(append([]string{g.LocalWorkspace.FullPackagePath()}, tc.args...))
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			err := r.Command.Execute()
+
+			switch {
+			case tc.expectedErrMsg != "":
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErrMsg)
+				}
+				return
+			case tc.expectedOutdated:
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), "1 package(s) are outdated")
+				}
+			default:
+				assert.NoError(t, err)
+			}
+
+			for _, s := range tc.expectedOutput {
+				assert.Contains(t, out.String(), s)
+			}
+			if tc.expectedJSON {
+				var results []outdated.Result
+				if assert.NoError(t, json.Unmarshal(out.Bytes(), &results)) && assert.Len(t, results, 1) {
+					assert.Equal(t, "master", results[0].Ref)
+					assert.True(t, results[0].Outdated)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdpush contains the push command
+package cmdpush
+
+import (
+	"context"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner
+func NewRunner(ctx context.Context, parent string) *Runner {
+	r := &Runner{
+		ctx: ctx,
+	}
+	c := &cobra.Command{
+		Use:     "push IMAGE[:TAG] [PKG_PATH]",
+		Args:    cobra.RangeArgs(1, 2),
+		Short:   docs.PushShort,
+		Long:    docs.PushShort + "\n" + docs.PushLong,
+		Example: docs.PushExamples,
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(ctx context.Context, parent string) *cobra.Command {
+	return NewRunner(ctx, parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	ctx     context.Context
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(_ *cobra.Command, args []string) error {
+	const op errors.Op = "cmdpush.runE"
+	if len(args) == 1 {
+		args = append(args, pkg.CurDir)
+	}
+	image := args[0]
+	p, err := pkg.New(args[1])
+	if err != nil {
+		return errors.E(op, types.UniquePath(args[1]), err)
+	}
+	if _, err := p.Kptfile(); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+
+	digest, err := oci.Push(r.ctx, p.UniquePath.String(), image)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	repo, err := oci.Repository(image)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	printer.FromContextOrDie(r.ctx).Printf("Pushed %s as %s@%s\n", p.DisplayPath, repo, digest)
+	return nil
+}
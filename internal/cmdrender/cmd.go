@@ -25,6 +25,7 @@ import (
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/spf13/cobra"
 )
 
@@ -45,6 +46,15 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		fmt.Sprintf("output resources are written to provided location. Allowed values: %s|%s|<OUT_DIR_PATH>", cmdutil.Stdout, cmdutil.Unwrap))
 	c.Flags().StringVar(&r.imagePullPolicy, "image-pull-policy", "always",
 		"pull image before running the container. It should be one of always, ifNotPresent and never.")
+	c.Flags().BoolVar(&r.noBuiltins, "no-builtins", false,
+		"run all functions in containers, even if kpt has a builtin implementation of the function.")
+	c.Flags().BoolVar(&r.requireDigests, "require-digests", false,
+		"fail if the image of any function is not pinned to a digest.")
+	c.Flags().BoolVar(&r.allowEnv, "allow-env", false,
+		"allow functions to pass through environment variables of the kpt process.")
+	c.Flags().IntVar(&r.validatorConcurrency, "validator-concurrency", DefaultValidatorConcurrency,
+		"maximum number of validators of a package to run concurrently.")
+	cmdutil.AddFunctionLimitsFlags(c, &r.limits)
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -56,12 +66,17 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 
 // Runner contains the run function pipeline run command
 type Runner struct {
-	pkgPath         string
-	resultsDirPath  string
-	imagePullPolicy string
-	dest            string
-	Command         *cobra.Command
-	ctx             context.Context
+	pkgPath              string
+	resultsDirPath       string
+	imagePullPolicy      string
+	dest                 string
+	noBuiltins           bool
+	requireDigests       bool
+	limits               kptfilev1.FunctionLimits
+	allowEnv             bool
+	validatorConcurrency int
+	Command              *cobra.Command
+	ctx                  context.Context
 }
 
 func (r *Runner) preRunE(c *cobra.Command, args []string) error {
@@ -87,6 +102,12 @@ func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 			return fmt.Errorf("cannot read or create results dir %q: %w", r.resultsDirPath, err)
 		}
 	}
+	if err := kptfilev1.ValidateFunctionLimits(r.limits); err != nil {
+		return err
+	}
+	if r.validatorConcurrency < 1 {
+		return fmt.Errorf("--validator-concurrency must be at least 1")
+	}
 	return cmdutil.ValidateImagePullPolicyValue(r.imagePullPolicy)
 }
 
@@ -107,6 +128,12 @@ func (r *Runner) runE(c *cobra.Command, _ []string) error {
 		ResultsDirPath:  r.resultsDirPath,
 		Output:          output,
 		ImagePullPolicy: cmdutil.StringToImagePullPolicy(r.imagePullPolicy),
+		NoBuiltins:      r.noBuiltins,
+		RequireDigests:  r.requireDigests,
+		Limits:          r.limits,
+		AllowEnv:        r.allowEnv,
+
+		ValidatorConcurrency: r.validatorConcurrency,
 	}
 	err = executor.Execute(r.ctx)
 	if err != nil {
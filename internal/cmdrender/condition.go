@@ -0,0 +1,122 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdrender
+
+import (
+	"context"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// conditionalFilter runs the wrapped function only if its `when` condition
+// matches the input. Otherwise the function is recorded as skipped, and the
+// input is returned unchanged.
+type conditionalFilter struct {
+	ctx        context.Context
+	filter     kio.Filter
+	when       *kptfilev1.Condition
+	pkgContext *yaml.RNode
+	// fnResult identifies the function in the results.
+	fnResult  fnresult.Result
+	fnResults *fnresult.ResultList
+}
+
+// newConditionalFilter wraps the runner of fn in a conditionalFilter if fn
+// has a `when` condition.
+func newConditionalFilter(ctx context.Context, hctx *hydrationContext, fn *kptfilev1.Function,
+	runner kio.Filter, pkgContext *yaml.RNode) kio.Filter {
+	if fn.When == nil {
+		return runner
+	}
+	fnResult := fnresult.Result{}
+	switch {
+	case fn.Starlark == nil:
+		fnResult.Image = hctx.runtimeConfigs.AddDefaultImagePathPrefix(fn.Image)
+	case fn.Starlark.Path != "":
+		fnResult.Starlark = fn.Starlark.Path
+	default:
+		fnResult.Starlark = "inline"
+	}
+	return &conditionalFilter{
+		ctx:        ctx,
+		filter:     runner,
+		when:       fn.When,
+		pkgContext: pkgContext,
+		fnResult:   fnResult,
+		fnResults:  hctx.fnResults,
+	}
+}
+
+func (f *conditionalFilter) Filter(input []*yaml.RNode) ([]*yaml.RNode, error) {
+	if conditionMatches(f.when, input, f.pkgContext.GetDataMap()) {
+		return f.filter.Filter(input)
+	}
+	name := f.fnResult.Image
+	if name == "" {
+		name = f.fnResult.Starlark
+	}
+	printer.FromContextOrDie(f.ctx).Printf("[SKIPPED] %q\n", name)
+	r := f.fnResult
+	r.Skipped = true
+	f.fnResults.Items = append(f.fnResults.Items, r)
+	return input, nil
+}
+
+// conditionMatches returns true if all parts of the condition match the
+// resources and the package context.
+func conditionMatches(c *kptfilev1.Condition, resources []*yaml.RNode, pkgContext map[string]string) bool {
+	for k, v := range c.PackageContext {
+		if pkgContext[k] != v {
+			return false
+		}
+	}
+	if c.ResourceExists != nil {
+		for _, r := range resources {
+			if selectorMatches(c.ResourceExists, r) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// selectorMatches returns true if the resource matches all specified fields
+// of the selector.
+func selectorMatches(s *kptfilev1.ResourceSelector, r *yaml.RNode) bool {
+	if s.APIVersion != "" && s.APIVersion != r.GetApiVersion() {
+		return false
+	}
+	if s.Kind != "" && s.Kind != r.GetKind() {
+		return false
+	}
+	if s.Name != "" && s.Name != r.GetName() {
+		return false
+	}
+	if s.Namespace != "" && s.Namespace != r.GetNamespace() {
+		return false
+	}
+	labels := r.GetLabels()
+	for k, v := range s.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdrender
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"gotest.tools/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestConditionMatches(t *testing.T) {
+	resources := []*yaml.RNode{yaml.MustParse(`apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+`)}
+	pkgContext := map[string]string{"name": "web", "path": "."}
+
+	testCases := map[string]struct {
+		when     kptfilev1.Condition
+		expected bool
+	}{
+		"kind exists": {
+			when:     kptfilev1.Condition{ResourceExists: &kptfilev1.ResourceSelector{Kind: "Ingress"}},
+			expected: true,
+		},
+		"kind doesn't exist": {
+			when:     kptfilev1.Condition{ResourceExists: &kptfilev1.ResourceSelector{Kind: "Service"}},
+			expected: false,
+		},
+		"all selector fields match": {
+			when: kptfilev1.Condition{ResourceExists: &kptfilev1.ResourceSelector{
+				APIVersion: "networking.k8s.io/v1",
+				Kind:       "Ingress",
+				Name:       "web",
+				Namespace:  "prod",
+				Labels:     map[string]string{"app": "web"},
+			}},
+			expected: true,
+		},
+		"label doesn't match": {
+			when: kptfilev1.Condition{ResourceExists: &kptfilev1.ResourceSelector{
+				Labels: map[string]string{"app": "db"},
+			}},
+			expected: false,
+		},
+		"package context matches": {
+			when:     kptfilev1.Condition{PackageContext: map[string]string{"name": "web"}},
+			expected: true,
+		},
+		"package context doesn't match": {
+			when:     kptfilev1.Condition{PackageContext: map[string]string{"path": "db"}},
+			expected: false,
+		},
+		"resource matches but package context doesn't": {
+			when: kptfilev1.Condition{
+				ResourceExists: &kptfilev1.ResourceSelector{Kind: "Ingress"},
+				PackageContext: map[string]string{"name": "db"},
+			},
+			expected: false,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			assert.Equal(t, conditionMatches(&tc.when, resources, pkgContext), tc.expected)
+		})
+	}
+}
+
+func TestConditionalFilterSkipped(t *testing.T) {
+	out := &bytes.Buffer{}
+	fnResults := fnresult.NewResultList()
+	pkgContext := yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+data:
+  name: app
+`)
+	input := []*yaml.RNode{yaml.MustParse(`apiVersion: v1
+kind: Service
+metadata:
+  name: app
+`)}
+
+	ran := false
+	fltr := &conditionalFilter{
+		ctx: fake.CtxWithPrinter(out, out),
+		filter: kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+			ran = true
+			return nil, nil
+		}),
+		when: &kptfilev1.Condition{
+			ResourceExists: &kptfilev1.ResourceSelector{Kind: "Ingress"},
+		},
+		pkgContext: pkgContext,
+		fnResult:   fnresult.Result{Image: "gcr.io/kpt-fn/set-ingress-class"},
+		fnResults:  fnResults,
+	}
+	output, err := fltr.Filter(input)
+	assert.NilError(t, err)
+	assert.Assert(t, !ran)
+	assert.Equal(t, len(output), 1)
+	assert.Equal(t, output[0], input[0])
+	assert.Equal(t, out.String(), "[SKIPPED] \"gcr.io/kpt-fn/set-ingress-class\"\n")
+	assert.Equal(t, len(fnResults.Items), 1)
+	assert.Assert(t, fnResults.Items[0].Skipped)
+}
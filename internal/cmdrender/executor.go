@@ -15,12 +15,15 @@
 package cmdrender
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
@@ -36,12 +39,31 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// DefaultValidatorConcurrency is the number of validators of a package
+// which are run concurrently by default.
+const DefaultValidatorConcurrency = 4
+
 // Executor hydrates a given pkg.
 type Executor struct {
 	PkgPath         string
 	ResultsDirPath  string
 	Output          io.Writer
 	ImagePullPolicy fnruntime.ImagePullPolicy
+	// NoBuiltins disables the in-process implementations of functions, so
+	// all functions are run in containers.
+	NoBuiltins bool
+	// RequireDigests fails the render if the image of any function is not
+	// pinned to a digest.
+	RequireDigests bool
+	// Limits override the resource limits of function containers.
+	Limits kptfilev1.FunctionLimits
+	// AllowEnv allows functions to pass through environment variables of
+	// the kpt process.
+	AllowEnv bool
+	// ValidatorConcurrency is the number of validators of a package which
+	// are run concurrently. DefaultValidatorConcurrency is used if it is
+	// not set.
+	ValidatorConcurrency int
 }
 
 // Execute runs a pipeline.
@@ -55,12 +77,31 @@ func (e *Executor) Execute(ctx context.Context) error {
 		return errors.E(op, types.UniquePath(e.PkgPath), err)
 	}
 
+	runtimeConfigs, err := fnruntime.LoadRuntimeConfigs(e.PkgPath)
+	if err != nil {
+		return errors.E(op, root.pkg.UniquePath, err)
+	}
+
+	// pull all images before running any function, so that a missing
+	// image fails the render early.
+	if err := e.pullImages(ctx, runtimeConfigs); err != nil {
+		return errors.E(op, root.pkg.UniquePath, err)
+	}
+
 	// initialize hydration context
 	hctx := &hydrationContext{
-		root:            root,
-		pkgs:            map[types.UniquePath]*pkgNode{},
-		fnResults:       fnresult.NewResultList(),
-		imagePullPolicy: e.ImagePullPolicy,
+		root:      root,
+		pkgs:      map[types.UniquePath]*pkgNode{},
+		fnResults: fnresult.NewResultList(),
+		// the images have been pulled already
+		imagePullPolicy: pulledImagePullPolicy(e.ImagePullPolicy),
+		noBuiltins:      e.NoBuiltins,
+		allowEnv:        e.AllowEnv,
+		requireDigests:  e.RequireDigests,
+		runtimeConfigs:  runtimeConfigs,
+		limits:          e.Limits,
+
+		validatorConcurrency: e.ValidatorConcurrency,
 	}
 
 	if _, err = hydrate(ctx, root, hctx); err != nil {
@@ -150,6 +191,26 @@ type hydrationContext struct {
 
 	// imagePullPolicy controls the image pulling behavior.
 	imagePullPolicy fnruntime.ImagePullPolicy
+
+	// noBuiltins disables the in-process implementations of functions.
+	noBuiltins bool
+
+	// allowEnv allows functions to pass through environment variables.
+	allowEnv bool
+
+	// requireDigests fails the render for function images which are not
+	// pinned to a digest.
+	requireDigests bool
+
+	// runtimeConfigs restrict and rewrite the function images which are run.
+	runtimeConfigs fnruntime.RuntimeConfigs
+
+	// limits override the resource limits of function containers.
+	limits kptfilev1.FunctionLimits
+
+	// validatorConcurrency is the number of validators of a package which
+	// are run concurrently.
+	validatorConcurrency int
 }
 
 //
@@ -305,7 +366,7 @@ func (pn *pkgNode) runPipeline(ctx context.Context, hctx *hydrationContext, inpu
 		return nil, nil
 	}
 
-	pl, err := pn.pkg.Pipeline()
+	pl, err := pn.pkg.Pipeline(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -341,7 +402,7 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 		return input, nil
 	}
 
-	pl, err := pn.pkg.Pipeline()
+	pl, err := pn.pkg.Pipeline(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -354,6 +415,14 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 	if err != nil {
 		return nil, err
 	}
+	pkgContext, err := packageContext(pn.pkg, hctx.root.pkg)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mutators {
+		mutators[i] = &packageContextFilter{filter: mutators[i], context: pkgContext}
+		mutators[i] = newConditionalFilter(ctx, hctx, &pl.Mutators[i], mutators[i], pkgContext)
+	}
 
 	output := &kio.PackageBuffer{}
 	// create a kio pipeline from kyaml library to execute the function chains
@@ -373,6 +442,9 @@ func (pn *pkgNode) runMutators(ctx context.Context, hctx *hydrationContext, inpu
 }
 
 // runValidators runs a set of validator functions on input resources.
+// Validators don't mutate resources, so up to validatorConcurrency
+// validators are run at the same time. Their output is buffered and printed
+// in the order they are declared in.
 // We bail out on first validation failure today, but the logic can be
 // improved to report multiple failures. Reporting multiple failures
 // will require changes to the way we print errors
@@ -381,7 +453,7 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		return nil
 	}
 
-	pl, err := pn.pkg.Pipeline()
+	pl, err := pn.pkg.Pipeline(ctx)
 	if err != nil {
 		return err
 	}
@@ -390,22 +462,103 @@ func (pn *pkgNode) runValidators(ctx context.Context, hctx *hydrationContext, in
 		return nil
 	}
 
+	pkgContext, err := packageContext(pn.pkg, hctx.root.pkg)
+	if err != nil {
+		return err
+	}
+	runs := make([]*validatorRun, len(pl.Validators))
 	for i := range pl.Validators {
 		fn := pl.Validators[i]
-		validator, err := fnruntime.NewContainerRunner(ctx, &fn, pn.pkg.UniquePath, hctx.fnResults, hctx.imagePullPolicy)
+		run := &validatorRun{fnResults: fnresult.NewResultList()}
+		// every validator prints to its own buffers and records its own
+		// results, since they are run concurrently
+		vctx := printer.WithContext(ctx, printer.New(&run.out, &run.err))
+		vhctx := *hctx
+		vhctx.fnResults = run.fnResults
+		validator, err := newFnRunner(vctx, &vhctx, pn.pkg.UniquePath, &fn)
 		if err != nil {
 			return err
 		}
+		validator = &packageContextFilter{filter: validator, context: pkgContext}
+		run.validator = newConditionalFilter(vctx, &vhctx, &fn, validator, pkgContext)
+		runs[i] = run
+	}
+
+	runConcurrently(runs, hctx.validatorConcurrency, func(run *validatorRun) {
 		// validators are run on a copy of mutated resources to ensure
 		// resources are not mutated.
-		if _, err = validator.Filter(cloneResources(input)); err != nil {
-			return err
+		_, run.runErr = run.validator.Filter(cloneResources(input))
+	})
+
+	pr := printer.FromContextOrDie(ctx)
+	for _, run := range runs {
+		_, _ = io.Copy(pr.OutStream(), &run.out)
+		_, _ = io.Copy(pr.ErrStream(), &run.err)
+		hctx.fnResults.Items = append(hctx.fnResults.Items, run.fnResults.Items...)
+		if run.fnResults.ExitCode != 0 {
+			hctx.fnResults.ExitCode = run.fnResults.ExitCode
+		}
+		if run.runErr != nil {
+			return run.runErr
 		}
 		hctx.executedFunctionCnt++
 	}
 	return nil
 }
 
+// validatorRun is a validator run by runValidators.
+type validatorRun struct {
+	validator kio.Filter
+	out       bytes.Buffer
+	err       bytes.Buffer
+	fnResults *fnresult.ResultList
+	runErr    error
+}
+
+// runConcurrently calls run for the validator runs, with up to concurrency
+// runs at the same time. The runs are started in order, and runs after a
+// failed run which haven't started when it fails are not started, so the
+// runs up to the first failed run are always complete.
+func runConcurrently(runs []*validatorRun, concurrency int, run func(*validatorRun)) {
+	if concurrency < 1 {
+		concurrency = DefaultValidatorConcurrency
+	}
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		// firstFailed is the index of the first failed run
+		firstFailed = len(runs)
+	)
+	queue := make(chan int)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				mu.Lock()
+				skip := i > firstFailed
+				mu.Unlock()
+				if skip {
+					continue
+				}
+				run(runs[i])
+				if runs[i].runErr != nil {
+					mu.Lock()
+					if i < firstFailed {
+						firstFailed = i
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range runs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+}
+
 func cloneResources(input []*yaml.RNode) (output []*yaml.RNode) {
 	for _, resource := range input {
 		output = append(output, resource.Copy())
@@ -487,8 +640,10 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 	var runners []kio.Filter
 	for i := range fns {
 		fn := fns[i]
-		fn.Image = fnruntime.AddDefaultImagePathPrefix(fn.Image)
-		r, err := fnruntime.NewContainerRunner(ctx, &fn, pkgPath, hctx.fnResults, hctx.imagePullPolicy)
+		if fn.Starlark == nil {
+			fn.Image = hctx.runtimeConfigs.AddDefaultImagePathPrefix(fn.Image)
+		}
+		r, err := newFnRunner(ctx, hctx, pkgPath, &fn)
 		if err != nil {
 			return nil, err
 		}
@@ -497,6 +652,147 @@ func fnChain(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePa
 	return runners, nil
 }
 
+// newFnRunner returns a function runner for the given function. Starlark
+// functions are run in-process. The builtin implementation of the function
+// is used if one exists for the exact image, otherwise the function is run
+// in a container.
+func newFnRunner(ctx context.Context, hctx *hydrationContext, pkgPath types.UniquePath, fn *kptfilev1.Function) (kio.Filter, error) {
+	if fn.Starlark != nil {
+		return fnruntime.NewStarlarkRunner(ctx, fn, pkgPath, hctx.fnResults)
+	}
+	builtin := useBuiltin(hctx.noBuiltins, fn)
+	// builtins are part of kpt, so they don't need to be pinned
+	if err := checkFnImage(hctx.runtimeConfigs, hctx.requireDigests && !builtin, fn.Image); err != nil {
+		return nil, errors.E(errors.Fn(fn.Image), pkgPath, err)
+	}
+	// builtins run without a container engine, so only the schema in the
+	// Kptfile is used for them
+	var schemaImage string
+	if !builtin {
+		schemaImage = hctx.runtimeConfigs.RewriteImage(fn.Image)
+	}
+	if err := fnruntime.ValidateFnConfig(ctx, fn, pkgPath, schemaImage); err != nil {
+		return nil, err
+	}
+	if builtin {
+		r, _, err := fnruntime.NewBuiltinRunner(ctx, fn, pkgPath, hctx.fnResults)
+		return r, err
+	}
+	return fnruntime.NewContainerRunner(ctx, fn, pkgPath, hctx.fnResults, fnruntime.RunnerOptions{
+		ImagePullPolicy: hctx.imagePullPolicy,
+		RuntimeConfigs:  hctx.runtimeConfigs,
+		Limits:          hctx.limits,
+		AllowEnv:        hctx.allowEnv,
+	})
+}
+
+// PullImages pulls the images of all container functions in the pipelines
+// of the package and its subpackages.
+func (e *Executor) PullImages(ctx context.Context) error {
+	const op errors.Op = "fn.pull"
+	runtimeConfigs, err := fnruntime.LoadRuntimeConfigs(e.PkgPath)
+	if err != nil {
+		return errors.E(op, types.UniquePath(e.PkgPath), err)
+	}
+	if err := e.pullImages(ctx, runtimeConfigs); err != nil {
+		return errors.E(op, types.UniquePath(e.PkgPath), err)
+	}
+	return nil
+}
+
+func (e *Executor) pullImages(ctx context.Context, runtimeConfigs fnruntime.RuntimeConfigs) error {
+	images, err := pipelineImages(ctx, e.PkgPath, runtimeConfigs, e.NoBuiltins, e.RequireDigests)
+	if err != nil {
+		return err
+	}
+	return fnruntime.PullImages(ctx, images, e.ImagePullPolicy, fnruntime.DefaultPullConcurrency)
+}
+
+// pulledImagePullPolicy returns the pull policy of the functions which are
+// run after their images have been pulled with the given policy. Images
+// aren't pulled again, but any image which wasn't pulled ahead of time is
+// still pulled if it's missing, unless the user asked to never pull.
+func pulledImagePullPolicy(policy fnruntime.ImagePullPolicy) fnruntime.ImagePullPolicy {
+	if policy == fnruntime.NeverPull {
+		return policy
+	}
+	return fnruntime.IfNotPresentPull
+}
+
+// pipelineImages returns the images which are run for the container
+// functions in the pipelines of the package at pkgPath and all of its
+// subpackages. An error is returned if any of the images may not be run.
+func pipelineImages(ctx context.Context, pkgPath string, runtimeConfigs fnruntime.RuntimeConfigs, noBuiltins, requireDigests bool) ([]string, error) {
+	rootPkg, err := pkg.New(pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	pkgPaths := []types.UniquePath{rootPkg.UniquePath}
+	subPkgPaths, err := pkg.Subpackages(rootPkg.UniquePath.String(), pkg.All, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, spp := range subPkgPaths {
+		// sub package paths are all relative to the root package
+		pkgPaths = append(pkgPaths, types.UniquePath(filepath.Join(rootPkg.UniquePath.String(), spp)))
+	}
+
+	images := sets.String{}
+	for _, p := range pkgPaths {
+		pn, err := pkg.New(p.String())
+		if err != nil {
+			return nil, err
+		}
+		pl, err := pn.Pipeline(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var fns []kptfilev1.Function
+		for _, fn := range pl.Mutators {
+			// keep in sync with fnChain
+			if fn.Starlark == nil {
+				fn.Image = runtimeConfigs.AddDefaultImagePathPrefix(fn.Image)
+			}
+			fns = append(fns, fn)
+		}
+		fns = append(fns, pl.Validators...)
+		for _, fn := range fns {
+			if fn.Starlark != nil {
+				continue
+			}
+			builtin := useBuiltin(noBuiltins, &fn)
+			if err := checkFnImage(runtimeConfigs, requireDigests && !builtin, fn.Image); err != nil {
+				return nil, errors.E(errors.Fn(fn.Image), p, err)
+			}
+			if builtin {
+				continue
+			}
+			images.Insert(runtimeConfigs.RewriteImage(fn.Image))
+		}
+	}
+	list := images.List()
+	sort.Strings(list)
+	return list, nil
+}
+
+// useBuiltin returns true if the builtin implementation of the function is
+// run instead of its container. Builtins don't read environment variables,
+// so functions which declare any are run in containers.
+func useBuiltin(noBuiltins bool, fn *kptfilev1.Function) bool {
+	return !noBuiltins && len(fn.Env) == 0 && fnruntime.IsBuiltin(fn.Image)
+}
+
+// checkFnImage returns an error if the function image may not be run.
+func checkFnImage(runtimeConfigs fnruntime.RuntimeConfigs, requireDigests bool, image string) error {
+	if err := runtimeConfigs.CheckImage(image); err != nil {
+		return err
+	}
+	if requireDigests && !fnruntime.HasImageDigest(image) {
+		return fmt.Errorf("function image %q must be pinned to a digest, run `kpt fn pin` to pin the function images", image)
+	}
+	return nil
+}
+
 // trackInputFiles records file paths of input resources in the hydration context.
 func trackInputFiles(hctx *hydrationContext, relPath string, input []*yaml.RNode) error {
 	if hctx.inputFiles == nil {
@@ -15,9 +15,18 @@
 package cmdrender
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"gotest.tools/assert"
 )
 
@@ -89,3 +98,180 @@ func TestPathRelToRoot(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFnRunnerRequireDigests(t *testing.T) {
+	hctx := &hydrationContext{requireDigests: true, noBuiltins: true}
+	_, err := newFnRunner(context.Background(), hctx, "/tmp/pkg",
+		&kptfilev1.Function{Image: "gcr.io/kpt-fn/set-labels:v0.1"})
+	assert.ErrorContains(t, err, `function image "gcr.io/kpt-fn/set-labels:v0.1" must be pinned to a digest`)
+
+	_, err = newFnRunner(context.Background(), hctx, "/tmp/pkg",
+		&kptfilev1.Function{Image: "gcr.io/kpt-fn/set-labels@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"})
+	assert.NilError(t, err)
+
+	// builtins don't run the image, so they don't need to be pinned
+	hctx.noBuiltins = false
+	_, err = newFnRunner(context.Background(), hctx, "/tmp/pkg",
+		&kptfilev1.Function{Image: "gcr.io/kpt-fn/set-labels:v0.1.4"})
+	assert.NilError(t, err)
+	_, err = newFnRunner(context.Background(), hctx, "/tmp/pkg",
+		&kptfilev1.Function{Image: "gcr.io/kpt-fn/kubeval:v0.1"})
+	assert.ErrorContains(t, err, `function image "gcr.io/kpt-fn/kubeval:v0.1" must be pinned to a digest`)
+}
+
+func TestNewFnRunnerValidatesConfig(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "schema.yaml"), []byte(`type: object
+properties:
+  data:
+    type: object
+    properties:
+      namespace:
+        type: string
+        pattern: "^[a-z0-9-]+$"
+`), 0600))
+
+	// the config of builtins and containers is validated before they run
+	for _, noBuiltins := range []bool{false, true} {
+		_, err := newFnRunner(context.Background(), &hydrationContext{noBuiltins: noBuiltins}, types.UniquePath(dir), &kptfilev1.Function{
+			Image:            "gcr.io/kpt-fn/set-namespace:v0.1.3",
+			ConfigMap:        map[string]string{"namespace": "Prod"},
+			ConfigSchemaPath: "schema.yaml",
+		})
+		assert.ErrorContains(t, err, "data.namespace in body should match")
+	}
+}
+
+func TestNewFnRunnerEnv(t *testing.T) {
+	fn := &kptfilev1.Function{
+		Image: "gcr.io/kpt-fn/set-labels:v0.1",
+		Env:   []string{"FEATURE_FOO=true", "HOME"},
+	}
+	_, err := newFnRunner(context.Background(), &hydrationContext{}, "/tmp/pkg", fn)
+	assert.ErrorContains(t, err, `passes through environment variable "HOME", which requires --allow-env`)
+
+	_, err = newFnRunner(context.Background(), &hydrationContext{allowEnv: true}, "/tmp/pkg", fn)
+	assert.NilError(t, err)
+
+	fn.Env = []string{"FEATURE_FOO=true"}
+	_, err = newFnRunner(context.Background(), &hydrationContext{}, "/tmp/pkg", fn)
+	assert.NilError(t, err)
+}
+
+func TestPipelineImages(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+pipeline:
+  mutators:
+    - image: set-labels:v0.1.4
+    - image: example.com/fns/foo:v1
+    - starlark:
+        source: print(ctx.resource_list)
+`)
+	writeKptfile(t, filepath.Join(dir, "sub"), `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+    - image: example.com/fns/foo:v1
+  validators:
+    - image: gcr.io/kpt-fn/kubeval:v0.1
+`)
+
+	images, err := pipelineImages(context.Background(), dir, nil, false, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"example.com/fns/foo:v1", "gcr.io/kpt-fn/kubeval:v0.1"}, images)
+
+	images, err = pipelineImages(context.Background(), dir, nil, true, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"example.com/fns/foo:v1", "gcr.io/kpt-fn/kubeval:v0.1", "gcr.io/kpt-fn/set-labels:v0.1.4"}, images)
+
+	configs := fnruntime.RuntimeConfigs{{ImageRewrites: []fnruntime.ImageRewrite{
+		{From: "gcr.io/kpt-fn/", To: "mirror.example.com/kpt-fn/"},
+	}}}
+	images, err = pipelineImages(context.Background(), dir, configs, false, false)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"example.com/fns/foo:v1", "mirror.example.com/kpt-fn/kubeval:v0.1"}, images)
+
+	_, err = pipelineImages(context.Background(), dir, nil, false, true)
+	assert.ErrorContains(t, err, "must be pinned to a digest")
+}
+
+func TestPulledImagePullPolicy(t *testing.T) {
+	assert.Equal(t, fnruntime.IfNotPresentPull, pulledImagePullPolicy(fnruntime.AlwaysPull))
+	assert.Equal(t, fnruntime.IfNotPresentPull, pulledImagePullPolicy(fnruntime.IfNotPresentPull))
+	assert.Equal(t, fnruntime.IfNotPresentPull, pulledImagePullPolicy(""))
+	assert.Equal(t, fnruntime.NeverPull, pulledImagePullPolicy(fnruntime.NeverPull))
+}
+
+func writeKptfile(t *testing.T, dir, content string) {
+	assert.NilError(t, os.MkdirAll(dir, 0700))
+	assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, kptfilev1.KptFileName), []byte(content), 0600))
+}
+
+func TestRunConcurrently(t *testing.T) {
+	newRuns := func() []*validatorRun {
+		runs := make([]*validatorRun, 6)
+		for i := range runs {
+			runs[i] = &validatorRun{}
+		}
+		return runs
+	}
+	indexOf := func(runs []*validatorRun, r *validatorRun) int {
+		i := 0
+		for runs[i] != r {
+			i++
+		}
+		return i
+	}
+
+	// runs after the failed run aren't started
+	runs := newRuns()
+	var started []int
+	runConcurrently(runs, 1, func(r *validatorRun) {
+		i := indexOf(runs, r)
+		started = append(started, i)
+		if i == 2 {
+			r.runErr = fmt.Errorf("validation failed")
+		}
+	})
+	assert.DeepEqual(t, []int{0, 1, 2}, started)
+
+	// runs before the failed run are completed, even if they are still
+	// running when it fails
+	runs = newRuns()
+	var (
+		mu      sync.Mutex
+		running int
+		maxRuns int
+		done    []int
+	)
+	failed := make(chan struct{})
+	runConcurrently(runs, 2, func(r *validatorRun) {
+		i := indexOf(runs, r)
+		mu.Lock()
+		running++
+		if running > maxRuns {
+			maxRuns = running
+		}
+		mu.Unlock()
+		switch i {
+		case 1:
+			<-failed
+		case 2:
+			r.runErr = fmt.Errorf("validation failed")
+			close(failed)
+		}
+		mu.Lock()
+		running--
+		done = append(done, i)
+		mu.Unlock()
+	})
+	assert.Assert(t, maxRuns <= 2)
+	sort.Ints(done)
+	assert.DeepEqual(t, []int{0, 1, 2}, done[:3])
+}
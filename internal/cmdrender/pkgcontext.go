@@ -0,0 +1,139 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdrender
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// PackageContextName is the name of the ConfigMap which describes the
+	// package a function is running in. It is added to the input of every
+	// function during render, and removed from the output of the function.
+	PackageContextName = "kptfile.kpt.dev"
+
+	// packageContextAnnotation marks the package context added by kpt, so
+	// that a ConfigMap of the package with the same name is left alone.
+	packageContextAnnotation = "internal.kpt.dev/package-context"
+
+	localConfigAnnotation = "config.kubernetes.io/local-config"
+)
+
+// packageContext returns the package context ConfigMap of p. The path is
+// relative to the root package being rendered.
+func packageContext(p *pkg.Pkg, root *pkg.Pkg) (*yaml.RNode, error) {
+	kf, err := p.Kptfile()
+	if err != nil {
+		return nil, err
+	}
+	relPath, err := p.RelativePathTo(root)
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]string{
+		"name": kf.Name,
+		"path": filepath.ToSlash(relPath),
+	}
+	if lock := kf.UpstreamLock; lock != nil {
+		setIfNotEmpty(data, "upstream.type", string(lock.Type))
+		if git := lock.Git; git != nil {
+			setIfNotEmpty(data, "upstream.repo", git.Repo)
+			setIfNotEmpty(data, "upstream.directory", git.Directory)
+			setIfNotEmpty(data, "upstream.ref", git.Ref)
+			setIfNotEmpty(data, "upstream.commit", git.Commit)
+		}
+		if oci := lock.Oci; oci != nil {
+			setIfNotEmpty(data, "upstream.image", oci.Image)
+			setIfNotEmpty(data, "upstream.digest", oci.Digest)
+		}
+		if local := lock.Local; local != nil {
+			setIfNotEmpty(data, "upstream.path", local.Path)
+			setIfNotEmpty(data, "upstream.hash", local.Hash)
+		}
+	}
+	if kf.Info != nil {
+		info := kf.Info
+		setIfNotEmpty(data, "info.site", info.Site)
+		setIfNotEmpty(data, "info.emails", strings.Join(info.Emails, ","))
+		setIfNotEmpty(data, "info.license", info.License)
+		setIfNotEmpty(data, "info.licenseFile", info.LicenseFile)
+		setIfNotEmpty(data, "info.description", info.Description)
+		setIfNotEmpty(data, "info.keywords", strings.Join(info.Keywords, ","))
+		setIfNotEmpty(data, "info.man", info.Man)
+	}
+
+	cm := yaml.NewMapRNode(nil)
+	cm.SetApiVersion("v1")
+	cm.SetKind("ConfigMap")
+	if err := cm.SetName(PackageContextName); err != nil {
+		return nil, err
+	}
+	if err := cm.SetAnnotations(map[string]string{
+		localConfigAnnotation:    "true",
+		packageContextAnnotation: "true",
+	}); err != nil {
+		return nil, err
+	}
+	cm.SetDataMap(data)
+	return cm, nil
+}
+
+func setIfNotEmpty(data map[string]string, key, value string) {
+	if value != "" {
+		data[key] = value
+	}
+}
+
+// isPackageContext returns true if r is the package context ConfigMap
+// added by kpt.
+func isPackageContext(r *yaml.RNode) bool {
+	return r.GetApiVersion() == "v1" && r.GetKind() == "ConfigMap" &&
+		r.GetName() == PackageContextName &&
+		r.GetAnnotations()[packageContextAnnotation] == "true"
+}
+
+// packageContextFilter adds a copy of the package context to the input of
+// the wrapped function, and removes it from the output. A copy is used for
+// every function, so functions can't change the context seen by later
+// functions.
+type packageContextFilter struct {
+	filter  kio.Filter
+	context *yaml.RNode
+}
+
+func (f *packageContextFilter) Filter(input []*yaml.RNode) ([]*yaml.RNode, error) {
+	in := make([]*yaml.RNode, 0, len(input)+1)
+	in = append(in, input...)
+	in = append(in, f.context.Copy())
+	output, err := f.filter.Filter(in)
+	return stripPackageContext(output), err
+}
+
+// stripPackageContext removes the package context from resources.
+func stripPackageContext(resources []*yaml.RNode) []*yaml.RNode {
+	var output []*yaml.RNode
+	for _, r := range resources {
+		if !isPackageContext(r) {
+			output = append(output, r)
+		}
+	}
+	return output
+}
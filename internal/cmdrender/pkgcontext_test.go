@@ -0,0 +1,176 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdrender
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"gotest.tools/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestPackageContext(t *testing.T) {
+	dir := t.TempDir()
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: root
+`)
+	writeKptfile(t, filepath.Join(dir, "db"), `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: mysql
+upstreamLock:
+  type: git
+  git:
+    repo: https://github.com/example/pkgs
+    directory: /mysql
+    ref: v1
+    commit: abc123
+info:
+  description: MySQL database
+  keywords:
+  - sql
+  - database
+`)
+	root, err := pkg.New(dir)
+	assert.NilError(t, err)
+	sub, err := pkg.New(filepath.Join(dir, "db"))
+	assert.NilError(t, err)
+
+	cm, err := packageContext(sub, root)
+	assert.NilError(t, err)
+	assert.Equal(t, cm.MustString(), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+  annotations:
+    config.kubernetes.io/local-config: "true"
+    internal.kpt.dev/package-context: "true"
+data:
+  info.description: MySQL database
+  info.keywords: sql,database
+  name: mysql
+  path: db
+  upstream.commit: abc123
+  upstream.directory: /mysql
+  upstream.ref: v1
+  upstream.repo: https://github.com/example/pkgs
+  upstream.type: git
+`)
+
+	cm, err = packageContext(root, root)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cm.GetDataMap(), map[string]string{"name": "root", "path": "."})
+}
+
+func TestPackageContextUpstream(t *testing.T) {
+	testCases := map[string]struct {
+		upstreamLock string
+		expected     map[string]string
+	}{
+		"oci": {
+			upstreamLock: `  type: oci
+  oci:
+    image: us-docker.pkg.dev/example/packages/mysql:v1
+    digest: sha256:abc123
+`,
+			expected: map[string]string{
+				"upstream.type":   "oci",
+				"upstream.image":  "us-docker.pkg.dev/example/packages/mysql:v1",
+				"upstream.digest": "sha256:abc123",
+			},
+		},
+		"local": {
+			upstreamLock: `  type: local
+  local:
+    path: ../vendor/mysql-v1.tar.gz
+    hash: abc123
+`,
+			expected: map[string]string{
+				"upstream.type": "local",
+				"upstream.path": "../vendor/mysql-v1.tar.gz",
+				"upstream.hash": "abc123",
+			},
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKptfile(t, dir, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: mysql
+upstreamLock:
+`+tc.upstreamLock)
+			p, err := pkg.New(dir)
+			assert.NilError(t, err)
+
+			cm, err := packageContext(p, p)
+			assert.NilError(t, err)
+			tc.expected["name"] = "mysql"
+			tc.expected["path"] = "."
+			assert.DeepEqual(t, cm.GetDataMap(), tc.expected)
+		})
+	}
+}
+
+func TestPackageContextFilter(t *testing.T) {
+	pkgContext := yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+  annotations:
+    internal.kpt.dev/package-context: "true"
+data:
+  name: foo
+`)
+	input := []*yaml.RNode{yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`), yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+data:
+  owner: user
+`)}
+
+	var seen []*yaml.RNode
+	fltr := &packageContextFilter{
+		filter: kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+			seen = nodes
+			// modifications of the context must not be visible to later functions
+			nodes[2].SetDataMap(map[string]string{"name": "bar"})
+			return nodes, nil
+		}),
+		context: pkgContext,
+	}
+	output, err := fltr.Filter(input)
+	assert.NilError(t, err)
+	assert.Equal(t, len(seen), 3)
+	assert.Equal(t, seen[2].GetName(), PackageContextName)
+	// a ConfigMap of the package with the name of the package context is kept
+	assert.Equal(t, len(output), 2)
+	assert.Equal(t, output[0].GetName(), "app")
+	assert.DeepEqual(t, output[1].GetDataMap(), map[string]string{"owner": "user"})
+	assert.Equal(t, len(input), 2)
+	assert.Equal(t, pkgContext.GetDataMap()["name"], "foo")
+}
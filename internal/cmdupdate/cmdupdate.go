@@ -52,6 +52,14 @@ func NewRunner(ctx context.Context, parent string) *Runner {
 		"the update strategy that will be used when updating the package. This will change "+
 			"the default strategy for the package -- must be one of: "+
 			strings.Join(kptfilev1.UpdateStrategiesAsStrings(), ","))
+	c.Flags().BoolVar(&r.Update.DryRun, "dry-run", false,
+		"print the changes the update would make to the package, without changing it.")
+	c.Flags().BoolVar(&r.Update.FailOnConflict, "fail-on-conflict", false,
+		"abort the update without changing the package if resources or fields were changed both upstream and locally.")
+	c.Flags().StringVar(&r.Update.ConflictReportPath, "conflict-report", "",
+		"path of a file to write the conflicts of the update to as YAML.")
+	c.Flags().BoolVar(&r.interactive, "interactive", false,
+		"prompt for the value of each field which was changed both upstream and locally.")
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -64,14 +72,26 @@ func NewCommand(ctx context.Context, parent string) *cobra.Command {
 // Runner contains the run function.
 // TODO, support listing versions
 type Runner struct {
-	ctx      context.Context
-	strategy string
-	Update   update.Command
-	Command  *cobra.Command
+	ctx         context.Context
+	strategy    string
+	interactive bool
+	Update      update.Command
+	Command     *cobra.Command
 }
 
-func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 	const op errors.Op = "cmdupdate.preRunE"
+	if r.interactive {
+		if r.Update.FailOnConflict {
+			return errors.E(op, errors.InvalidParam,
+				fmt.Errorf("--interactive and --fail-on-conflict cannot be used together"))
+		}
+		if r.Update.DryRun {
+			return errors.E(op, errors.InvalidParam,
+				fmt.Errorf("--interactive and --dry-run cannot be used together"))
+		}
+		r.Update.Resolver = update.NewPromptResolver(c.InOrStdin(), c.ErrOrStderr())
+	}
 	if len(args) == 0 {
 		args = append(args, pkg.CurDir)
 	}
@@ -16,6 +16,7 @@ package cmdupdate_test
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -179,6 +180,50 @@ func TestCmd_failUnCommitted(t *testing.T) {
 	}
 }
 
+// TestCmd_dryRun verifies the changes of the update are printed, and the
+// package isn't changed, even though it has uncommitted changes.
+func TestCmd_dryRun(t *testing.T) {
+	g, w, clean := testutil.SetupRepoAndWorkspace(t, testutil.Content{
+		Data:   testutil.Dataset1,
+		Branch: "master",
+	})
+	defer clean()
+
+	defer testutil.Chdir(t, w.WorkspaceDirectory)()
+
+	dest := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+
+	// clone the repo
+	getCmd := cmdget.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	getCmd.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git", w.WorkspaceDirectory})
+	err := getCmd.Command.Execute()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// update the master branch
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		return
+	}
+	_, err = g.Commit("new dataset")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	out := &bytes.Buffer{}
+	updateCmd := cmdupdate.NewRunner(fake.CtxWithPrinter(out, &bytes.Buffer{}), "kpt")
+	updateCmd.Command.SetArgs([]string{g.RepoName, "--dry-run"})
+	if !assert.NoError(t, updateCmd.Command.Execute()) {
+		return
+	}
+	assert.Contains(t, out.String(), fmt.Sprintf("--- a/%s/Kptfile", g.RepoName))
+	assert.Contains(t, out.String(), "Resources to modify:")
+
+	if !g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1), dest, true) {
+		return
+	}
+}
+
 // NoOpRunE is a noop function to replace the run function of a command.  Useful for testing argument parsing.
 var NoOpRunE = func(cmd *cobra.Command, args []string) error { return nil }
 
@@ -241,6 +286,36 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, kptfilev1.ResourceMerge, r.Update.Strategy)
 	assert.Equal(t, "", r.Update.Ref)
+
+	// verify --interactive sets a resolver
+	r = cmdupdate.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SetArgs([]string{"foo", "--interactive"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	assert.NotNil(t, r.Update.Resolver)
+
+	// verify --interactive can't be used with --fail-on-conflict
+	r = cmdupdate.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{"foo", "--interactive", "--fail-on-conflict"})
+	err = r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--interactive and --fail-on-conflict cannot be used together")
+	}
+
+	// verify --interactive can't be used with --dry-run
+	r = cmdupdate.NewRunner(fake.CtxWithDefaultPrinter(), "kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{"foo", "--interactive", "--dry-run"})
+	err = r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--interactive and --dry-run cannot be used together")
+	}
 }
 
 // TestCmd_fail verifies that that command returns an error when it fails rather than exiting the process
@@ -7,20 +7,82 @@ The ` + "`" + `fn` + "`" + ` command group contains subcommands for transforming
 using containerized functions.
 `
 
+var DescribeShort = `Describe a function of a function catalog`
+var DescribeLong = `
+` + "`" + `kpt fn describe` + "`" + ` prints the image, versions, description, config schema and
+examples of a function in a function catalog. The function is looked up by name
+or by image, ignoring the tag of the image. The catalog is read without pulling
+or running any function images. See ` + "`" + `kpt fn list` + "`" + ` for the catalog format.
+
+  kpt fn describe NAME|IMAGE [flags]
+
+Args:
+
+  NAME|IMAGE:
+    The name of the function in the catalog, e.g. ` + "`" + `set-labels` + "`" + `, or its image,
+    e.g. ` + "`" + `gcr.io/kpt-fn/set-labels:v0.1` + "`" + `. If the full image path is not
+    specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
+
+Flags:
+
+  --catalog:
+    Location of the function catalog. It is either a local catalog file, a local
+    directory containing a ` + "`" + `catalog.yaml` + "`" + ` file, or a directory in a git repository
+    containing a ` + "`" + `catalog.yaml` + "`" + ` file, e.g.
+    ` + "`" + `https://github.com/example/fns.git/catalog@v1` + "`" + `. Git repositories are
+    fetched through the kpt repository cache. A ref which has been fetched before
+    is read from the cache, so the catalog is available offline and a branch is
+    not updated until the cache is cleared. Defaults to the value of the
+    ` + "`" + `KPT_FN_CATALOG` + "`" + ` environment variable.
+
+Env Vars:
+
+  KPT_FN_CATALOG:
+    Location of the function catalog, if ` + "`" + `--catalog` + "`" + ` is not specified.
+`
+var DescribeExamples = `
+  # describe the set-labels function of the catalog in functions/catalog.yaml
+  $ kpt fn describe set-labels --catalog functions/catalog.yaml
+`
+
 var DocShort = `Display the documentation for a function`
 var DocLong = `
 ` + "`" + `kpt fn doc` + "`" + ` invokes the function container with ` + "`" + `--help` + "`" + ` flag.
 If the function supports ` + "`" + `--help` + "`" + `, it will print the documentation to STDOUT.
 Otherwise, it will exit with non-zero exit code and print the error message to STDERR.
 
+If a function catalog is configured and contains the function, the metadata
+from the catalog is printed instead, without running the container. See
+` + "`" + `kpt fn list` + "`" + ` for the catalog format.
+
   kpt fn doc --image=IMAGE
 
 Flags:
 
+  --catalog:
+    Location of the function catalog. It is either a local catalog file, a local
+    directory containing a ` + "`" + `catalog.yaml` + "`" + ` file, or a directory in a git repository
+    containing a ` + "`" + `catalog.yaml` + "`" + ` file, e.g.
+    ` + "`" + `https://github.com/example/fns.git/catalog@v1` + "`" + `. Git repositories are
+    fetched through the kpt repository cache. A ref which has been fetched before
+    is read from the cache, so the catalog is available offline and a branch is
+    not updated until the cache is cleared. Defaults to the value of the
+    ` + "`" + `KPT_FN_CATALOG` + "`" + ` environment variable.
+  
   --image, i: (required flag)
     Container image of the function e.g. ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + `.
     For convenience, if full image path is not specified, ` + "`" + `gcr.io/kpt-fn/` + "`" + ` is added as default prefix.
     e.g. instead of passing ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1` + "`" + ` you can pass ` + "`" + `set-namespace:v0.1` + "`" + `.
+
+Env Vars:
+
+  KPT_FN_CATALOG:
+    Location of the function catalog, if ` + "`" + `--catalog` + "`" + ` is not specified.
+  
+  KPT_FN_CONFIG:
+    Path to the user level function runtime config which configures and restricts
+    the function images that are run. Defaults to <HOME>/.kpt/fn-config.yaml.
+    See ` + "`" + `kpt fn` + "`" + ` for the format of the config.
 `
 var DocExamples = `
   # display the documentation for image set-namespace:v0.1.1
@@ -58,6 +120,10 @@ Flags:
     By default, container function is executed as ` + "`" + `nobody` + "`" + ` user. You may want to use
     this flag to run higher privilege operations such as mounting the local filesystem.
   
+  --cpus:
+    Number of CPUs available to each function container, e.g. ` + "`" + `0.5` + "`" + `. Overrides
+    the ` + "`" + `limits` + "`" + ` of functions and of the function runtime config.
+  
   --env, e:
     List of local environment variables to be exported to the container function.
     By default, none of local environment variables are made available to the
@@ -94,6 +160,10 @@ Flags:
     If enabled, meta resources (i.e. ` + "`" + `Kptfile` + "`" + ` and ` + "`" + `functionConfig` + "`" + `) are included
     in the input to the function. By default it is disabled.
   
+  --memory:
+    Memory limit of each function container, e.g. ` + "`" + `512m` + "`" + `. Overrides the ` + "`" + `limits` + "`" + `
+    of functions and of the function runtime config.
+  
   --mount:
     List of storage options to enable reading from the local filesytem. By default,
     container functions can not access the local filesystem. It accepts the same options
@@ -113,11 +183,30 @@ Flags:
     3. OUT_DIR_PATH: output resources are written to provided directory.
        The provided directory must not already exist.
   
+  --pids-limit:
+    Maximum number of processes in each function container, or -1 for no limit.
+    Overrides the ` + "`" + `limits` + "`" + ` of functions and of the function runtime config.
+  
+  --read-only:
+    Run function containers with a read-only root filesystem. A writable ` + "`" + `/tmp` + "`" + `
+    is still provided to functions. ` + "`" + `--read-only=false` + "`" + ` runs them with a writable
+    root filesystem even if the ` + "`" + `limits` + "`" + ` of functions or the function runtime
+    config require a read-only one.
+  
   --results-dir:
     Path to a directory to write structured results. Directory will be created if
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
+
+Env Vars:
+
+  KPT_FN_CONFIG:
+    Path to the user level function runtime config which configures and restricts
+    the function images that are run. Defaults to <HOME>/.kpt/fn-config.yaml.
+    The function runtime config of the git repository containing the package,
+    ` + "`" + `.kpt/fn-config.yaml` + "`" + ` in the repository root, is applied as well. See
+    ` + "`" + `kpt fn` + "`" + ` for the format of the config.
 `
 var EvalExamples = `
   # execute container my-fn on the resources in DIR directory and
@@ -211,6 +300,155 @@ var ExportExamples = `
   kpt fn export DIR/ --fn-path FUNCTIONS_DIR/ --workflow cloud-build
 `
 
+var ListShort = `List the functions in a function catalog`
+var ListLong = `
+` + "`" + `kpt fn list` + "`" + ` lists the name, latest version and description of the functions
+in a function catalog. The catalog is read without pulling or running any
+function images, so no container engine is required. A catalog in a local file
+doesn't require network access either.
+
+  kpt fn list [flags]
+
+Flags:
+
+  --catalog:
+    Location of the function catalog. It is either a local catalog file, a local
+    directory containing a ` + "`" + `catalog.yaml` + "`" + ` file, or a directory in a git repository
+    containing a ` + "`" + `catalog.yaml` + "`" + ` file, e.g.
+    ` + "`" + `https://github.com/example/fns.git/catalog@v1` + "`" + `. Git repositories are
+    fetched through the kpt repository cache. A ref which has been fetched before
+    is read from the cache, so the catalog is available offline and a branch is
+    not updated until the cache is cleared. Defaults to the value of the
+    ` + "`" + `KPT_FN_CATALOG` + "`" + ` environment variable.
+
+Env Vars:
+
+  KPT_FN_CATALOG:
+    Location of the function catalog, if ` + "`" + `--catalog` + "`" + ` is not specified.
+
+Catalog format:
+
+A function catalog is a YAML file of kind ` + "`" + `FunctionCatalog` + "`" + `:
+
+  apiVersion: kpt.dev/v1
+  kind: FunctionCatalog
+  functions:
+    - name: set-labels
+      image: gcr.io/kpt-fn/set-labels
+      # published versions, from oldest to latest.
+      versions:
+        - v0.1.4
+        - v0.1.5
+      description: Add or update labels of resources.
+      # OpenAPI schema of the function config.
+      configSchema:
+        type: object
+        properties:
+          data:
+            type: object
+            additionalProperties:
+              type: string
+      examples:
+        - description: Add the label ` + "`" + `tier=backend` + "`" + ` to all resources.
+          content: |
+            apiVersion: v1
+            kind: ConfigMap
+            metadata:
+              name: labels
+            data:
+              tier: backend
+`
+var ListExamples = `
+  # list the functions of the catalog in functions/catalog.yaml
+  $ kpt fn list --catalog functions/catalog.yaml
+
+  # list the functions of a catalog published in a git repository
+  $ kpt fn list --catalog https://github.com/example/fns.git/catalog@v1
+`
+
+var PinShort = `Pin the function images of a package to digests`
+var PinLong = `
+` + "`" + `kpt fn pin` + "`" + ` resolves the image of every function in the pipelines of a package
+and its subpackages to a digest, and records the image as
+` + "`" + `IMAGE:TAG@sha256:DIGEST` + "`" + ` in each ` + "`" + `Kptfile` + "`" + `, keeping the tag so the version
+stays readable. The ` + "`" + `Kptfile` + "`" + `s are updated in place, preserving comments and
+formatting. Images are pulled using the container engine to resolve the
+digest. Functions which are already pinned to a digest and Starlark functions
+are left unchanged.
+
+Functions which kpt runs with a builtin implementation, such as
+` + "`" + `gcr.io/kpt-fn/set-labels:v0.1.4` + "`" + `, don't run the image and are left unchanged,
+unless ` + "`" + `--no-builtins` + "`" + ` is set.
+
+The functions of pipeline fragments included from local files are pinned in
+those files, which may be shared with other packages. The functions of pipeline
+fragments in git repositories can't be pinned by ` + "`" + `kpt fn pin` + "`" + `: the other
+functions are pinned, and an error lists the functions which must be pinned in
+the repositories of the fragments. The commits the ` + "`" + `ref` + "`" + `s of the fragments in
+git repositories resolve to are recorded in the ` + "`" + `commit` + "`" + ` fields of the
+includes, so that the fragments are read at those commits.
+
+Tags are mutable, so pinning the function images makes the output of
+` + "`" + `kpt fn render` + "`" + ` reproducible. Use ` + "`" + `kpt fn render --require-digests` + "`" + ` to ensure
+that all functions are pinned.
+
+  kpt fn pin [PKG_PATH]
+
+Args:
+
+  PKG_PATH:
+    Local package path to pin the function images. Defaults to the current
+    working directory.
+
+Flags:
+
+  --no-builtins:
+    If set, the functions which kpt runs with a builtin implementation are
+    pinned too. Use it if the package is rendered with ` + "`" + `--no-builtins` + "`" + `.
+`
+var PinExamples = `
+  # pin the function images of the package in the current directory
+  $ kpt fn pin
+
+  # pin the function images of the package in directory my-package-dir
+  $ kpt fn pin my-package-dir
+`
+
+var PullShort = `Pull the function images of a package`
+var PullLong = `
+` + "`" + `kpt fn pull` + "`" + ` pulls the images of all functions in the pipelines of a package
+and its subpackages using the container engine. Images are pulled concurrently.
+This can be used to warm the local image cache ahead of working offline, after
+which the package can be rendered with ` + "`" + `--image-pull-policy never` + "`" + `.
+
+` + "`" + `kpt fn render` + "`" + ` pulls all images the same way before running any function.
+
+  kpt fn pull [PKG_PATH] [flags]
+
+Args:
+
+  PKG_PATH:
+    Local package path to pull the function images for. Defaults to the current
+    working directory.
+
+Flags:
+
+  --image-pull-policy:
+    If the images should be pulled. It can be set to one of always, ifNotPresent,
+    never. If unspecified, always will be the default.
+  
+  --no-builtins:
+    Images of functions which kpt implements in-process are not pulled, unless
+    this flag is set.
+`
+var PullExamples = `
+  # pull the function images of the package in the current directory
+  $ kpt fn pull
+
+  # pull the function images of my-package-dir which aren't available locally
+  $ kpt fn pull my-package-dir --image-pull-policy ifNotPresent
+`
+
 var RenderShort = `Render a package.`
 var RenderLong = `
   kpt fn render [PKG_PATH] [flags]
@@ -223,10 +461,30 @@ Args:
 
 Flags:
 
+  --allow-env:
+    Allow functions to pass through environment variables of the kpt process.
+    Functions which declare ` + "`" + `env` + "`" + ` entries without a value fail to run unless this
+    flag is set, so that secrets aren't exposed to functions unintentionally.
+  
+  --cpus:
+    Number of CPUs available to each function container, e.g. ` + "`" + `0.5` + "`" + `. Overrides
+    the ` + "`" + `limits` + "`" + ` of functions and of the function runtime config.
+  
   --image-pull-policy:
     If the image should be pulled before rendering the package(s). It can be set
     to one of always, ifNotPresent, never. If unspecified, always will be the
-    default.
+    default. The images of all functions are pulled concurrently before any
+    function is run, and aren't pulled again when the functions are run.
+  
+  --memory:
+    Memory limit of each function container, e.g. ` + "`" + `512m` + "`" + `. Overrides the ` + "`" + `limits` + "`" + `
+    of functions and of the function runtime config.
+  
+  --no-builtins:
+    kpt ships in-process implementations of some commonly used functions, e.g.
+    ` + "`" + `gcr.io/kpt-fn/set-namespace:v0.1.3` + "`" + `. These are used in place of the function
+    container when the image matches one of the versions they implement exactly.
+    If this flag is set, all functions are run in containers.
   
   --output, o:
     If specified, the output resources are written to provided location,
@@ -237,11 +495,41 @@ Flags:
     3. OUT_DIR_PATH: output resources are written to provided directory.
        The provided directory must not already exist.
   
+  --pids-limit:
+    Maximum number of processes in each function container, or -1 for no limit.
+    Overrides the ` + "`" + `limits` + "`" + ` of functions and of the function runtime config.
+  
+  --read-only:
+    Run function containers with a read-only root filesystem. A writable ` + "`" + `/tmp` + "`" + `
+    is still provided to functions. ` + "`" + `--read-only=false` + "`" + ` runs them with a writable
+    root filesystem even if the ` + "`" + `limits` + "`" + ` of functions or the function runtime
+    config require a read-only one.
+  
+  --require-digests:
+    If set, render fails if the image of any function is not pinned to a digest,
+    e.g. ` + "`" + `gcr.io/kpt-fn/set-labels:v0.1@sha256:<DIGEST>` + "`" + `. Use ` + "`" + `kpt fn pin` + "`" + ` to pin
+    the function images of a package. Functions which are run with a builtin
+    implementation don't need to be pinned, unless ` + "`" + `--no-builtins` + "`" + ` is set.
+  
   --results-dir:
     Path to a directory to write structured results. Directory will be created if
     it doesn't exist. Structured results emitted by the functions are aggregated and saved
     to ` + "`" + `results.yaml` + "`" + ` file in the specified directory.
     If not specified, no result files are written to the local filesystem.
+  
+  --validator-concurrency:
+    Maximum number of validators of a package which are run at the same time.
+    The output of the validators is printed in the order they are declared in.
+    Defaults to 4. Set it to 1 to run validators one after another.
+
+Env Vars:
+
+  KPT_FN_CONFIG:
+    Path to the user level function runtime config which configures and restricts
+    the function images that are run. Defaults to <HOME>/.kpt/fn-config.yaml.
+    The function runtime config of the git repository containing the package,
+    ` + "`" + `.kpt/fn-config.yaml` + "`" + ` in the repository root, is applied as well. See
+    ` + "`" + `kpt fn` + "`" + ` for the format of the config.
 `
 var RenderExamples = `
   # Render the package in current directory
@@ -0,0 +1,265 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fncatalog reads catalogs of function metadata, which allow
+// functions to be discovered and documented without running their images.
+package fncatalog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/parse"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// CatalogKind is the kind of a function catalog.
+	CatalogKind = "FunctionCatalog"
+
+	// CatalogFileName is the name of the catalog file if the catalog location
+	// is a directory.
+	CatalogFileName = "catalog.yaml"
+
+	// CatalogEnv can be set to the location of the function catalog.
+	CatalogEnv = "KPT_FN_CATALOG"
+)
+
+// Catalog is an index of functions and their metadata.
+type Catalog struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	// Functions are the functions in the catalog.
+	Functions []Function `yaml:"functions,omitempty"`
+}
+
+// Function contains the metadata of a function.
+type Function struct {
+	// Name is the name of the function, e.g. `set-labels`.
+	Name string `yaml:"name"`
+
+	// Image is the image of the function without a tag,
+	// e.g. `gcr.io/kpt-fn/set-labels`.
+	Image string `yaml:"image"`
+
+	// Versions are the published tags of the image, from oldest to latest.
+	Versions []string `yaml:"versions,omitempty"`
+
+	// Description is a short description of the function.
+	Description string `yaml:"description,omitempty"`
+
+	// ConfigSchema is the OpenAPI schema of the function config.
+	ConfigSchema map[string]interface{} `yaml:"configSchema,omitempty"`
+
+	// Examples show how to use the function.
+	Examples []Example `yaml:"examples,omitempty"`
+}
+
+// Example is an example usage of a function.
+type Example struct {
+	// Description describes the example.
+	Description string `yaml:"description,omitempty"`
+
+	// Content is the example, e.g. a function config or a Kptfile pipeline.
+	Content string `yaml:"content,omitempty"`
+}
+
+// LatestVersion returns the latest version of the function, or an empty
+// string if the catalog doesn't list any versions.
+func (f *Function) LatestVersion() string {
+	if len(f.Versions) == 0 {
+		return ""
+	}
+	return f.Versions[len(f.Versions)-1]
+}
+
+// Describe writes the metadata of the function to w.
+func (f *Function) Describe(w io.Writer) error {
+	fmt.Fprintf(w, "Name: %s\n", f.Name)
+	fmt.Fprintf(w, "Image: %s\n", f.Image)
+	if len(f.Versions) != 0 {
+		fmt.Fprintf(w, "Versions: %s\n", strings.Join(f.Versions, ", "))
+	}
+	if f.Description != "" {
+		fmt.Fprintf(w, "Description:\n%s", indent(f.Description))
+	}
+	if len(f.ConfigSchema) != 0 {
+		b, err := yaml.Marshal(f.ConfigSchema)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Config Schema:\n%s", indent(string(b)))
+	}
+	if len(f.Examples) != 0 {
+		fmt.Fprintf(w, "Examples:\n")
+		for _, e := range f.Examples {
+			if e.Description != "" {
+				fmt.Fprint(w, indent(e.Description))
+			}
+			if e.Content != "" {
+				fmt.Fprint(w, indent(indent(e.Content)))
+			}
+		}
+	}
+	return nil
+}
+
+// indent indents every line of s by two spaces.
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := range lines {
+		if lines[i] != "" {
+			lines[i] = "  " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Lookup returns the function with the given name, or the function of the
+// given image. A tag or digest of the image is ignored.
+func (c *Catalog) Lookup(nameOrImage string) (*Function, bool) {
+	repo := imageRepository(nameOrImage)
+	for i := range c.Functions {
+		fn := &c.Functions[i]
+		if fn.Name == nameOrImage || fn.Name == repo || fn.Image == repo {
+			return fn, true
+		}
+	}
+	return nil, false
+}
+
+// imageRepository returns the image without the tag or digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	// a colon after the last slash separates the tag, other colons
+	// separate the port of the registry
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// Location returns the location of the catalog. The flag value takes
+// precedence over CatalogEnv. It returns an empty string if no catalog
+// is configured.
+func Location(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	return os.Getenv(CatalogEnv)
+}
+
+// Load reads the catalog at location. The location is either a local catalog
+// file, a local directory containing a catalog.yaml file, or a directory in a
+// git repository containing a catalog.yaml file, e.g.
+// `https://github.com/example/fns.git/catalog@v1`. Git repositories are
+// fetched through the kpt repository cache, and a ref which has been fetched
+// before is read from the cache without accessing the repository.
+func Load(ctx context.Context, location string) (*Catalog, error) {
+	const op errors.Op = "fncatalog.Load"
+	if isGitLocation(location) {
+		c, err := loadFromGit(ctx, location)
+		if err != nil {
+			return nil, errors.E(op, errors.Repo(location), err)
+		}
+		return c, nil
+	}
+	c, err := loadFromPath(location)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return c, nil
+}
+
+func isGitLocation(location string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git@", "file://"} {
+		if strings.HasPrefix(location, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadFromGit(ctx context.Context, location string) (*Catalog, error) {
+	target, err := parse.GitParseLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	file := strings.TrimPrefix(target.Directory, "/")
+	if ext := path.Ext(file); ext != ".yaml" && ext != ".yml" {
+		file = path.Join(file, CatalogFileName)
+	}
+	// read the catalog from the repo cache if the ref has been fetched
+	// before, so that catalogs can be used offline
+	b, _, found, err := gitutil.ReadCachedFile(ctx, target.Repo, target.Ref, file)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		b, _, err = gitutil.ReadFile(ctx, target.Repo, target.Ref, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Parse(b)
+}
+
+func loadFromPath(path string) (*Catalog, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, CatalogFileName)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.E(errors.IO, types.UniquePath(path), err)
+	}
+	return Parse(b)
+}
+
+// Parse parses and validates a catalog.
+func Parse(b []byte) (*Catalog, error) {
+	c := &Catalog{}
+	d := yaml.NewDecoder(bytes.NewBuffer(b))
+	d.KnownFields(true)
+	if err := d.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid function catalog: %w", err)
+	}
+	if c.APIVersion != kptfilev1.KptFileAPIVersion || c.Kind != CatalogKind {
+		return nil, fmt.Errorf("invalid function catalog: expected %s %s",
+			kptfilev1.KptFileAPIVersion, CatalogKind)
+	}
+	names := map[string]bool{}
+	for _, fn := range c.Functions {
+		if fn.Name == "" || fn.Image == "" {
+			return nil, fmt.Errorf("invalid function catalog: functions must specify `name` and `image`")
+		}
+		if names[fn.Name] {
+			return nil, fmt.Errorf("invalid function catalog: duplicate function %q", fn.Name)
+		}
+		names[fn.Name] = true
+	}
+	return c, nil
+}
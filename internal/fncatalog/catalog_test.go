@@ -0,0 +1,196 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fncatalog
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+const testCatalog = `apiVersion: kpt.dev/v1
+kind: FunctionCatalog
+functions:
+- name: set-labels
+  image: gcr.io/kpt-fn/set-labels
+  versions:
+  - v0.1.4
+  - v0.1.5
+  description: Add or update labels of resources.
+  configSchema:
+    type: object
+  examples:
+  - description: Add the label tier=backend.
+    content: |
+      data:
+        tier: backend
+- name: generate-config
+  image: registry.example.com:5000/fns/generate-config
+`
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		catalog string
+		errMsg  string
+	}{
+		"valid": {
+			catalog: testCatalog,
+		},
+		"wrong kind": {
+			catalog: "apiVersion: kpt.dev/v1\nkind: Kptfile\n",
+			errMsg:  "expected kpt.dev/v1 FunctionCatalog",
+		},
+		"unknown field": {
+			catalog: "apiVersion: kpt.dev/v1\nkind: FunctionCatalog\nfns: []\n",
+			errMsg:  "field fns not found",
+		},
+		"missing image": {
+			catalog: "apiVersion: kpt.dev/v1\nkind: FunctionCatalog\nfunctions:\n- name: foo\n",
+			errMsg:  "functions must specify `name` and `image`",
+		},
+		"duplicate name": {
+			catalog: "apiVersion: kpt.dev/v1\nkind: FunctionCatalog\nfunctions:\n- name: foo\n  image: foo\n- name: foo\n  image: bar\n",
+			errMsg:  `duplicate function "foo"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			_, err := Parse([]byte(tc.catalog))
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestCatalogLookup(t *testing.T) {
+	c, err := Parse([]byte(testCatalog))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testCases := map[string]string{
+		"set-labels":                                       "set-labels",
+		"gcr.io/kpt-fn/set-labels":                         "set-labels",
+		"gcr.io/kpt-fn/set-labels:v0.1":                    "set-labels",
+		"gcr.io/kpt-fn/set-labels@sha256:abc":              "set-labels",
+		"registry.example.com:5000/fns/generate-config:v1": "generate-config",
+		"registry.example.com:5000/fns/generate-config":    "generate-config",
+		"gcr.io/kpt-fn/set-namespace:v0.1":                 "",
+	}
+	for nameOrImage, expected := range testCases {
+		fn, found := c.Lookup(nameOrImage)
+		if expected == "" {
+			assert.False(t, found, nameOrImage)
+			continue
+		}
+		if assert.True(t, found, nameOrImage) {
+			assert.Equal(t, expected, fn.Name)
+		}
+	}
+}
+
+func TestFunctionDescribe(t *testing.T) {
+	c, err := Parse([]byte(testCatalog))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	out := &bytes.Buffer{}
+	assert.NoError(t, c.Functions[0].Describe(out))
+	assert.Equal(t, `Name: set-labels
+Image: gcr.io/kpt-fn/set-labels
+Versions: v0.1.4, v0.1.5
+Description:
+  Add or update labels of resources.
+Config Schema:
+  type: object
+Examples:
+  Add the label tier=backend.
+    data:
+      tier: backend
+`, out.String())
+	assert.Equal(t, "v0.1.5", c.Functions[0].LatestVersion())
+	assert.Equal(t, "", c.Functions[1].LatestVersion())
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, CatalogFileName), []byte(testCatalog), 0600)) {
+		t.FailNow()
+	}
+	for _, location := range []string{dir, filepath.Join(dir, CatalogFileName)} {
+		c, err := Load(context.Background(), location)
+		if assert.NoError(t, err) {
+			assert.Len(t, c.Functions, 2)
+		}
+	}
+	_, err := Load(context.Background(), filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestLoadFromGit(t *testing.T) {
+	g, _, clean := testutil.SetupReposAndWorkspace(t, map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithSubPackages(pkgbuilder.NewSubPkg("catalog").
+						WithFile(CatalogFileName, testCatalog)),
+				Branch: "main",
+				Tag:    "v1",
+			},
+		},
+	})
+	defer clean()
+	ctx := fake.CtxWithDefaultPrinter()
+	repo := g[testutil.Upstream].RepoDirectory
+
+	for _, location := range []string{
+		"file://" + repo + ".git/catalog@v1",
+		"file://" + repo + ".git/catalog/catalog.yaml@v1",
+	} {
+		c, err := Load(ctx, location)
+		if assert.NoError(t, err, location) {
+			assert.Len(t, c.Functions, 2)
+		}
+	}
+
+	// the catalog is read from the cache once it has been fetched
+	if !assert.NoError(t, os.RemoveAll(repo)) {
+		t.FailNow()
+	}
+	c, err := Load(ctx, "file://"+repo+".git/catalog@v1")
+	if assert.NoError(t, err) {
+		assert.Len(t, c.Functions, 2)
+	}
+	_, err = Load(ctx, "file://"+repo+".git/catalog@v2")
+	assert.Error(t, err)
+}
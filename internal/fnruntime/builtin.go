@@ -0,0 +1,60 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+
+	"github.com/GoogleContainerTools/kpt/internal/builtins"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// IsBuiltin returns true if there is a builtin implementation for the
+// exact function image.
+func IsBuiltin(image string) bool {
+	_, found := builtins.Lookup(image)
+	return found
+}
+
+// NewBuiltinRunner returns a kio.Filter which runs the in-process
+// implementation of the given function instead of its container. The
+// second return value is false if there is no builtin implementation
+// for the exact function image.
+func NewBuiltinRunner(
+	ctx context.Context, f *kptfilev1.Function,
+	pkgPath types.UniquePath, fnResults *fnresult.ResultList) (kio.Filter, bool, error) {
+	run, found := builtins.Lookup(f.Image)
+	if !found {
+		return nil, false, nil
+	}
+	config, err := newFnConfig(f, pkgPath)
+	if err != nil {
+		return nil, true, err
+	}
+
+	fnResult := &fnresult.Result{
+		Image: f.Image,
+	}
+	fltr := &runtimeutil.FunctionFilter{
+		Run:            run,
+		FunctionConfig: config,
+	}
+	r, err := NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, true)
+	return r, true, err
+}
@@ -0,0 +1,360 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	goerrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// RuntimeConfigKind is the kind of the function runtime config.
+	RuntimeConfigKind = "FunctionRuntimeConfig"
+
+	// RuntimeConfigFileName is the name of the function runtime config
+	// file in the .kpt directory of the user home or of a git repository.
+	RuntimeConfigFileName = "fn-config.yaml"
+
+	// RuntimeConfigEnv can be set to the path of the user level function
+	// runtime config. Defaults to UserHomeDir/.kpt/fn-config.yaml.
+	RuntimeConfigEnv = "KPT_FN_CONFIG"
+)
+
+// RuntimeConfig configures how kpt runs functions. It is read from a user
+// level file and from a file in the git repository containing the package.
+type RuntimeConfig struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	// AllowedImages is the list of function images which may be run. An
+	// entry ending with `*` matches all images with the preceding prefix,
+	// e.g. `gcr.io/kpt-fn/*`. All images are allowed if the list is empty.
+	AllowedImages []string `yaml:"allowedImages,omitempty"`
+
+	// RequireDigests only allows function images pinned to a digest.
+	RequireDigests bool `yaml:"requireDigests,omitempty"`
+
+	// DefaultImagePrefix is prepended to function images which are only
+	// specified by name, e.g. `set-labels:v0.1`. Defaults to `gcr.io/kpt-fn/`.
+	DefaultImagePrefix string `yaml:"defaultImagePrefix,omitempty"`
+
+	// ImageRewrites are applied to function images before they are pulled
+	// and run, e.g. to use a mirror of a registry.
+	ImageRewrites []ImageRewrite `yaml:"imageRewrites,omitempty"`
+
+	// Limits are the default resource limits of function containers.
+	Limits *kptfilev1.FunctionLimits `yaml:"limits,omitempty"`
+
+	// path is the file the config was read from.
+	path string
+}
+
+// ImageRewrite replaces the prefix From of a function image with To.
+type ImageRewrite struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// RuntimeConfigs are the function runtime configs which apply to a package,
+// ordered from the user level config to the repository config. Where configs
+// set the same option, the user level config takes precedence, since the
+// repository config comes with the package and the user may not trust it.
+type RuntimeConfigs []*RuntimeConfig
+
+// LoadRuntimeConfigs reads the user level function runtime config and the
+// config of the git repository containing pkgPath. Missing config files
+// are ignored, unless the user level config is set with RuntimeConfigEnv.
+func LoadRuntimeConfigs(pkgPath string) (RuntimeConfigs, error) {
+	const op errors.Op = "fn.loadRuntimeConfigs"
+	var configs RuntimeConfigs
+
+	userPath, explicit, err := userRuntimeConfigPath()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	c, err := readRuntimeConfig(userPath, explicit)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if c != nil {
+		configs = append(configs, c)
+	}
+
+	if repoRoot, found := findRepoRoot(pkgPath); found {
+		c, err := readRuntimeConfig(filepath.Join(repoRoot, ".kpt", RuntimeConfigFileName), false)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		if c != nil {
+			configs = append(configs, c)
+		}
+	}
+	return configs, nil
+}
+
+// AddDefaultImagePathPrefix adds the default path prefix to the image if
+// only the image name is specified. The first default prefix set in the
+// configs is used.
+func (cs RuntimeConfigs) AddDefaultImagePathPrefix(image string) string {
+	if strings.Contains(image, "/") {
+		return image
+	}
+	prefix := defaultImagePathPrefix
+	for _, c := range cs {
+		if c.DefaultImagePrefix != "" {
+			prefix = c.DefaultImagePrefix
+			break
+		}
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + image
+}
+
+// RewriteImage applies the first image rewrite rule which matches the
+// image. Rules of the configs are considered in order.
+func (cs RuntimeConfigs) RewriteImage(image string) string {
+	image, _ = cs.rewriteImage(image)
+	return image
+}
+
+// rewriteImage rewrites the image like RewriteImage, and also returns the
+// index of the config whose rule was applied, or -1 if none was.
+func (cs RuntimeConfigs) rewriteImage(image string) (string, int) {
+	for i, c := range cs {
+		for _, rw := range c.ImageRewrites {
+			if strings.HasPrefix(image, rw.From) {
+				return rw.To + strings.TrimPrefix(image, rw.From), i
+			}
+		}
+	}
+	return image, -1
+}
+
+// FunctionLimits returns the resource limits of a function container. The
+// limits of the configs are caps, so a package can't raise them: the lowest
+// limit of the function and the configs is used, and the root filesystem is
+// read-only if the function or any of the configs requires it. The limits of
+// override are set by the user on the command line and take precedence over
+// all of them.
+func (cs RuntimeConfigs) FunctionLimits(fnLimits *kptfilev1.FunctionLimits, override kptfilev1.FunctionLimits) kptfilev1.FunctionLimits {
+	var limits kptfilev1.FunctionLimits
+	if fnLimits != nil {
+		limits = *fnLimits
+	}
+	for _, c := range cs {
+		if c.Limits != nil {
+			capLimits(&limits, *c.Limits)
+		}
+	}
+	if override.Memory != "" {
+		limits.Memory = override.Memory
+	}
+	if override.CPUs != "" {
+		limits.CPUs = override.CPUs
+	}
+	if override.PidsLimit != 0 {
+		limits.PidsLimit = override.PidsLimit
+	}
+	if override.ReadOnlyRootFilesystem != nil {
+		limits.ReadOnlyRootFilesystem = override.ReadOnlyRootFilesystem
+	}
+	return limits
+}
+
+// capLimits lowers the limits of l to the limits which are set in max. The
+// limits must be valid.
+func capLimits(l *kptfilev1.FunctionLimits, max kptfilev1.FunctionLimits) {
+	if max.Memory != "" && (l.Memory == "" || memoryBytes(max.Memory) < memoryBytes(l.Memory)) {
+		l.Memory = max.Memory
+	}
+	if max.CPUs != "" && (l.CPUs == "" || parseCPUs(max.CPUs) < parseCPUs(l.CPUs)) {
+		l.CPUs = max.CPUs
+	}
+	// a pids limit of -1 is no limit
+	if max.PidsLimit > 0 && (l.PidsLimit <= 0 || max.PidsLimit < l.PidsLimit) {
+		l.PidsLimit = max.PidsLimit
+	}
+	if max.ReadOnlyRootFilesystem != nil && *max.ReadOnlyRootFilesystem {
+		l.ReadOnlyRootFilesystem = max.ReadOnlyRootFilesystem
+	}
+}
+
+// memoryBytes returns the number of bytes of a memory limit in the format
+// of docker, e.g. 512m.
+func memoryBytes(memory string) int64 {
+	unit := int64(1)
+	switch strings.ToLower(memory[len(memory)-1:]) {
+	case "b":
+		memory = memory[:len(memory)-1]
+	case "k":
+		unit, memory = 1<<10, memory[:len(memory)-1]
+	case "m":
+		unit, memory = 1<<20, memory[:len(memory)-1]
+	case "g":
+		unit, memory = 1<<30, memory[:len(memory)-1]
+	}
+	n, _ := strconv.ParseInt(memory, 10, 64)
+	return n * unit
+}
+
+// parseCPUs returns the number of CPUs of a CPU limit.
+func parseCPUs(cpus string) float64 {
+	n, _ := strconv.ParseFloat(cpus, 64)
+	return n
+}
+
+// CheckImage returns an error if any of the configs doesn't allow the
+// given function image to run. A package can't loosen the restrictions of
+// the user config by adding a config to its repository, since the image
+// must be allowed by all configs. The image is checked before it is
+// rewritten. If it is rewritten, the rewritten image must also be allowed by
+// the configs which take precedence over the config of the rewrite rule, so
+// the mirrors of a config don't need to be in its own allowed images, but a
+// repository config can't redirect images to a registry the user doesn't
+// allow.
+func (cs RuntimeConfigs) CheckImage(image string) error {
+	if err := checkImage(cs, image); err != nil {
+		return err
+	}
+	if rewritten, i := cs.rewriteImage(image); i > 0 {
+		return checkImage(cs[:i], rewritten)
+	}
+	return nil
+}
+
+func checkImage(cs RuntimeConfigs, image string) error {
+	for _, c := range cs {
+		if c.RequireDigests && !HasImageDigest(image) {
+			return &ImageNotAllowedError{
+				Image:  image,
+				Path:   c.path,
+				Reason: "image must be pinned to a digest",
+			}
+		}
+		if len(c.AllowedImages) != 0 && !matchesAny(image, c.AllowedImages) {
+			return &ImageNotAllowedError{
+				Image:  image,
+				Path:   c.path,
+				Reason: "image is not in the list of allowed images",
+			}
+		}
+	}
+	return nil
+}
+
+// ImageNotAllowedError is returned when a function runtime config doesn't
+// allow a function image to run.
+type ImageNotAllowedError struct {
+	Image  string
+	Path   string
+	Reason string
+}
+
+func (e *ImageNotAllowedError) Error() string {
+	return fmt.Sprintf("function image %q is not allowed by %q: %s", e.Image, e.Path, e.Reason)
+}
+
+func matchesAny(image string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(image, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+			continue
+		}
+		if image == p {
+			return true
+		}
+	}
+	return false
+}
+
+// userRuntimeConfigPath returns the path to the user level config, and
+// whether it was set explicitly.
+func userRuntimeConfigPath() (string, bool, error) {
+	if p := os.Getenv(RuntimeConfigEnv); p != "" {
+		return p, true, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", false, fmt.Errorf("error looking up user home dir: %w", err)
+	}
+	return filepath.Join(dir, ".kpt", RuntimeConfigFileName), false, nil
+}
+
+// readRuntimeConfig reads the config at path. It returns nil if the file
+// doesn't exist and isn't required.
+func readRuntimeConfig(path string, required bool) (*RuntimeConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if goerrors.Is(err, os.ErrNotExist) && !required {
+			return nil, nil
+		}
+		return nil, errors.E(errors.IO, types.UniquePath(path), err)
+	}
+	c := &RuntimeConfig{}
+	d := yaml.NewDecoder(bytes.NewBuffer(b))
+	d.KnownFields(true)
+	if err := d.Decode(c); err != nil {
+		return nil, fmt.Errorf("invalid function runtime config %q: %w", path, err)
+	}
+	if c.APIVersion != kptfilev1.KptFileAPIVersion || c.Kind != RuntimeConfigKind {
+		return nil, fmt.Errorf("invalid function runtime config %q: expected %s %s", path,
+			kptfilev1.KptFileAPIVersion, RuntimeConfigKind)
+	}
+	for _, rw := range c.ImageRewrites {
+		if rw.From == "" || rw.To == "" {
+			return nil, fmt.Errorf("invalid function runtime config %q: image rewrites must specify `from` and `to`", path)
+		}
+	}
+	if c.Limits != nil {
+		if err := kptfilev1.ValidateFunctionLimits(*c.Limits); err != nil {
+			return nil, fmt.Errorf("invalid function runtime config %q: %w", path, err)
+		}
+	}
+	c.path = path
+	return c, nil
+}
+
+// findRepoRoot returns the root directory of the git repository which
+// contains path.
+func findRepoRoot(path string) (string, bool) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeConfigsCheckImage(t *testing.T) {
+	testCases := map[string]struct {
+		configs RuntimeConfigs
+		image   string
+		errMsg  string
+	}{
+		"no configs": {
+			image: "example.com/fn:v1",
+		},
+		"prefix match": {
+			configs: RuntimeConfigs{{AllowedImages: []string{"gcr.io/kpt-fn/*"}}},
+			image:   "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"exact match": {
+			configs: RuntimeConfigs{{AllowedImages: []string{"example.com/fn:v1"}}},
+			image:   "example.com/fn:v1",
+		},
+		"not allowed": {
+			configs: RuntimeConfigs{{AllowedImages: []string{"gcr.io/kpt-fn/*"}, path: "fn-config.yaml"}},
+			image:   "example.com/fn:v1",
+			errMsg:  `function image "example.com/fn:v1" is not allowed by "fn-config.yaml": image is not in the list of allowed images`,
+		},
+		"must be allowed by all configs": {
+			configs: RuntimeConfigs{
+				{AllowedImages: []string{"gcr.io/kpt-fn/*", "example.com/*"}},
+				{AllowedImages: []string{"gcr.io/kpt-fn/*"}},
+			},
+			image:  "example.com/fn:v1",
+			errMsg: "image is not in the list of allowed images",
+		},
+		"digest required": {
+			configs: RuntimeConfigs{{RequireDigests: true}},
+			image:   "gcr.io/kpt-fn/set-labels:v0.1",
+			errMsg:  "image must be pinned to a digest",
+		},
+		"digest present": {
+			configs: RuntimeConfigs{{RequireDigests: true}},
+			image:   "gcr.io/kpt-fn/set-labels" + testDigest,
+		},
+		"image is checked before rewriting": {
+			configs: RuntimeConfigs{{
+				AllowedImages: []string{"gcr.io/kpt-fn/*"},
+				ImageRewrites: []ImageRewrite{{From: "gcr.io/kpt-fn/", To: "mirror.example.com/kpt-fn/"}},
+			}},
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"rewriting doesn't allow an image": {
+			configs: RuntimeConfigs{{
+				AllowedImages: []string{"gcr.io/kpt-fn/*"},
+				ImageRewrites: []ImageRewrite{{From: "example.com/", To: "gcr.io/kpt-fn/"}},
+			}},
+			image:  "example.com/fn:v1",
+			errMsg: `function image "example.com/fn:v1" is not allowed`,
+		},
+		"user mirror is trusted": {
+			configs: RuntimeConfigs{
+				{ImageRewrites: []ImageRewrite{{From: "gcr.io/kpt-fn/", To: "mirror.example.com/kpt-fn/"}}},
+				{AllowedImages: []string{"gcr.io/kpt-fn/*"}},
+			},
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"repository mirror must be allowed by user config": {
+			configs: RuntimeConfigs{
+				{AllowedImages: []string{"gcr.io/kpt-fn/*"}, path: "user.yaml"},
+				{ImageRewrites: []ImageRewrite{{From: "gcr.io/kpt-fn/", To: "mirror.example.com/kpt-fn/"}}},
+			},
+			image:  "gcr.io/kpt-fn/set-labels:v0.1",
+			errMsg: `function image "mirror.example.com/kpt-fn/set-labels:v0.1" is not allowed by "user.yaml"`,
+		},
+		"repository mirror allowed by user config": {
+			configs: RuntimeConfigs{
+				{AllowedImages: []string{"gcr.io/kpt-fn/*", "mirror.example.com/*"}},
+				{ImageRewrites: []ImageRewrite{{From: "gcr.io/kpt-fn/", To: "mirror.example.com/kpt-fn/"}}},
+			},
+			image: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			err := tc.configs.CheckImage(tc.image)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestRuntimeConfigsImages(t *testing.T) {
+	testCases := map[string]struct {
+		configs  RuntimeConfigs
+		image    string
+		expected string
+	}{
+		"default prefix": {
+			image:    "set-labels:v0.1",
+			expected: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+		"configured prefix": {
+			configs:  RuntimeConfigs{{DefaultImagePrefix: "registry.example.com/fns"}},
+			image:    "set-labels:v0.1",
+			expected: "registry.example.com/fns/set-labels:v0.1",
+		},
+		"user prefix takes precedence": {
+			configs: RuntimeConfigs{
+				{DefaultImagePrefix: "registry.example.com/fns/"},
+				{DefaultImagePrefix: "registry.example.com/repo-fns/"},
+			},
+			image:    "set-labels:v0.1",
+			expected: "registry.example.com/fns/set-labels:v0.1",
+		},
+		"repository prefix applies without user prefix": {
+			configs: RuntimeConfigs{
+				{AllowedImages: []string{"registry.example.com/*"}},
+				{DefaultImagePrefix: "registry.example.com/repo-fns/"},
+			},
+			image:    "set-labels:v0.1",
+			expected: "registry.example.com/repo-fns/set-labels:v0.1",
+		},
+		"rewrite": {
+			configs: RuntimeConfigs{{ImageRewrites: []ImageRewrite{
+				{From: "gcr.io/kpt-fn/", To: "registry.example.com/kpt-fn/"},
+			}}},
+			image:    "set-labels" + testDigest,
+			expected: "registry.example.com/kpt-fn/set-labels" + testDigest,
+		},
+		"user rewrite takes precedence": {
+			configs: RuntimeConfigs{
+				{ImageRewrites: []ImageRewrite{{From: "gcr.io/", To: "mirror.example.com/"}}},
+				{ImageRewrites: []ImageRewrite{{From: "gcr.io/kpt-fn/", To: "registry.example.com/kpt-fn/"}}},
+			},
+			image:    "gcr.io/kpt-fn/set-labels:v0.1",
+			expected: "mirror.example.com/kpt-fn/set-labels:v0.1",
+		},
+		"no matching rewrite": {
+			configs: RuntimeConfigs{{ImageRewrites: []ImageRewrite{
+				{From: "docker.io/", To: "registry.example.com/"},
+			}}},
+			image:    "gcr.io/kpt-fn/set-labels:v0.1",
+			expected: "gcr.io/kpt-fn/set-labels:v0.1",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			image := tc.configs.RewriteImage(tc.configs.AddDefaultImagePathPrefix(tc.image))
+			assert.Equal(t, tc.expected, image)
+		})
+	}
+}
+
+func TestRuntimeConfigsFunctionLimits(t *testing.T) {
+	testCases := map[string]struct {
+		configs  RuntimeConfigs
+		fnLimits *kptfilev1.FunctionLimits
+		override kptfilev1.FunctionLimits
+		expected kptfilev1.FunctionLimits
+		args     []string
+	}{
+		"no limits": {},
+		"config defaults": {
+			configs: RuntimeConfigs{
+				{Limits: &kptfilev1.FunctionLimits{Memory: "512m", CPUs: "1"}},
+				{Limits: &kptfilev1.FunctionLimits{Memory: "256m"}},
+			},
+			expected: kptfilev1.FunctionLimits{Memory: "256m", CPUs: "1"},
+			args:     []string{"--memory", "256m", "--cpus", "1"},
+		},
+		"function limits below the caps": {
+			configs:  RuntimeConfigs{{Limits: &kptfilev1.FunctionLimits{Memory: "1g", CPUs: "2", PidsLimit: 200}}},
+			fnLimits: &kptfilev1.FunctionLimits{Memory: "256m", CPUs: "0.5", PidsLimit: 100},
+			expected: kptfilev1.FunctionLimits{Memory: "256m", CPUs: "0.5", PidsLimit: 100},
+			args:     []string{"--memory", "256m", "--cpus", "0.5", "--pids-limit", "100"},
+		},
+		"function limits can't raise the caps": {
+			configs: RuntimeConfigs{
+				{Limits: &kptfilev1.FunctionLimits{Memory: "256m", ReadOnlyRootFilesystem: boolPtr(true)}},
+				{Limits: &kptfilev1.FunctionLimits{CPUs: "1", PidsLimit: 100}},
+			},
+			fnLimits: &kptfilev1.FunctionLimits{Memory: "1G", CPUs: "4", PidsLimit: -1, ReadOnlyRootFilesystem: boolPtr(false)},
+			expected: kptfilev1.FunctionLimits{Memory: "256m", CPUs: "1", PidsLimit: 100, ReadOnlyRootFilesystem: boolPtr(true)},
+			args:     []string{"--memory", "256m", "--cpus", "1", "--pids-limit", "100", "--read-only", "--tmpfs", "/tmp"},
+		},
+		"function requires read-only root filesystem": {
+			configs:  RuntimeConfigs{{Limits: &kptfilev1.FunctionLimits{PidsLimit: -1}}},
+			fnLimits: &kptfilev1.FunctionLimits{PidsLimit: 100, ReadOnlyRootFilesystem: boolPtr(true)},
+			expected: kptfilev1.FunctionLimits{PidsLimit: 100, ReadOnlyRootFilesystem: boolPtr(true)},
+			args:     []string{"--pids-limit", "100", "--read-only", "--tmpfs", "/tmp"},
+		},
+		"override takes precedence": {
+			configs: RuntimeConfigs{{Limits: &kptfilev1.FunctionLimits{
+				CPUs: "1", PidsLimit: 100, ReadOnlyRootFilesystem: boolPtr(true),
+			}}},
+			fnLimits: &kptfilev1.FunctionLimits{CPUs: "0.5"},
+			override: kptfilev1.FunctionLimits{CPUs: "2", PidsLimit: -1, ReadOnlyRootFilesystem: boolPtr(false)},
+			expected: kptfilev1.FunctionLimits{CPUs: "2", PidsLimit: -1, ReadOnlyRootFilesystem: boolPtr(false)},
+			args:     []string{"--cpus", "2", "--pids-limit", "-1"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			limits := tc.configs.FunctionLimits(tc.fnLimits, tc.override)
+			assert.Equal(t, tc.expected, limits)
+			assert.Equal(t, tc.args, limitArgs(limits))
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestLoadRuntimeConfigs(t *testing.T) {
+	dir := t.TempDir()
+	userConfig := filepath.Join(dir, "user-fn-config.yaml")
+	writeFile(t, userConfig, `apiVersion: kpt.dev/v1
+kind: FunctionRuntimeConfig
+allowedImages:
+- gcr.io/kpt-fn/*
+`)
+	repo := filepath.Join(dir, "repo")
+	writeFile(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/main\n")
+	writeFile(t, filepath.Join(repo, ".kpt", RuntimeConfigFileName), `apiVersion: kpt.dev/v1
+kind: FunctionRuntimeConfig
+requireDigests: true
+`)
+	pkgPath := filepath.Join(repo, "pkgs", "foo")
+	if !assert.NoError(t, os.MkdirAll(pkgPath, 0700)) {
+		t.FailNow()
+	}
+
+	defer os.Setenv(RuntimeConfigEnv, os.Getenv(RuntimeConfigEnv))
+	if !assert.NoError(t, os.Setenv(RuntimeConfigEnv, userConfig)) {
+		t.FailNow()
+	}
+	configs, err := LoadRuntimeConfigs(pkgPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, configs, 2) {
+		assert.Equal(t, []string{"gcr.io/kpt-fn/*"}, configs[0].AllowedImages)
+		assert.True(t, configs[1].RequireDigests)
+	}
+
+	// an explicitly configured user config must exist
+	if !assert.NoError(t, os.Setenv(RuntimeConfigEnv, filepath.Join(dir, "missing.yaml"))) {
+		t.FailNow()
+	}
+	_, err = LoadRuntimeConfigs(pkgPath)
+	assert.Error(t, err)
+
+	// unknown fields are rejected
+	writeFile(t, userConfig, `apiVersion: kpt.dev/v1
+kind: FunctionRuntimeConfig
+allowImages:
+- gcr.io/kpt-fn/*
+`)
+	if !assert.NoError(t, os.Setenv(RuntimeConfigEnv, userConfig)) {
+		t.FailNow()
+	}
+	_, err = LoadRuntimeConfigs(pkgPath)
+	assert.Error(t, err)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+		t.FailNow()
+	}
+}
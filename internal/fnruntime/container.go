@@ -21,12 +21,14 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 )
 
@@ -76,6 +78,8 @@ type ContainerFn struct {
 	StorageMounts []runtimeutil.StorageMount
 	// Env is a slice of env string that will be exposed to container
 	Env []string
+	// Limits restricts the resources available to the container.
+	Limits kptfilev1.FunctionLimits
 	// FnResult is used to store the information about the result from
 	// the function.
 	FnResult *fnresult.Result
@@ -138,6 +142,7 @@ func (f *ContainerFn) getDockerCmd() (*exec.Cmd, context.CancelFunc) {
 	if f.ImagePullPolicy == NeverPull {
 		args = append(args, "--pull", "never")
 	}
+	args = append(args, limitArgs(f.Limits)...)
 	for _, storageMount := range f.StorageMounts {
 		args = append(args, "--mount", storageMount.String())
 	}
@@ -153,6 +158,25 @@ func (f *ContainerFn) getDockerCmd() (*exec.Cmd, context.CancelFunc) {
 	return exec.CommandContext(ctx, dockerBin, args...), cancel
 }
 
+// limitArgs returns the docker run flags for the given limits.
+func limitArgs(l kptfilev1.FunctionLimits) []string {
+	var args []string
+	if l.Memory != "" {
+		args = append(args, "--memory", l.Memory)
+	}
+	if l.CPUs != "" {
+		args = append(args, "--cpus", l.CPUs)
+	}
+	if l.PidsLimit != 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(l.PidsLimit))
+	}
+	if l.ReadOnlyRootFilesystem != nil && *l.ReadOnlyRootFilesystem {
+		// functions may still need a scratch directory
+		args = append(args, "--read-only", "--tmpfs", "/tmp")
+	}
+	return args
+}
+
 // NewContainerEnvFromStringSlice returns a new ContainerEnv pointer with parsing
 // input envStr. envStr example: ["foo=bar", "baz"]
 // using this instead of runtimeutil.NewContainerEnvFromStringSlice() to avoid
@@ -203,7 +227,11 @@ func (f *ContainerFn) prepareImage() error {
 	if f.Timeout != 0 {
 		timeout = f.Timeout
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	parent := f.Ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
 	defer cancel()
 	cmd := exec.CommandContext(ctx, dockerBin, args...)
 	output, err := cmd.CombinedOutput()
@@ -230,12 +258,13 @@ func (f *ContainerFn) checkImageExistence() bool {
 	return false
 }
 
+// defaultImagePathPrefix is the path prefix for function images which are
+// only specified by name, unless a function runtime config overrides it.
+const defaultImagePathPrefix = "gcr.io/kpt-fn/"
+
 // AddDefaultImagePathPrefix adds default gcr.io/kpt-fn/ path prefix to image if only image name is specified
 func AddDefaultImagePathPrefix(image string) string {
-	if !strings.Contains(image, "/") {
-		return fmt.Sprintf("gcr.io/kpt-fn/%s", image)
-	}
-	return image
+	return RuntimeConfigs(nil).AddDefaultImagePathPrefix(image)
 }
 
 // ContainerImageError is an error type which will be returned when
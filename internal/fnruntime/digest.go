@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const digestSeparator = "@sha256:"
+
+// HasImageDigest returns true if the image is referenced by digest,
+// e.g. gcr.io/kpt-fn/set-labels@sha256:<digest>.
+func HasImageDigest(image string) bool {
+	return strings.Contains(image, digestSeparator)
+}
+
+// ResolveImageDigest pulls the given image using the container engine and
+// returns a reference to the image pinned by digest, which keeps the tag of
+// the given image so the version stays readable,
+// e.g. gcr.io/kpt-fn/set-labels:v0.1@sha256:<digest>. The image is pulled
+// after applying the image rewrite rules of configs, but the returned
+// reference keeps the repository of the given image.
+func ResolveImageDigest(ctx context.Context, image string, configs RuntimeConfigs) (string, error) {
+	if HasImageDigest(image) {
+		return image, nil
+	}
+	pullImage := configs.RewriteImage(image)
+	cfn := &ContainerFn{
+		Ctx:             ctx,
+		Image:           pullImage,
+		ImagePullPolicy: AlwaysPull,
+	}
+	if err := cfn.prepareImage(); err != nil {
+		return "", err
+	}
+
+	args := []string{"image", "inspect", "--format", `{{join .RepoDigests "\n"}}`, pullImage}
+	ctx, cancel := context.WithTimeout(ctx, defaultShortTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, dockerBin, args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %q: %s", pullImage, strings.TrimSpace(string(out)))
+	}
+	pinned, err := pinnedImage(pullImage, strings.Fields(string(out)))
+	if err != nil {
+		return "", err
+	}
+	return image + strings.TrimPrefix(pinned, imageRepository(pullImage)), nil
+}
+
+// pinnedImage returns the entry of repoDigests which belongs to the
+// repository of image.
+func pinnedImage(image string, repoDigests []string) (string, error) {
+	repo := imageRepository(image)
+	for _, rd := range repoDigests {
+		if strings.HasPrefix(rd, repo+digestSeparator) {
+			return rd, nil
+		}
+	}
+	return "", fmt.Errorf("image %q has no digest for repository %q", image, repo)
+}
+
+// imageRepository returns the image without tag or digest.
+func imageRepository(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
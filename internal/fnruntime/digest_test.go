@@ -0,0 +1,76 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testDigest = "@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestPinnedImage(t *testing.T) {
+	testCases := map[string]struct {
+		image       string
+		repoDigests []string
+		expected    string
+		errMsg      string
+	}{
+		"tag": {
+			image:       "gcr.io/kpt-fn/set-labels:v0.1",
+			repoDigests: []string{"gcr.io/kpt-fn/set-labels" + testDigest},
+			expected:    "gcr.io/kpt-fn/set-labels" + testDigest,
+		},
+		"no tag": {
+			image:       "gcr.io/kpt-fn/set-labels",
+			repoDigests: []string{"gcr.io/kpt-fn/set-labels" + testDigest},
+			expected:    "gcr.io/kpt-fn/set-labels" + testDigest,
+		},
+		"registry with port": {
+			image:       "localhost:5000/set-labels:v0.1",
+			repoDigests: []string{"localhost:5000/set-labels" + testDigest},
+			expected:    "localhost:5000/set-labels" + testDigest,
+		},
+		"multiple repositories": {
+			image: "example.com/fns/set-labels:v0.1",
+			repoDigests: []string{
+				"gcr.io/kpt-fn/set-labels" + testDigest,
+				"example.com/fns/set-labels" + testDigest,
+			},
+			expected: "example.com/fns/set-labels" + testDigest,
+		},
+		"no digest": {
+			image:  "gcr.io/kpt-fn/set-labels:v0.1",
+			errMsg: `has no digest for repository "gcr.io/kpt-fn/set-labels"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			pinned, err := pinnedImage(tc.image, tc.repoDigests)
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, pinned)
+			assert.True(t, HasImageDigest(pinned))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+)
+
+// DefaultPullConcurrency is the number of images which are pulled
+// concurrently by PullImages.
+const DefaultPullConcurrency = 4
+
+// PullImages pulls the given function images according to the image pull
+// policy, pulling up to concurrency images at the same time. It returns
+// the first error encountered, and images which haven't started pulling at
+// that point are not pulled.
+func PullImages(ctx context.Context, images []string, policy ImagePullPolicy, concurrency int) error {
+	if policy == NeverPull || len(images) == 0 {
+		return nil
+	}
+	if concurrency < 1 {
+		concurrency = DefaultPullConcurrency
+	}
+	pr := printer.FromContextOrDie(ctx)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	queue := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for image := range queue {
+				if ctx.Err() != nil {
+					// another image failed to pull
+					continue
+				}
+				pr.Printf("[PULLING] %q\n", image)
+				cfn := &ContainerFn{
+					Ctx:             ctx,
+					Image:           image,
+					ImagePullPolicy: policy,
+				}
+				if err := cfn.prepareImage(); err != nil {
+					pr.Printf("[FAIL] %q\n", image)
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				pr.Printf("[PULLED] %q\n", image)
+			}
+		}()
+	}
+loop:
+	for _, image := range images {
+		select {
+		case queue <- image:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
@@ -37,27 +37,58 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// RunnerOptions contains the options for running container functions.
+type RunnerOptions struct {
+	// ImagePullPolicy controls the image pulling behavior.
+	ImagePullPolicy ImagePullPolicy
+
+	// RuntimeConfigs are used to rewrite the function image before it is run,
+	// and provide the default resource limits.
+	RuntimeConfigs RuntimeConfigs
+
+	// Limits override the resource limits of the function.
+	Limits kptfilev1.FunctionLimits
+
+	// AllowEnv allows functions to pass through environment variables of the
+	// kpt process.
+	AllowEnv bool
+}
+
 // NewContainerRunner returns a kio.Filter given a specification of a container function
-// and it's config.
+// and it's config. The config must have been validated with ValidateFnConfig.
 func NewContainerRunner(
 	ctx context.Context, f *kptfilev1.Function,
 	pkgPath types.UniquePath, fnResults *fnresult.ResultList,
-	imagePullPolicy ImagePullPolicy) (kio.Filter, error) {
+	opts RunnerOptions) (kio.Filter, error) {
 	config, err := newFnConfig(f, pkgPath)
 	if err != nil {
 		return nil, err
 	}
 
+	if !opts.AllowEnv {
+		for _, e := range f.Env {
+			if !strings.Contains(e, "=") {
+				return nil, fmt.Errorf("function %q passes through environment variable %q, which requires --allow-env", f.Image, e)
+			}
+		}
+	}
+
 	fnResult := &fnresult.Result{
 		Image: f.Image,
 		// TODO(droot): This is required for making structured results subpackage aware.
 		// Enable this once test harness supports filepath based assertions.
 		// Pkg: string(pkgPath),
 	}
+	image := opts.RuntimeConfigs.RewriteImage(f.Image)
+	if image != f.Image {
+		fnResult.RewrittenImage = image
+	}
 	cfn := &ContainerFn{
 		Path:            pkgPath,
-		Image:           f.Image,
-		ImagePullPolicy: imagePullPolicy,
+		Image:           image,
+		ImagePullPolicy: opts.ImagePullPolicy,
+		Limits:          opts.RuntimeConfigs.FunctionLimits(f.Limits, opts.Limits),
+		Env:             f.Env,
 		Ctx:             ctx,
 		FnResult:        fnResult,
 	}
@@ -80,6 +111,9 @@ func NewFunctionRunner(ctx context.Context,
 	if name == "" {
 		name = fnResult.ExecPath
 	}
+	if name == "" {
+		name = fnResult.Starlark
+	}
 	return &FunctionRunner{
 		ctx:                  ctx,
 		name:                 name,
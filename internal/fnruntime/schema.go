@@ -0,0 +1,192 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ConfigSchemaLabel is the image label in which a function can publish the
+// OpenAPI schema of its function config, in YAML or JSON format.
+const ConfigSchemaLabel = "dev.kpt.fn.config-schema"
+
+// ConfigSchema is the OpenAPI schema of a function config.
+type ConfigSchema struct {
+	props apiextensions.JSONSchemaProps
+}
+
+// ParseConfigSchema parses an OpenAPI schema in YAML or JSON format.
+func ParseConfigSchema(b []byte) (*ConfigSchema, error) {
+	node, err := yaml.Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("invalid function config schema: %w", err)
+	}
+	j, err := node.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("invalid function config schema: %w", err)
+	}
+	var props apiextensionsv1.JSONSchemaProps
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&props); err != nil {
+		return nil, fmt.Errorf("invalid function config schema: %w", err)
+	}
+	s := &ConfigSchema{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(&props, &s.props, nil); err != nil {
+		return nil, fmt.Errorf("invalid function config schema: %w", err)
+	}
+	return s, nil
+}
+
+// ReadConfigSchema reads the function config schema at path.
+func ReadConfigSchema(path string) (*ConfigSchema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.E(errors.IO, types.UniquePath(path), err)
+	}
+	return ParseConfigSchema(b)
+}
+
+// ImageConfigSchema returns the function config schema published in the
+// ConfigSchemaLabel of a local image. It returns nil if the image doesn't
+// publish a schema, or if the image or the container engine isn't available
+// locally.
+func ImageConfigSchema(ctx context.Context, image string) (*ConfigSchema, error) {
+	cmd := exec.CommandContext(ctx, dockerBin, "image", "inspect", "--format",
+		fmt.Sprintf("{{ index .Config.Labels %q }}", ConfigSchemaLabel), image)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		if goerrors.Is(err, exec.ErrNotFound) || strings.Contains(errOut.String(), "No such") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error inspecting image %q: %w: %s", image, err, strings.TrimSpace(errOut.String()))
+	}
+	label := strings.TrimSpace(out.String())
+	if label == "" || label == "<no value>" {
+		return nil, nil
+	}
+	return ParseConfigSchema([]byte(label))
+}
+
+// ValidateFnConfig validates the config of a function of the package at
+// pkgPath against the schema in the configSchemaPath of the function, or
+// else against the schema published by image. The published schema isn't
+// looked up if image is empty. Functions without a config aren't validated.
+func ValidateFnConfig(ctx context.Context, f *kptfilev1.Function, pkgPath types.UniquePath, image string) error {
+	const op errors.Op = "fn.validateConfig"
+	config, err := newFnConfig(f, pkgPath)
+	if err != nil {
+		return err
+	}
+	var schema *ConfigSchema
+	if f.ConfigSchemaPath != "" {
+		if schema, err = ReadConfigSchema(filepath.Join(string(pkgPath), f.ConfigSchemaPath)); err != nil {
+			return errors.E(op, errors.Fn(f.Image), err)
+		}
+	}
+	if err := validateConfig(ctx, image, config, schema); err != nil {
+		return errors.E(op, errors.Fn(f.Image), err)
+	}
+	return nil
+}
+
+// ValidateImageFnConfig validates the config of a function which isn't
+// declared in a Kptfile, e.g. a function run with fn eval, against the
+// schema published by image.
+func ValidateImageFnConfig(ctx context.Context, image string, config *yaml.RNode) error {
+	const op errors.Op = "fn.validateConfig"
+	if err := validateConfig(ctx, image, config, nil); err != nil {
+		return errors.E(op, errors.Fn(image), err)
+	}
+	return nil
+}
+
+// validateConfig validates config against schema, or against the schema
+// published by image if schema is nil and image isn't empty.
+func validateConfig(ctx context.Context, image string, config *yaml.RNode, schema *ConfigSchema) error {
+	if config == nil {
+		return nil
+	}
+	if schema == nil {
+		if image == "" {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, defaultShortTimeout)
+		defer cancel()
+		var err error
+		if schema, err = ImageConfigSchema(ctx, image); err != nil || schema == nil {
+			return err
+		}
+	}
+	return schema.Validate(config)
+}
+
+// Validate validates the function config against the schema. It returns a
+// *FnConfigValidationError listing the invalid fields.
+func (s *ConfigSchema) Validate(config *yaml.RNode) error {
+	validator, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{
+		OpenAPIV3Schema: &s.props,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid function config schema: %w", err)
+	}
+	b, err := config.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var obj interface{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	errs := validation.ValidateCustomResource(nil, obj, validator)
+	if len(errs) == 0 {
+		return nil
+	}
+	verr := &FnConfigValidationError{}
+	for _, e := range errs {
+		verr.Errors = append(verr.Errors, e.Error())
+	}
+	return verr
+}
+
+// FnConfigValidationError is returned if a function config doesn't match the
+// schema of the function.
+type FnConfigValidationError struct {
+	// Errors are the field-path errors of the invalid fields.
+	Errors []string
+}
+
+func (e *FnConfigValidationError) Error() string {
+	return fmt.Sprintf("function config doesn't match the function config schema:\n  %s",
+		strings.Join(e.Errors, "\n  "))
+}
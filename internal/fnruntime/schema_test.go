@@ -0,0 +1,150 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const testConfigSchema = `type: object
+properties:
+  data:
+    type: object
+    properties:
+      replicas:
+        type: integer
+      tier:
+        type: string
+        enum: [frontend, backend]
+    required: [tier]
+`
+
+func TestConfigSchemaValidate(t *testing.T) {
+	schema, err := ParseConfigSchema([]byte(testConfigSchema))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	testCases := map[string]struct {
+		config string
+		errs   []string
+	}{
+		"valid": {
+			config: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  replicas: 3
+  tier: backend
+`,
+		},
+		"invalid fields": {
+			config: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  replicas: three
+  tier: database
+`,
+			errs: []string{
+				`data.replicas: Invalid value: "string": data.replicas in body must be of type integer: "string"`,
+				`data.tier: Unsupported value: "database": supported values: "frontend", "backend"`,
+			},
+		},
+		"missing field": {
+			config: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  replicas: 3
+`,
+			errs: []string{`data.tier: Required value`},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			err := schema.Validate(yaml.MustParse(tc.config))
+			if len(tc.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var verr *FnConfigValidationError
+			if assert.ErrorAs(t, err, &verr) {
+				assert.ElementsMatch(t, tc.errs, verr.Errors)
+			}
+		})
+	}
+}
+
+func TestParseConfigSchema(t *testing.T) {
+	_, err := ParseConfigSchema([]byte(`{"type": "object", "properties": {"data": {"type": "object"}}}`))
+	assert.NoError(t, err)
+
+	_, err = ParseConfigSchema([]byte("type: object\nproperty: {}\n"))
+	assert.Error(t, err)
+}
+
+func TestValidateImageFnConfig(t *testing.T) {
+	config := yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  tier: database
+`)
+	// images which aren't available locally don't publish a schema
+	assert.NoError(t, ValidateImageFnConfig(context.Background(), "example.com/kpt-fn/no-such-image:v0.1", config))
+
+	// functions without a config are not validated
+	assert.NoError(t, ValidateImageFnConfig(context.Background(), "gcr.io/kpt-fn/set-labels:v0.1", nil))
+}
+
+func TestValidateFnConfig(t *testing.T) {
+	dir := t.TempDir()
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "schema.yaml"), []byte(testConfigSchema), 0600)) {
+		t.FailNow()
+	}
+	fn := &kptfilev1.Function{
+		Image:            "gcr.io/kpt-fn/set-labels:v0.1",
+		ConfigMap:        map[string]string{"tier": "database"},
+		ConfigSchemaPath: "schema.yaml",
+	}
+	err := ValidateFnConfig(context.Background(), fn, types.UniquePath(dir), "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "fn gcr.io/kpt-fn/set-labels:v0.1")
+		assert.Contains(t, err.Error(), `data.tier: Unsupported value: "database"`)
+	}
+
+	fn.ConfigMap["tier"] = "backend"
+	assert.NoError(t, ValidateFnConfig(context.Background(), fn, types.UniquePath(dir), ""))
+
+	// without a schema or an image publishing one, the config isn't validated
+	fn.ConfigMap["tier"] = "database"
+	fn.ConfigSchemaPath = ""
+	assert.NoError(t, ValidateFnConfig(context.Background(), fn, types.UniquePath(dir), ""))
+}
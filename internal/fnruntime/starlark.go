@@ -0,0 +1,71 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/starlark"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// inlineStarlarkName is the name used in results and output for
+// Starlark scripts specified inline in the Kptfile.
+const inlineStarlarkName = "inline"
+
+// NewStarlarkRunner returns a kio.Filter which runs the Starlark script of
+// the given function in-process.
+func NewStarlarkRunner(
+	ctx context.Context, f *kptfilev1.Function,
+	pkgPath types.UniquePath, fnResults *fnresult.ResultList) (kio.Filter, error) {
+	const op errors.Op = "fn.starlark"
+
+	name := inlineStarlarkName
+	program := f.Starlark.Source
+	if f.Starlark.Path != "" {
+		name = f.Starlark.Path
+		b, err := ioutil.ReadFile(filepath.Join(string(pkgPath), filepath.FromSlash(f.Starlark.Path)))
+		if err != nil {
+			return nil, errors.E(op, errors.Fn(name),
+				fmt.Errorf("missing starlark script %q", f.Starlark.Path))
+		}
+		program = string(b)
+	}
+	config, err := newFnConfig(f, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+
+	fnResult := &fnresult.Result{
+		Starlark: name,
+	}
+	sf := &starlark.Filter{
+		Name:    name,
+		Program: program,
+	}
+	fltr := &runtimeutil.FunctionFilter{
+		Run:            sf.Run,
+		FunctionConfig: config,
+	}
+	return NewFunctionRunner(ctx, fltr, pkgPath, fnResult, fnResults, true)
+}
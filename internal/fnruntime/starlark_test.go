@@ -0,0 +1,116 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fnruntime
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	fnresult "github.com/GoogleContainerTools/kpt/pkg/api/fnresult/v1"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const setReplicasScript = `
+def set_replicas(resources, replicas):
+  for r in resources:
+    if r["kind"] == "Deployment":
+      r["spec"]["replicas"] = replicas
+
+set_replicas(ctx.resource_list["items"], int(ctx.resource_list["functionConfig"]["data"]["replicas"]))
+`
+
+func TestStarlarkRunner(t *testing.T) {
+	testCases := map[string]struct {
+		starlark     kptfilev1.StarlarkScript
+		scriptFile   string
+		expectedName string
+		errMsg       string
+	}{
+		"inline script": {
+			starlark:     kptfilev1.StarlarkScript{Source: setReplicasScript},
+			expectedName: "inline",
+		},
+		"script file": {
+			starlark:     kptfilev1.StarlarkScript{Path: "fn/replicas.star"},
+			scriptFile:   "fn/replicas.star",
+			expectedName: "fn/replicas.star",
+		},
+		"missing script file": {
+			starlark: kptfilev1.StarlarkScript{Path: "replicas.star"},
+			errMsg:   `missing starlark script "replicas.star"`,
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.scriptFile != "" {
+				p := filepath.Join(dir, filepath.FromSlash(tc.scriptFile))
+				assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+				assert.NoError(t, ioutil.WriteFile(p, []byte(setReplicasScript), 0600))
+			}
+			starlark := tc.starlark
+			fn := &kptfilev1.Function{
+				Starlark:  &starlark,
+				ConfigMap: map[string]string{"replicas": "3"},
+			}
+			ctx := printer.WithContext(context.Background(), printer.New(nil, &bytes.Buffer{}))
+			fnResults := fnresult.NewResultList()
+			r, err := NewStarlarkRunner(ctx, fn, types.UniquePath(dir), fnResults)
+			if tc.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.errMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			input, err := kio.FromBytes([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 1
+`))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			output, err := r.Filter(input)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if assert.Len(t, output, 1) {
+				replicas, err := output[0].Pipe(yaml.Lookup("spec", "replicas"))
+				assert.NoError(t, err)
+				assert.Equal(t, "3", replicas.YNode().Value)
+			}
+			if assert.Len(t, fnResults.Items, 1) {
+				assert.Equal(t, tc.expectedName, fnResults.Items[0].Starlark)
+			}
+		})
+	}
+}
@@ -31,6 +31,7 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/errors"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/Masterminds/semver/v3"
 )
 
 // RepoCacheDirEnv is the name of the environment variable that controls the cache directory
@@ -227,6 +228,160 @@ func (gur *GitUpstreamRepo) GetRepo(ctx context.Context, refs []string) (string,
 	return dir, nil
 }
 
+// GetRepoWithHistory fetches ref and its objects like GetRepo, but including
+// the full history of ref, which the cache repo otherwise doesn't contain. It
+// returns an error if any of the commits isn't in the history of ref.
+func (gur *GitUpstreamRepo) GetRepoWithHistory(ctx context.Context, ref string, commits ...string) (string, error) {
+	const op errors.Op = "gitutil.GetRepoWithHistory"
+	dir, err := gur.GetRepo(ctx, []string{ref})
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return "", errors.E(op, errors.Repo(gur.URI), err)
+	}
+	rr, err := gitRunner.Run(ctx, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return "", errors.E(op, errors.Repo(gur.URI), err)
+	}
+	if strings.TrimSpace(rr.Stdout) == "true" {
+		if _, err := gitRunner.Run(ctx, "fetch", "--unshallow", "origin", ref); err != nil {
+			AmendGitExecError(err, func(e *GitExecError) {
+				e.Repo = gur.URI
+			})
+			return "", errors.E(op, errors.Git, errors.Repo(gur.URI), fmt.Errorf(
+				"error fetching the history of ref %s: %w", ref, err))
+		}
+	}
+	// the cache repo doesn't have local branches or tags, so the commit of
+	// ref is used
+	commit, found := gur.ResolveRef(ref)
+	if !found {
+		commit = ref
+	}
+	for _, c := range commits {
+		if _, err := gitRunner.Run(ctx, "merge-base", "--is-ancestor", c, commit); err != nil {
+			return "", errors.E(op, errors.Git, errors.Repo(gur.URI), fmt.Errorf(
+				"commit %s is not in the history of ref %s", c, ref))
+		}
+	}
+	return dir, nil
+}
+
+// ReadFile returns the content of the file at the slash-separated path in
+// the repo at ref, and the commit ref was resolved to. The default branch is
+// used if ref is empty. The repo is fetched through the repo cache.
+func ReadFile(ctx context.Context, uri, ref, path string) ([]byte, string, error) {
+	const op errors.Op = "gitutil.ReadFile"
+	if isCommit(ref) {
+		// a commit never changes, so the repo doesn't need to be fetched if
+		// the commit is already in the cache
+		content, commit, found, err := ReadCachedFile(ctx, uri, ref, path)
+		if err != nil {
+			return nil, "", errors.E(op, errors.Repo(uri), err)
+		}
+		if found {
+			return content, commit, nil
+		}
+	}
+	gur, err := NewGitUpstreamRepo(ctx, uri)
+	if err != nil {
+		return nil, "", errors.E(op, errors.Repo(uri), err)
+	}
+	if ref == "" {
+		ref, err = gur.GetDefaultBranch(ctx)
+		if err != nil {
+			return nil, "", errors.E(op, errors.Repo(uri), err)
+		}
+	}
+	dir, err := gur.GetRepo(ctx, []string{ref})
+	if err != nil {
+		return nil, "", errors.E(op, errors.Repo(uri), err)
+	}
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return nil, "", errors.E(op, errors.Repo(uri), err)
+	}
+	rev := ref
+	if commit, found := gur.ResolveRef(ref); found {
+		rev = commit
+	}
+	rr, err := gitRunner.Run(ctx, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = uri
+			e.Ref = ref
+		})
+		return nil, "", errors.E(op, errors.Repo(uri), err)
+	}
+	commit := strings.TrimSpace(rr.Stdout)
+	if _, found := gur.ResolveTag(ref); found {
+		// fetching a tag doesn't create it in the cache repo, record it so
+		// that ReadCachedFile can find it
+		if _, err := gitRunner.Run(ctx, "update-ref", "refs/tags/"+ref, commit); err != nil {
+			return nil, "", errors.E(op, errors.Repo(uri), err)
+		}
+	}
+	rr, err = gitRunner.Run(ctx, "show", commit+":"+path)
+	if err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = uri
+			e.Ref = ref
+		})
+		return nil, "", errors.E(op, errors.Repo(uri), fmt.Errorf("error reading %q: %w", path, err))
+	}
+	return []byte(rr.Stdout), commit, nil
+}
+
+// ReadCachedFile returns the content of the file at the slash-separated path
+// in the repo at ref, and the commit ref resolves to, like ReadFile, but only
+// reads the repo cache and never fetches from the repo. found is false if the
+// repo or ref isn't in the cache, e.g. because it hasn't been fetched before.
+func ReadCachedFile(ctx context.Context, uri, ref, path string) ([]byte, string, bool, error) {
+	const op errors.Op = "gitutil.ReadCachedFile"
+	if ref == "" {
+		// the default branch isn't known without asking the repo
+		return nil, "", false, nil
+	}
+	gur := &GitUpstreamRepo{URI: uri}
+	kptCacheDir, err := gur.getRepoCacheDir()
+	if err != nil {
+		return nil, "", false, errors.E(op, err)
+	}
+	dir := filepath.Join(kptCacheDir, gur.getRepoDir(uri))
+	if _, err := os.Stat(dir); err != nil {
+		return nil, "", false, nil
+	}
+	gitRunner, err := NewLocalGitRunner(dir)
+	if err != nil {
+		return nil, "", false, errors.E(op, errors.Repo(uri), err)
+	}
+	var commit string
+	// branches are fetched as remote-tracking branches, tags are recorded
+	// by ReadFile
+	for _, rev := range []string{"refs/tags/" + ref, "refs/remotes/origin/" + ref, ref} {
+		// an error means the ref isn't in the cache
+		rr, err := gitRunner.Run(ctx, "rev-parse", "--verify", "-q", rev+"^{commit}")
+		if err == nil {
+			commit = strings.TrimSpace(rr.Stdout)
+			break
+		}
+	}
+	if commit == "" {
+		return nil, "", false, nil
+	}
+	rr, err := gitRunner.Run(ctx, "show", commit+":"+path)
+	if err != nil {
+		AmendGitExecError(err, func(e *GitExecError) {
+			e.Repo = uri
+			e.Ref = ref
+		})
+		return nil, "", false, errors.E(op, errors.Repo(uri), fmt.Errorf("error reading %q: %w", path, err))
+	}
+	return []byte(rr.Stdout), commit, true, nil
+}
+
 // GetDefaultBranch returns the name of the branch pointed to by the
 // HEAD symref. This is the default branch of the repository.
 func (gur *GitUpstreamRepo) GetDefaultBranch(ctx context.Context) (string, error) {
@@ -302,6 +457,48 @@ func (gur *GitUpstreamRepo) ResolveRef(ref string) (string, bool) {
 	return gur.ResolveTag(ref)
 }
 
+// IsSemverConstraint returns true if ref is a semantic version constraint,
+// e.g. '~1.4' or '>=2.0 <3.0', rather than a branch, tag or commit. Exact
+// versions like 'v1.4.2' are treated as tags.
+func IsSemverConstraint(ref string) bool {
+	if !strings.ContainsAny(ref, "~^<>=*|, ") && !strings.HasSuffix(ref, ".x") {
+		return false
+	}
+	_, err := semver.NewConstraint(ref)
+	return err == nil
+}
+
+// ResolveSemverConstraint returns the tag with the highest semantic version
+// matching the constraint among the tags with the given prefix, e.g.
+// 'packages/wordpress/'. Tags which are not semantic versions are ignored. If
+// no tag matches, the second return value will be false.
+func (gur *GitUpstreamRepo) ResolveSemverConstraint(constraint, prefix string) (string, bool, error) {
+	const op errors.Op = "gitutil.ResolveSemverConstraint"
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", false, errors.E(op, errors.InvalidParam,
+			fmt.Errorf("invalid version constraint %q: %w", constraint, err))
+	}
+	var tag string
+	var highest *semver.Version
+	for t := range gur.Tags {
+		if !strings.HasPrefix(t, prefix) {
+			continue
+		}
+		v, err := semver.NewVersion(strings.TrimPrefix(t, prefix))
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		// tags are compared by their original name if the versions are
+		// equal, e.g. for 'v1.0' and 'v1.0.0', to get a deterministic result
+		if highest == nil || v.GreaterThan(highest) || (v.Equal(highest) && t > tag) {
+			tag = t
+			highest = v
+		}
+	}
+	return tag, highest != nil, nil
+}
+
 // getRepoDir returns the cache directory name for a remote repo
 // This takes the md5 hash of the repo uri and then base32 encodes it to make
 // sure it doesn't contain characters that isn't legal in directory names.
@@ -311,7 +508,13 @@ func (gur *GitUpstreamRepo) getRepoDir(uri string) string {
 
 // getRepoCacheDir
 func (gur *GitUpstreamRepo) getRepoCacheDir() (string, error) {
-	const op errors.Op = "gitutil.getRepoCacheDir"
+	return CacheDir()
+}
+
+// CacheDir returns the directory of the kpt cache. It is controlled by the
+// KPT_CACHE_DIR environment variable, and defaults to UserHomeDir/.kpt/repos.
+func CacheDir() (string, error) {
+	const op errors.Op = "gitutil.CacheDir"
 	var err error
 	dir := os.Getenv(RepoCacheDirEnv)
 	if dir != "" {
@@ -429,3 +632,10 @@ loop:
 	}
 	return repoCacheDir, nil
 }
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// isCommit returns true if ref is a full commit SHA.
+func isCommit(ref string) bool {
+	return commitRegexp.MatchString(ref)
+}
@@ -454,3 +454,140 @@ func toKeys(m map[string]string) []string {
 	sort.Strings(keys)
 	return keys
 }
+
+func TestIsSemverConstraint(t *testing.T) {
+	testCases := map[string]bool{
+		"~1.4":        true,
+		"^2":          true,
+		">=2.0 <3.0":  true,
+		">=2.0, <3.0": true,
+		"1.4.x":       true,
+		"v1.4.2":      false,
+		"main":        false,
+		"4d2aa98b45":  false,
+		"my=branch":   false,
+	}
+	for ref, expected := range testCases {
+		assert.Equal(t, expected, IsSemverConstraint(ref), ref)
+	}
+}
+
+func TestGitUpstreamRepo_ResolveSemverConstraint(t *testing.T) {
+	gur := &GitUpstreamRepo{
+		Tags: map[string]string{
+			"v1.3.9":                 "a",
+			"v1.4.0":                 "b",
+			"v1.4.2":                 "c",
+			"v1.5.0":                 "d",
+			"v2.0.0":                 "e",
+			"v2.1.0-rc.1":            "f",
+			"latest":                 "g",
+			"packages/mysql/v1.4.7":  "h",
+			"packages/mysql/v3.0.0":  "i",
+			"packages/mysqlx/v1.4.9": "j",
+		},
+	}
+	testCases := map[string]struct {
+		constraint    string
+		prefix        string
+		expectedTag   string
+		expectedFound bool
+	}{
+		"tilde": {
+			constraint:    "~1.4",
+			expectedTag:   "v1.4.2",
+			expectedFound: true,
+		},
+		"range": {
+			constraint:    ">=1.4 <2.0",
+			expectedTag:   "v1.5.0",
+			expectedFound: true,
+		},
+		"pre-releases are excluded": {
+			constraint:    "^2",
+			expectedTag:   "v2.0.0",
+			expectedFound: true,
+		},
+		"package prefix": {
+			constraint:    "~1.4",
+			prefix:        "packages/mysql/",
+			expectedTag:   "packages/mysql/v1.4.7",
+			expectedFound: true,
+		},
+		"no match": {
+			constraint: "~4",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			tag, found, err := gur.ResolveSemverConstraint(tc.constraint, tc.prefix)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFound, found)
+			assert.Equal(t, tc.expectedTag, tag)
+		})
+	}
+}
+
+func TestReadCachedFile(t *testing.T) {
+	g, _, clean := testutil.SetupReposAndWorkspace(t, map[string][]testutil.Content{
+		testutil.Upstream: {
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource),
+				Branch: "foo",
+				Tag:    "v1",
+			},
+		},
+	})
+	defer clean()
+	ctx := fake.CtxWithDefaultPrinter()
+	repo := g[testutil.Upstream].RepoDirectory
+
+	_, _, found, err := ReadCachedFile(ctx, repo, "foo", "deployment.yaml")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.False(t, found)
+
+	refs := []string{"foo", "v1"}
+	expected := map[string][]byte{}
+	commits := map[string]string{}
+	for _, ref := range refs {
+		content, commit, err := ReadFile(ctx, repo, ref, "deployment.yaml")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		expected[ref], commits[ref] = content, commit
+	}
+	refs = append(refs, commits["foo"])
+	expected[commits["foo"]], commits[commits["foo"]] = expected["foo"], commits["foo"]
+
+	// the cache is read without the repo
+	if !assert.NoError(t, os.RemoveAll(repo)) {
+		t.FailNow()
+	}
+	for _, ref := range refs {
+		content, commit, found, err := ReadCachedFile(ctx, repo, ref, "deployment.yaml")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.True(t, found, ref)
+		assert.Equal(t, string(expected[ref]), string(content), ref)
+		assert.Equal(t, commits[ref], commit, ref)
+	}
+
+	// files are read at cached commits without fetching the repo
+	content, commit, err := ReadFile(ctx, repo, commits["foo"], "deployment.yaml")
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(expected["foo"]), string(content))
+		assert.Equal(t, commits["foo"], commit)
+	}
+
+	_, _, found, err = ReadCachedFile(ctx, repo, "bar", "deployment.yaml")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, _, err = ReadCachedFile(ctx, repo, "foo", "missing.yaml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,325 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// GitFileReader reads the file at the slash-separated path in the git
+// repository repo at ref. It returns the content of the file and the commit
+// ref was resolved to. An empty ref refers to the default branch.
+type GitFileReader func(ctx context.Context, repo, ref, path string) ([]byte, string, error)
+
+type contextKey int
+
+const gitFileReaderKey contextKey = 0
+
+// WithGitFileReader returns a context with the reader used by Pipeline to
+// read pipeline fragments in git repositories. Every file is only read once
+// at each ref through the returned context.
+func WithGitFileReader(ctx context.Context, r GitFileReader) context.Context {
+	type key struct{ repo, ref, path string }
+	type result struct {
+		content []byte
+		commit  string
+	}
+	var mu sync.Mutex
+	results := map[key]result{}
+	return context.WithValue(ctx, gitFileReaderKey, GitFileReader(
+		func(ctx context.Context, repo, ref, path string) ([]byte, string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			k := key{repo: repo, ref: ref, path: path}
+			if res, found := results[k]; found {
+				return res.content, res.commit, nil
+			}
+			content, commit, err := r(ctx, repo, ref, path)
+			if err != nil {
+				return nil, "", err
+			}
+			results[k] = result{content: content, commit: commit}
+			return content, commit, nil
+		}))
+}
+
+// fragmentLocation is the location of a file containing a pipeline, i.e. a
+// Kptfile or a pipeline fragment.
+type fragmentLocation struct {
+	// path is the OS-defined path of a local file, or the slash-separated
+	// path of the file in the git repository.
+	path string
+
+	// repo is the git repository of the file. It is empty for local files.
+	repo string
+
+	// commit is the commit of the file in the git repository.
+	commit string
+}
+
+// Fragment is a pipeline fragment included by the pipeline of a package.
+type Fragment struct {
+	kptfilev1.PipelineFragment
+
+	// Path is the OS-defined path of the fragment file if it is a local
+	// file, and empty if it is in a git repository.
+	Path string
+
+	// Location is the location of the fragment file, i.e. its path or its
+	// repository, path and commit.
+	Location string
+}
+
+// Fragments returns the pipeline fragments included by the pipeline of the
+// package, directly or through other fragments, in the order in which their
+// functions are run.
+func (p *Pkg) Fragments(ctx context.Context) ([]Fragment, error) {
+	const op errors.Op = "pkg.Fragments"
+	pl, err := p.localPipeline()
+	if err != nil {
+		return nil, errors.E(op, p.UniquePath, err)
+	}
+	var fragments []Fragment
+	kptfilePath := filepath.Join(string(p.UniquePath), kptfilev1.KptFileName)
+	_, err = expandPipeline(ctx, pl, fragmentLocation{path: kptfilePath}, nil,
+		func(loc fragmentLocation, frag *kptfilev1.PipelineFragment) {
+			f := Fragment{PipelineFragment: *frag, Location: loc.String()}
+			if loc.repo == "" {
+				f.Path = loc.path
+			}
+			fragments = append(fragments, f)
+		})
+	if err != nil {
+		return nil, errors.E(op, p.UniquePath, err)
+	}
+	return fragments, nil
+}
+
+func (l fragmentLocation) String() string {
+	if l.repo == "" {
+		return l.path
+	}
+	return fmt.Sprintf("%s/%s@%s", l.repo, l.path, l.commit)
+}
+
+// expandPipeline returns a copy of pl in which the included pipeline fragments
+// are replaced by their functions. loc is the location of the file containing
+// pl, and stack contains the locations of the files including it, which is
+// used to detect include cycles. visit is called for every fragment which is
+// read, if it is set.
+func expandPipeline(ctx context.Context, pl *kptfilev1.Pipeline, loc fragmentLocation,
+	stack []string, visit func(fragmentLocation, *kptfilev1.PipelineFragment)) (*kptfilev1.Pipeline, error) {
+	stack = append(stack, loc.String())
+	expanded := &kptfilev1.Pipeline{}
+	for i := range pl.Include {
+		fragLoc, err := resolveInclude(loc, &pl.Include[i])
+		if err != nil {
+			return nil, err
+		}
+		frag, err := readFragment(ctx, &fragLoc)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stack {
+			if s == fragLoc.String() {
+				return nil, fmt.Errorf("pipeline include cycle: %s -> %s",
+					strings.Join(stack, " -> "), fragLoc)
+			}
+		}
+		fragPipeline, err := expandPipeline(ctx, &frag.Pipeline, fragLoc, stack, visit)
+		if err != nil {
+			return nil, err
+		}
+		if visit != nil {
+			visit(fragLoc, frag)
+		}
+		expanded.Mutators = append(expanded.Mutators, fragPipeline.Mutators...)
+		expanded.Validators = append(expanded.Validators, fragPipeline.Validators...)
+	}
+	expanded.Mutators = append(expanded.Mutators, pl.Mutators...)
+	expanded.Validators = append(expanded.Validators, pl.Validators...)
+	return expanded, nil
+}
+
+// resolveInclude returns the location of the fragment included by the file
+// at loc.
+func resolveInclude(loc fragmentLocation, inc *kptfilev1.PipelineInclude) (fragmentLocation, error) {
+	const op errors.Op = "pkg.resolveInclude"
+	if inc.Git != nil {
+		// the fragment is read at the recorded commit, so that it doesn't
+		// change when the ref moves. Otherwise the ref is resolved to a
+		// commit when the fragment is read.
+		commit := inc.Git.Commit
+		if commit == "" {
+			commit = inc.Git.Ref
+		}
+		return fragmentLocation{
+			path:   path.Clean(strings.TrimPrefix(inc.Git.Path, "/")),
+			repo:   inc.Git.Repo,
+			commit: commit,
+		}, nil
+	}
+	if loc.repo == "" {
+		return fragmentLocation{
+			path: filepath.Join(filepath.Dir(loc.path), filepath.FromSlash(inc.Path)),
+		}, nil
+	}
+	// relative includes of git fragments refer to the same commit
+	p := path.Join(path.Dir(loc.path), inc.Path)
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return fragmentLocation{}, errors.E(op, errors.Repo(loc.repo),
+			fmt.Errorf("pipeline fragment %q is outside the repository", inc.Path))
+	}
+	return fragmentLocation{
+		path:   p,
+		repo:   loc.repo,
+		commit: loc.commit,
+	}, nil
+}
+
+// readFragment reads and validates the pipeline fragment at loc. The ref of
+// git fragments is replaced by the commit it was resolved to.
+func readFragment(ctx context.Context, loc *fragmentLocation) (*kptfilev1.PipelineFragment, error) {
+	const op errors.Op = "pkg.readFragment"
+	var b []byte
+	if loc.repo == "" {
+		var err error
+		b, err = ioutil.ReadFile(loc.path)
+		if err != nil {
+			return nil, errors.E(op, errors.IO, types.UniquePath(loc.path), err)
+		}
+	} else {
+		readFile, ok := ctx.Value(gitFileReaderKey).(GitFileReader)
+		if !ok {
+			return nil, errors.E(op, errors.Repo(loc.repo),
+				fmt.Errorf("pipeline fragments in git repositories are not supported"))
+		}
+		var err error
+		b, loc.commit, err = readFile(ctx, loc.repo, loc.commit, loc.path)
+		if err != nil {
+			return nil, errors.E(op, errors.Repo(loc.repo), err)
+		}
+	}
+
+	frag := &kptfilev1.PipelineFragment{}
+	d := yaml.NewDecoder(bytes.NewBuffer(b))
+	d.KnownFields(true)
+	if err := d.Decode(frag); err != nil {
+		return nil, fmt.Errorf("invalid pipeline fragment %s: %w", loc, err)
+	}
+	if err := frag.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid pipeline fragment %s: %w", loc, err)
+	}
+	return frag, nil
+}
+
+// RecordIncludeCommits resolves the refs of the git pipeline fragments
+// included by the Kptfile, and records the commits they were resolved to in
+// the commit field of the includes, so that the fragments are read at the
+// same commits until the commits are recorded again. The Kptfile is only
+// written if any of the commits changed.
+func (p *Pkg) RecordIncludeCommits(ctx context.Context) error {
+	const op errors.Op = "pkg.RecordIncludeCommits"
+	pl, err := p.localPipeline()
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	kptfilePath := filepath.Join(string(p.UniquePath), kptfilev1.KptFileName)
+	commits := make([]string, len(pl.Include))
+	for i, inc := range pl.Include {
+		if inc.Git == nil {
+			continue
+		}
+		// resolve the ref rather than the recorded commit
+		git := *inc.Git
+		git.Commit = ""
+		inc.Git = &git
+		loc, err := resolveInclude(fragmentLocation{path: kptfilePath}, &inc)
+		if err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		if _, err := readFragment(ctx, &loc); err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+		commits[i] = loc.commit
+	}
+	if err := p.recordIncludeCommits(commits); err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	// the fragments may have changed
+	p.pipeline = nil
+	return nil
+}
+
+// recordIncludeCommits records the commits the git fragments included by the
+// Kptfile were resolved to in the Kptfile, if they have changed.
+func (p *Pkg) recordIncludeCommits(commits []string) error {
+	kf, err := p.Kptfile()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for i, commit := range commits {
+		if commit != "" && kf.Pipeline.Include[i].Git.Commit != commit {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// the Kptfile is updated in place to preserve comments and formatting
+	kptfilePath := filepath.Join(string(p.UniquePath), kptfilev1.KptFileName)
+	node, err := yaml.ReadFile(kptfilePath)
+	if err != nil {
+		return errors.E(errors.IO, types.UniquePath(kptfilePath), err)
+	}
+	includes, err := node.Pipe(yaml.Lookup("pipeline", "include"))
+	if err != nil {
+		return err
+	}
+	elements, err := includes.Elements()
+	if err != nil {
+		return err
+	}
+	for i, commit := range commits {
+		if commit == "" {
+			continue
+		}
+		if err := elements[i].PipeE(
+			yaml.Lookup("git"),
+			yaml.SetField("commit", yaml.NewScalarRNode(commit)),
+		); err != nil {
+			return err
+		}
+		kf.Pipeline.Include[i].Git.Commit = commit
+	}
+	if err := yaml.WriteFile(node, kptfilePath); err != nil {
+		return errors.E(errors.IO, types.UniquePath(kptfilePath), err)
+	}
+	return nil
+}
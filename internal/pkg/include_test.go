@@ -0,0 +1,284 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineInclude(t *testing.T) {
+	testCases := map[string]struct {
+		files              map[string]string
+		expectedMutators   []string
+		expectedValidators []string
+		expectedErr        string
+	}{
+		"local fragments": {
+			files: map[string]string{
+				"pkg/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  include:
+  - path: ../policies/base.yaml
+  mutators:
+  - image: gcr.io/kpt-fn/set-labels:v0.1
+  validators:
+  - image: gcr.io/kpt-fn/kubeval:v0.1
+`,
+				"policies/base.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: base
+pipeline:
+  include:
+  - path: security.yaml
+  mutators:
+  - image: gcr.io/kpt-fn/set-namespace:v0.1
+    configMap:
+      namespace: prod
+`,
+				"policies/security.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: security
+pipeline:
+  validators:
+  - image: gcr.io/kpt-fn/gatekeeper:v0.1
+`,
+			},
+			expectedMutators: []string{
+				"gcr.io/kpt-fn/set-namespace:v0.1",
+				"gcr.io/kpt-fn/set-labels:v0.1",
+			},
+			expectedValidators: []string{
+				"gcr.io/kpt-fn/gatekeeper:v0.1",
+				"gcr.io/kpt-fn/kubeval:v0.1",
+			},
+		},
+		"include cycle": {
+			files: map[string]string{
+				"pkg/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  include:
+  - path: a.yaml
+`,
+				"pkg/a.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: a
+pipeline:
+  include:
+  - path: b.yaml
+`,
+				"pkg/b.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: b
+pipeline:
+  include:
+  - path: a.yaml
+`,
+			},
+			expectedErr: "pipeline include cycle",
+		},
+		"fragment referring to files": {
+			files: map[string]string{
+				"pkg/Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  include:
+  - path: a.yaml
+`,
+				"pkg/a.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: a
+pipeline:
+  mutators:
+  - image: gcr.io/kpt-fn/set-labels:v0.1
+    configPath: labels.yaml
+`,
+			},
+			expectedErr: "functions in pipeline fragments must not refer to files",
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			p, err := New(filepath.Join(dir, "pkg"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			pl, err := p.Pipeline(context.Background())
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErr)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var mutators, validators []string
+			for _, fn := range pl.Mutators {
+				mutators = append(mutators, fn.Image)
+			}
+			for _, fn := range pl.Validators {
+				validators = append(validators, fn.Image)
+			}
+			assert.Equal(t, tc.expectedMutators, mutators)
+			assert.Equal(t, tc.expectedValidators, validators)
+		})
+	}
+}
+
+func TestPipelineIncludeGit(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  include:
+  # shared validators
+  - git:
+      repo: https://github.com/example/policies
+      path: /validators.yaml
+      ref: main
+`,
+	})
+	fragments := map[string]string{
+		"validators.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: validators
+pipeline:
+  include:
+  - path: kubeval.yaml
+`,
+		"kubeval.yaml": `apiVersion: kpt.dev/v1
+kind: PipelineFragment
+metadata:
+  name: kubeval
+pipeline:
+  validators:
+  - image: gcr.io/kpt-fn/kubeval:v0.1
+`,
+	}
+	var refs []string
+	reader := func(_ context.Context, repo, ref, path string) ([]byte, string, error) {
+		refs = append(refs, ref)
+		content, found := fragments[path]
+		if !found {
+			return nil, "", fmt.Errorf("%s not found", path)
+		}
+		return []byte(content), "abc123", nil
+	}
+	ctx := WithGitFileReader(context.Background(), reader)
+
+	for i := 0; i < 2; i++ {
+		p, err := New(dir)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		pl, err := p.Pipeline(ctx)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Len(t, pl.Validators, 1)
+		assert.Equal(t, "gcr.io/kpt-fn/kubeval:v0.1", pl.Validators[0].Image)
+	}
+	// relative includes are read at the resolved commit, and the fragments
+	// are only read once
+	assert.Equal(t, []string{"main", "abc123"}, refs)
+
+	// reading the pipeline doesn't change the Kptfile
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotContains(t, string(b), "commit")
+
+	p, err := New(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	refs = nil
+	if !assert.NoError(t, p.RecordIncludeCommits(WithGitFileReader(context.Background(), reader))) {
+		t.FailNow()
+	}
+	// the ref is resolved even if a commit was recorded before
+	assert.Equal(t, []string{"main"}, refs)
+	b, err = ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: pkg
+pipeline:
+  include:
+  # shared validators
+  - git:
+      repo: https://github.com/example/policies
+      path: /validators.yaml
+      ref: main
+      commit: abc123
+`, string(b))
+
+	// the fragments are read at the recorded commit
+	p, err = New(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	refs = nil
+	_, err = p.Pipeline(WithGitFileReader(context.Background(), reader))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"abc123", "abc123"}, refs)
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
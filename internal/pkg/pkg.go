@@ -17,6 +17,7 @@ package pkg
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -119,6 +120,10 @@ type Pkg struct {
 	// A package can contain zero or one Kptfile meta resource.
 	// A nil value represents an implicit package.
 	kptfile *kptfilev1.KptFile
+
+	// pipeline is the pipeline of the package with the included pipeline
+	// fragments expanded.
+	pipeline *kptfilev1.Pipeline
 }
 
 // New returns a pkg given an absolute or relative OS-defined path.
@@ -256,9 +261,34 @@ func isSupportedKptfileVersion(version string) bool {
 	return slice.ContainsString(SupportedKptfileVersions, version, nil)
 }
 
-// Pipeline returns the Pipeline section of the pkg's Kptfile.
+// Pipeline returns the Pipeline section of the pkg's Kptfile, with the
+// pipeline fragments it includes expanded in place.
 // if pipeline is not specified in a Kptfile, it returns Zero value of the pipeline.
-func (p *Pkg) Pipeline() (*kptfilev1.Pipeline, error) {
+// Git fragments are read at the commit recorded in the Kptfile, if any.
+func (p *Pkg) Pipeline(ctx context.Context) (*kptfilev1.Pipeline, error) {
+	const op errors.Op = "pkg.Pipeline"
+	if p.pipeline != nil {
+		return p.pipeline, nil
+	}
+	pl, err := p.localPipeline()
+	if err != nil {
+		return nil, err
+	}
+	if len(pl.Include) == 0 {
+		return pl, nil
+	}
+	kptfilePath := filepath.Join(string(p.UniquePath), kptfilev1.KptFileName)
+	expanded, err := expandPipeline(ctx, pl, fragmentLocation{path: kptfilePath}, nil, nil)
+	if err != nil {
+		return nil, errors.E(op, p.UniquePath, err)
+	}
+	p.pipeline = expanded
+	return p.pipeline, nil
+}
+
+// localPipeline returns the Pipeline section of the pkg's Kptfile without
+// expanding the pipeline fragments it includes.
+func (p *Pkg) localPipeline() (*kptfilev1.Pipeline, error) {
 	kf, err := p.Kptfile()
 	if err != nil {
 		return nil, err
@@ -477,7 +507,7 @@ func (p *Pkg) LocalResources(includeMetaResources bool) (resources []*yaml.RNode
 	if !hasKptfile {
 		return nil, nil
 	}
-	pl, err := p.Pipeline()
+	pl, err := p.localPipeline()
 	if err != nil {
 		return nil, errors.E(op, p.UniquePath, err)
 	}
@@ -507,7 +537,7 @@ func (p *Pkg) LocalResources(includeMetaResources bool) (resources []*yaml.RNode
 
 // Validates the package pipeline.
 func (p *Pkg) ValidatePipeline() error {
-	pl, err := p.Pipeline()
+	pl, err := p.localPipeline()
 	if err != nil {
 		return err
 	}
@@ -578,8 +608,8 @@ func filterMetaResources(input []*yaml.RNode, pl *kptfilev1.Pipeline) (output []
 	return output, nil
 }
 
-// fnConfigFilePaths returns paths to function config files referred in the
-// given pipeline.
+// fnConfigFilePaths returns paths to function config files and function
+// config schema files referred in the given pipeline.
 func fnConfigFilePaths(pl *kptfilev1.Pipeline) (fnConfigPaths sets.String) {
 	if pl == nil {
 		return nil
@@ -591,12 +621,18 @@ func fnConfigFilePaths(pl *kptfilev1.Pipeline) (fnConfigPaths sets.String) {
 			// TODO(droot): check if cleaning this path has some unnecessary side effects
 			fnConfigPaths.Insert(filepath.Clean(fn.ConfigPath))
 		}
+		if fn.ConfigSchemaPath != "" {
+			fnConfigPaths.Insert(filepath.Clean(fn.ConfigSchemaPath))
+		}
 	}
 	for _, fn := range pl.Validators {
 		if fn.ConfigPath != "" {
 			// TODO(droot): check if cleaning this path has some unnecessary side effects
 			fnConfigPaths.Insert(filepath.Clean(fn.ConfigPath))
 		}
+		if fn.ConfigSchemaPath != "" {
+			fnConfigPaths.Insert(filepath.Clean(fn.ConfigSchemaPath))
+		}
 	}
 	return fnConfigPaths
 }
@@ -632,7 +668,7 @@ func FunctionConfigFilePaths(rootPath types.UniquePath, recursive bool) (sets.St
 		if err != nil {
 			return nil, errors.E(op, rootPath, err)
 		}
-		pl, err := p.Pipeline()
+		pl, err := p.localPipeline()
 		if err != nil {
 			return nil, errors.E(op, rootPath, fmt.Errorf("failed to get pipeline in package %s: %w", path, err))
 		}
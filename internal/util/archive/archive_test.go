@@ -0,0 +1,140 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarExtract(t *testing.T) {
+	src := t.TempDir()
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(src, "sub", ".git"), 0700)) {
+		t.FailNow()
+	}
+	files := map[string]string{
+		"Kptfile":       "kind: Kptfile\n",
+		"sub/cm.yaml":   "kind: ConfigMap\n",
+		"sub/.git/HEAD": "ref: refs/heads/main\n",
+	}
+	for p, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(src, filepath.FromSlash(p)), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	if !assert.NoError(t, os.Symlink("Kptfile", filepath.Join(src, "link"))) {
+		t.FailNow()
+	}
+
+	b, err := Tar(src)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	dest := t.TempDir()
+	if !assert.NoError(t, Extract(bytes.NewReader(b), dest)) {
+		t.FailNow()
+	}
+
+	for _, p := range []string{"Kptfile", "sub/cm.yaml"} {
+		content, err := ioutil.ReadFile(filepath.Join(dest, filepath.FromSlash(p)))
+		assert.NoError(t, err)
+		assert.Equal(t, files[p], string(content))
+	}
+	// git metadata and symlinks are skipped
+	for _, p := range []string{"sub/.git", "link"} {
+		_, err := os.Lstat(filepath.Join(dest, filepath.FromSlash(p)))
+		assert.True(t, os.IsNotExist(err), p)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	testCases := map[string]struct {
+		headers  []*tar.Header
+		expected []string
+		err      string
+	}{
+		"parent directory": {
+			headers: []*tar.Header{
+				{Name: "../cm.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			},
+			err: `invalid file path "../cm.yaml"`,
+		},
+		"parent directory in path": {
+			headers: []*tar.Header{
+				{Name: "sub/../../cm.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			},
+			err: `invalid file path "sub/../../cm.yaml"`,
+		},
+		"absolute path": {
+			headers: []*tar.Header{
+				{Name: "/tmp/cm.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			},
+			err: `invalid file path "/tmp/cm.yaml"`,
+		},
+		"symlinks are skipped": {
+			headers: []*tar.Header{
+				{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+				{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0700},
+				{Name: "sub/cm.yaml", Typeflag: tar.TypeReg, Mode: 0600},
+			},
+			expected: []string{"sub", "sub/cm.yaml"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		t.Run(tn, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range tc.headers {
+				if !assert.NoError(t, tw.WriteHeader(hdr)) {
+					t.FailNow()
+				}
+			}
+			if !assert.NoError(t, tw.Close()) {
+				t.FailNow()
+			}
+
+			dir := filepath.Join(t.TempDir(), "pkg")
+			err := Extract(&buf, dir)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			var extracted []string
+			err = filepath.Walk(dir, func(p string, _ os.FileInfo, err error) error {
+				if err != nil || p == dir {
+					return err
+				}
+				rel, err := filepath.Rel(dir, p)
+				extracted = append(extracted, filepath.ToSlash(rel))
+				return err
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, extracted)
+		})
+	}
+}
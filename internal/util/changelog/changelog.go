@@ -0,0 +1,185 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package changelog contains functions for listing the upstream changes of
+// packages between the version they were fetched at and a new version.
+package changelog
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+)
+
+// Log is the list of upstream changes of a package.
+type Log struct {
+	// From is the commit the package was last fetched at.
+	From string
+
+	// To is the commit the target ref was resolved to.
+	To string
+
+	// Ref is the target ref, as resolved in the upstream repo.
+	Ref string
+
+	// Commits are the commits which changed the upstream directory of the
+	// package between From and To, newest first.
+	Commits []Commit
+}
+
+// Commit is a commit which changed the upstream package.
+type Commit struct {
+	Commit  string
+	Author  string
+	Date    string
+	Subject string
+
+	// Files are the files of the package changed by the commit.
+	Files []FileChange
+}
+
+// FileChange is the change of a file in a commit.
+type FileChange struct {
+	// Path is the slash-separated path of the file relative to the package.
+	Path string
+
+	// Added and Deleted are the number of lines added and deleted.
+	Added   int
+	Deleted int
+
+	// Binary is true if the file is binary, in which case the number of
+	// lines isn't known.
+	Binary bool
+}
+
+// Get returns the commits which changed the upstream of the package between
+// the commit in the upstreamLock and ref. If ref is empty, the ref of the
+// upstream is used. The history is read through the repo cache.
+func Get(ctx context.Context, kf *kptfilev1.KptFile, ref string) (*Log, error) {
+	const op errors.Op = "changelog.Get"
+	if kf.Upstream == nil || kf.Upstream.Git == nil {
+		return nil, errors.E(op, errors.InvalidParam,
+			fmt.Errorf("package must have a git upstream"))
+	}
+	if kf.UpstreamLock == nil || kf.UpstreamLock.Git == nil {
+		return nil, errors.E(op, errors.InvalidParam,
+			fmt.Errorf("package has not been fetched from its upstream"))
+	}
+	g := kf.Upstream.Git
+	if ref == "" {
+		ref = g.Ref
+	}
+	from := kf.UpstreamLock.Git.Commit
+
+	gur, err := gitutil.NewGitUpstreamRepo(ctx, g.Repo)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	ref, err = fetch.ResolvePackageRef(gur, g.Directory, ref)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	to, found := gur.ResolveRef(ref)
+	if !found {
+		to = ref
+	}
+
+	dir, err := gur.GetRepoWithHistory(ctx, ref, from)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	gitRunner, err := gitutil.NewLocalGitRunner(dir)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	// the resolved commit is used for the range, since the cache repo
+	// doesn't have local branches or tags
+	rr, err := gitRunner.Run(ctx, "rev-parse", "--verify", "-q", to+"^{commit}")
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	to = strings.TrimSpace(rr.Stdout)
+
+	pkgDir := strings.Trim(path.Clean("/"+g.Directory), "/")
+	args := []string{"--no-renames", "--numstat",
+		"--format=%x00%H%x1f%an <%ae>%x1f%aI%x1f%s", from + ".." + to}
+	if pkgDir != "" {
+		args = append(args, "--", pkgDir)
+	}
+	rr, err = gitRunner.Run(ctx, "log", args...)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	commits, err := parseLog(rr.Stdout, pkgDir)
+	if err != nil {
+		return nil, errors.E(op, errors.Repo(g.Repo), err)
+	}
+	return &Log{
+		From:    from,
+		To:      to,
+		Ref:     ref,
+		Commits: commits,
+	}, nil
+}
+
+// parseLog parses the output of git log with the format used by Get. The
+// paths of the files are made relative to pkgDir.
+func parseLog(out, pkgDir string) ([]Commit, error) {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x00") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		if len(lines) == 0 || lines[0] == "" {
+			continue
+		}
+		header := strings.SplitN(lines[0], "\x1f", 4)
+		if len(header) != 4 {
+			return nil, fmt.Errorf("unexpected output from git log: %q", lines[0])
+		}
+		c := Commit{
+			Commit:  header[0],
+			Author:  header[1],
+			Date:    header[2],
+			Subject: header[3],
+		}
+		for _, line := range lines[1:] {
+			if line == "" {
+				continue
+			}
+			fields := strings.SplitN(line, "\t", 3)
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("unexpected output from git log: %q", line)
+			}
+			f := FileChange{Path: fields[2]}
+			if pkgDir != "" {
+				f.Path = strings.TrimPrefix(f.Path, pkgDir+"/")
+			}
+			if fields[0] == "-" {
+				f.Binary = true
+			} else {
+				f.Added, _ = strconv.Atoi(fields[0])
+				f.Deleted, _ = strconv.Atoi(fields[1])
+			}
+			c.Files = append(c.Files, f)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
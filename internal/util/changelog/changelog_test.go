@@ -0,0 +1,239 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+func TestGet(t *testing.T) {
+	testCases := map[string]struct {
+		ref             string
+		expectedRef     string
+		expectedCommits int
+	}{
+		"same version": {
+			ref:             "v1.0.0",
+			expectedRef:     "v1.0.0",
+			expectedCommits: 0,
+		},
+		"newer tag": {
+			ref:             "v2.0.0",
+			expectedRef:     "v2.0.0",
+			expectedCommits: 2,
+		},
+		"semver constraint": {
+			ref:             "~1.1",
+			expectedRef:     "v1.1.0",
+			expectedCommits: 1,
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:      t,
+				GetRef: "v1.0.0",
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset1,
+							Branch: "master",
+							Tag:    "v1.0.0",
+						},
+						{
+							Data: testutil.Dataset2,
+							Tag:  "v1.1.0",
+						},
+						{
+							Data: testutil.Dataset3,
+							Tag:  "v2.0.0",
+						},
+					},
+				},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+			upstreamRepo := g.Repos[testutil.Upstream]
+
+			p := pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath())
+			kf, err := p.Kptfile()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			l, err := Get(fake.CtxWithDefaultPrinter(), kf, tc.ref)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, kf.UpstreamLock.Git.Commit, l.From)
+			assert.Equal(t, tc.expectedRef, l.Ref)
+			if !assert.Len(t, l.Commits, tc.expectedCommits) {
+				t.FailNow()
+			}
+			if tc.expectedCommits == 0 {
+				assert.Equal(t, l.From, l.To)
+				return
+			}
+			head, err := upstreamRepo.GetCommit()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.expectedRef == "v2.0.0" {
+				assert.Equal(t, head, l.To)
+			}
+			assert.Equal(t, l.To, l.Commits[0].Commit)
+			for _, c := range l.Commits {
+				assert.NotEmpty(t, c.Files)
+			}
+		})
+	}
+}
+
+func TestGet_lockedCommit(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T:      t,
+		GetRef: "v1.0.0",
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: "master",
+					Tag:    "v1.0.0",
+				},
+				{
+					Data: testutil.Dataset2,
+				},
+				{
+					Data: testutil.Dataset3,
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	upstreamRepo := g.Repos[testutil.Upstream]
+	runner, err := gitutil.NewLocalGitRunner(upstreamRepo.RepoDirectory)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the locked commit is neither a tag nor the head of a branch
+	_, err = runner.Run(fake.CtxWithDefaultPrinter(), "tag", "-d", "v1.0.0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	p := pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath())
+	kf, err := p.Kptfile()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	l, err := Get(fake.CtxWithDefaultPrinter(), kf, "master")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, kf.UpstreamLock.Git.Commit, l.From)
+	assert.Len(t, l.Commits, 2)
+
+	// the locked commit must be in the history of the ref
+	_, err = runner.Run(fake.CtxWithDefaultPrinter(), "checkout", "--orphan", "other")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = runner.Run(fake.CtxWithDefaultPrinter(), "commit", "-m", "other")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = Get(fake.CtxWithDefaultPrinter(), kf, "other")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "commit "+kf.UpstreamLock.Git.Commit+" is not in the history of ref other")
+	}
+}
+
+func TestGet_noGitUpstream(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: "master",
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	p := pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath())
+	kf, err := p.Kptfile()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	kf.UpstreamLock = nil
+	_, err = Get(fake.CtxWithDefaultPrinter(), kf, "")
+	assert.Contains(t, err.Error(), "package has not been fetched from its upstream")
+
+	kf.Upstream = nil
+	_, err = Get(fake.CtxWithDefaultPrinter(), kf, "")
+	assert.Contains(t, err.Error(), "package must have a git upstream")
+}
+
+func TestParseLog(t *testing.T) {
+	out := "\x00abc\x1fJane <jane@example.com>\x1f2021-06-14T10:32:11-07:00\x1fUpdate the package\n\n" +
+		"3\t1\tpkg/deployment.yaml\n-\t-\tpkg/logo.png\n" +
+		"\x00def\x1fJohn <john@example.com>\x1f2021-06-13T10:32:11-07:00\x1fAdd the package\n\n" +
+		"10\t0\tpkg/Kptfile\n"
+	commits, err := parseLog(out, "pkg")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Commit{
+		{
+			Commit:  "abc",
+			Author:  "Jane <jane@example.com>",
+			Date:    "2021-06-14T10:32:11-07:00",
+			Subject: "Update the package",
+			Files: []FileChange{
+				{Path: "deployment.yaml", Added: 3, Deleted: 1},
+				{Path: "logo.png", Binary: true},
+			},
+		},
+		{
+			Commit:  "def",
+			Author:  "John <john@example.com>",
+			Date:    "2021-06-13T10:32:11-07:00",
+			Subject: "Add the package",
+			Files: []FileChange{
+				{Path: "Kptfile", Added: 10},
+			},
+		},
+	}, commits)
+}
@@ -22,10 +22,12 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/GoogleContainerTools/kpt/internal/fnruntime"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
@@ -121,6 +123,45 @@ func StringToImagePullPolicy(v string) fnruntime.ImagePullPolicy {
 	}
 }
 
+// AddFunctionLimitsFlags adds the flags which override the resource limits
+// of function containers.
+func AddFunctionLimitsFlags(c *cobra.Command, limits *kptfilev1.FunctionLimits) {
+	c.Flags().StringVar(&limits.Memory, "memory", "",
+		"memory limit of function containers, e.g. 512m.")
+	c.Flags().StringVar(&limits.CPUs, "cpus", "",
+		"number of CPUs available to function containers, e.g. 0.5.")
+	c.Flags().IntVar(&limits.PidsLimit, "pids-limit", 0,
+		"maximum number of processes in function containers, or -1 for no limit.")
+	c.Flags().Var(&optionalBool{p: &limits.ReadOnlyRootFilesystem}, "read-only",
+		"run function containers with a read-only root filesystem. --read-only=false turns it off.")
+	c.Flags().Lookup("read-only").NoOptDefVal = trueString
+}
+
+// optionalBool is a boolean flag which is nil unless it is set.
+type optionalBool struct {
+	p **bool
+}
+
+func (b *optionalBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b.p = &v
+	return nil
+}
+
+func (b *optionalBool) String() string {
+	if *b.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(**b.p)
+}
+
+func (b *optionalBool) Type() string {
+	return "bool"
+}
+
 // WriteFnOutput writes the output resources of function commands to provided destination
 func WriteFnOutput(dest, content string, fromStdin bool, w io.Writer) error {
 	r := strings.NewReader(content)
@@ -21,6 +21,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/kio"
 )
@@ -305,3 +307,31 @@ metadata:
 		})
 	}
 }
+
+func TestAddFunctionLimitsFlags(t *testing.T) {
+	testCases := map[string]struct {
+		args     []string
+		expected *bool
+	}{
+		"not set":   {},
+		"read-only": {args: []string{"--read-only"}, expected: boolPtr(true)},
+		"disabled":  {args: []string{"--read-only=false"}, expected: boolPtr(false)},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			var limits kptfilev1.FunctionLimits
+			c := &cobra.Command{}
+			AddFunctionLimitsFlags(c, &limits)
+			if !assert.NoError(t, c.ParseFlags(append(tc.args, "--pids-limit", "-1"))) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, limits.ReadOnlyRootFilesystem)
+			assert.Equal(t, -1, limits.PidsLimit)
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
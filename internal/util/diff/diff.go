@@ -122,6 +122,9 @@ func (c *Command) Run(ctx context.Context) error {
 	if err != nil {
 		return errors.Errorf("package missing Kptfile at '%s': %v", c.Path, err)
 	}
+	if kptFile.Upstream == nil || kptFile.Upstream.Git == nil {
+		return errors.Errorf("package at '%s' doesn't have a git upstream", c.Path)
+	}
 
 	// Create a staging directory to store all compared packages
 	stagingDirectory, err := ioutil.TempDir("", "kpt-")
@@ -29,6 +29,8 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/local"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
@@ -54,6 +56,22 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, c.Pkg.UniquePath, err)
 	}
 
+	if kf.Upstream.Oci != nil {
+		imageSpec := &oci.ImageSpec{Image: kf.Upstream.Oci.Image}
+		if err := pullAndCopy(ctx, imageSpec, c.Pkg.UniquePath.String()); err != nil {
+			return errors.E(op, c.Pkg.UniquePath, err)
+		}
+		return nil
+	}
+
+	if kf.Upstream.Local != nil {
+		spec := &local.PackageSpec{Path: kf.Upstream.Local.Path}
+		if err := loadAndCopy(ctx, spec, c.Pkg.UniquePath.String()); err != nil {
+			return errors.E(op, c.Pkg.UniquePath, err)
+		}
+		return nil
+	}
+
 	g := kf.Upstream.Git
 	repoSpec := &git.RepoSpec{
 		OrgRepo: g.Repo,
@@ -75,6 +93,20 @@ func (c Command) validate(kf *kptfilev1.KptFile) error {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile doesn't contain upstream information"))
 	}
 
+	if kf.Upstream.Oci != nil {
+		if len(kf.Upstream.Oci.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+		return nil
+	}
+
+	if kf.Upstream.Local != nil {
+		if len(kf.Upstream.Local.Path) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify path"))
+		}
+		return nil
+	}
+
 	if kf.Upstream.Git == nil {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("kptfile upstream doesn't have git information"))
 	}
@@ -121,6 +153,58 @@ func cloneAndCopy(ctx context.Context, r *git.RepoSpec, dest string) error {
 	return nil
 }
 
+// pullAndCopy pulls the provided package image and copies the content into
+// the directory specified by dest.
+func pullAndCopy(ctx context.Context, spec *oci.ImageSpec, dest string) error {
+	const op errors.Op = "fetch.pullAndCopy"
+	pr := printer.FromContextOrDie(ctx)
+
+	if err := oci.Pull(ctx, spec); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	defer os.RemoveAll(spec.Dir)
+
+	pr.Printf("Adding package %q.\n", spec.Image)
+	if err := pkgutil.CopyPackage(spec.AbsPath(), dest, true, pkg.All); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateKptfileWithoutOrigin(dest, spec.AbsPath(), false); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateUpstreamLockFromOci(dest, spec); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	return nil
+}
+
+// loadAndCopy copies the content of the provided local package into the
+// directory specified by dest.
+func loadAndCopy(ctx context.Context, spec *local.PackageSpec, dest string) error {
+	const op errors.Op = "fetch.loadAndCopy"
+	pr := printer.FromContextOrDie(ctx)
+
+	if err := local.Load(spec, dest); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	defer os.RemoveAll(spec.Dir)
+
+	pr.Printf("Adding package %q.\n", spec.Path)
+	if err := pkgutil.CopyPackage(spec.AbsPath(), dest, true, pkg.All); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateKptfileWithoutOrigin(dest, spec.AbsPath(), false); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+
+	if err := kptfileutil.UpdateUpstreamLockFromLocal(dest, spec); err != nil {
+		return errors.E(op, types.UniquePath(dest), err)
+	}
+	return nil
+}
+
 // ClonerUsingGitExec uses a local git install, as opposed
 // to say, some remote API, to obtain a local clone of
 // a remote repo. It looks for tags with the directory as a prefix to allow
@@ -138,18 +222,11 @@ func ClonerUsingGitExec(ctx context.Context, repoSpec *git.RepoSpec) error {
 		return errors.E(op, errors.Git, errors.Repo(repoSpec.CloneSpec()), err)
 	}
 
-	// Check if we have a ref in the upstream that matches the package-specific
-	// reference. If we do, we use that reference.
-	ps := strings.Split(repoSpec.Path, "/")
-	for len(ps) != 0 {
-		p := path.Join(ps...)
-		packageRef := path.Join(strings.TrimLeft(p, "/"), repoSpec.Ref)
-		if _, found := upstreamRepo.ResolveTag(packageRef); found {
-			repoSpec.Ref = packageRef
-			break
-		}
-		ps = ps[:len(ps)-1]
+	ref, err := ResolvePackageRef(upstreamRepo, repoSpec.Path, repoSpec.Ref)
+	if err != nil {
+		return errors.E(op, errors.Repo(repoSpec.CloneSpec()), err)
 	}
+	repoSpec.Ref = ref
 
 	// Pull the required ref into the repo git cache.
 	dir, err := upstreamRepo.GetRepo(ctx, []string{repoSpec.Ref})
@@ -223,3 +300,56 @@ func ClonerUsingGitExec(ctx context.Context, repoSpec *git.RepoSpec) error {
 	}
 	return nil
 }
+
+// ResolvePackageRef returns the ref in the upstream repo that is used to
+// fetch the version ref of the package in the directory dir. Semantic version
+// constraints are resolved to the highest matching tag. Tags with the
+// directory of the package as a prefix are preferred to allow for versioning
+// multiple kpt packages in a single repo independently.
+func ResolvePackageRef(upstreamRepo *gitutil.GitUpstreamRepo, dir, ref string) (string, error) {
+	if gitutil.IsSemverConstraint(ref) {
+		return resolveSemverConstraint(upstreamRepo, dir, ref)
+	}
+
+	// Check if we have a ref in the upstream that matches the package-specific
+	// reference. If we do, we use that reference.
+	ps := strings.Split(dir, "/")
+	for len(ps) != 0 {
+		p := path.Join(ps...)
+		packageRef := path.Join(strings.TrimLeft(p, "/"), ref)
+		if _, found := upstreamRepo.ResolveTag(packageRef); found {
+			return packageRef, nil
+		}
+		ps = ps[:len(ps)-1]
+	}
+	return ref, nil
+}
+
+// resolveSemverConstraint resolves the semantic version constraint to the
+// highest matching tag, preferring tags with the directory of the package as
+// a prefix.
+func resolveSemverConstraint(upstreamRepo *gitutil.GitUpstreamRepo, dir, constraint string) (string, error) {
+	const op errors.Op = "fetch.resolveSemverConstraint"
+	ps := strings.Split(strings.Trim(dir, "/"), "/")
+	for len(ps) != 0 {
+		prefix := path.Join(ps...)
+		if prefix != "" && prefix != "." {
+			tag, found, err := upstreamRepo.ResolveSemverConstraint(constraint, prefix+"/")
+			if err != nil {
+				return "", errors.E(op, err)
+			}
+			if found {
+				return tag, nil
+			}
+		}
+		ps = ps[:len(ps)-1]
+	}
+	tag, found, err := upstreamRepo.ResolveSemverConstraint(constraint, "")
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	if !found {
+		return "", errors.E(op, fmt.Errorf("no tag matches the version constraint %q", constraint))
+	}
+	return tag, nil
+}
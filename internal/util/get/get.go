@@ -35,12 +35,20 @@ import (
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 )
 
-// Command fetches a package from a git repository, copies it to a local
-// directory, and expands any remote subpackages.
+// Command fetches a package from a git repository or an OCI registry, copies
+// it to a local directory, and expands any remote subpackages.
 type Command struct {
 	// Git contains information about the git repo to fetch
 	Git *kptfilev1.Git
 
+	// Oci contains information about the package image to fetch.
+	Oci *kptfilev1.Oci
+
+	// Local contains information about the local directory or archive to
+	// fetch. Exactly one of Git, Oci and Local must be set. A relative path
+	// is relative to Destination.
+	Local *kptfilev1.Local
+
 	// Destination is the output directory to clone the package to.  Defaults to the name of the package --
 	// either the base repo name, or the base subdirectory name.
 	Destination string
@@ -70,18 +78,33 @@ func (c Command) Run(ctx context.Context) error {
 		return errors.E(op, errors.IO, types.UniquePath(c.Destination), err)
 	}
 
-	// normalize path to a filepath
-	repoDir := c.Git.Directory
-	if !strings.HasSuffix(repoDir, "file://") {
-		repoDir = filepath.Join(path.Split(repoDir))
-	}
-	c.Git.Directory = repoDir
-
 	kf := kptfileutil.DefaultKptfile(c.Name)
-	kf.Upstream = &kptfilev1.Upstream{
-		Type:           kptfilev1.GitOrigin,
-		Git:            c.Git,
-		UpdateStrategy: c.UpdateStrategy,
+	switch {
+	case c.Oci != nil:
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.OciOrigin,
+			Oci:            c.Oci,
+			UpdateStrategy: c.UpdateStrategy,
+		}
+	case c.Local != nil:
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.LocalOrigin,
+			Local:          c.Local,
+			UpdateStrategy: c.UpdateStrategy,
+		}
+	default:
+		// normalize path to a filepath
+		repoDir := c.Git.Directory
+		if !strings.HasSuffix(repoDir, "file://") {
+			repoDir = filepath.Join(path.Split(repoDir))
+		}
+		c.Git.Directory = repoDir
+
+		kf.Upstream = &kptfilev1.Upstream{
+			Type:           kptfilev1.GitOrigin,
+			Git:            c.Git,
+			UpdateStrategy: c.UpdateStrategy,
+		}
 	}
 
 	err = kptfileutil.WriteFile(c.Destination, kf)
@@ -127,7 +150,14 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 		if kf.Upstream != nil && kf.UpstreamLock == nil {
 			packageCount += 1
 			pr.PrintPackage(p, !(p == rootPkg))
-			pr.Printf("Fetching %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+			switch {
+			case kf.Upstream.Oci != nil:
+				pr.Printf("Fetching %s\n", kf.Upstream.Oci.Image)
+			case kf.Upstream.Local != nil:
+				pr.Printf("Fetching %s\n", kf.Upstream.Local.Path)
+			default:
+				pr.Printf("Fetching %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
+			}
 			err := (&fetch.Command{
 				Pkg: p,
 			}).Run(ctx)
@@ -151,22 +181,36 @@ func (c Command) fetchPackages(ctx context.Context, rootPkg *pkg.Pkg) error {
 // DefaultValues sets values to the default values if they were unspecified
 func (c *Command) DefaultValues() error {
 	const op errors.Op = "get.DefaultValues"
-	if c.Git == nil {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify git repo information"))
-	}
-	g := c.Git
-	if len(g.Repo) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
+	if (c.Git != nil && c.Oci != nil) || (c.Git != nil && c.Local != nil) || (c.Oci != nil && c.Local != nil) {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("must specify only one of git repo, image and local path information"))
 	}
-	if len(g.Ref) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
+	switch {
+	case c.Oci != nil:
+		if len(c.Oci.Image) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify image"))
+		}
+	case c.Local != nil:
+		if len(c.Local.Path) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify path"))
+		}
+	default:
+		if c.Git == nil {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify git repo information"))
+		}
+		g := c.Git
+		if len(g.Repo) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify repo"))
+		}
+		if len(g.Ref) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify ref"))
+		}
+		if len(g.Directory) == 0 {
+			return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
+		}
 	}
 	if len(c.Destination) == 0 {
 		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify destination"))
 	}
-	if len(g.Directory) == 0 {
-		return errors.E(op, errors.MissingParam, fmt.Errorf("must specify directory"))
-	}
 
 	if !filepath.IsAbs(c.Destination) {
 		return errors.E(op, errors.InvalidParam, fmt.Errorf("destination must be an absolute path"))
@@ -0,0 +1,223 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local contains functions for fetching packages from directories
+// and .tar.gz archives on the local filesystem.
+//
+// The content of every fetched package is stored as a snapshot in the kpt
+// cache, keyed by its hash. This allows the version of a package that was
+// fetched to be used when updating the package, even if the directory or
+// archive has since been replaced by a new version.
+package local
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/archive"
+)
+
+const hashPrefix = "sha256:"
+
+// PackageSpec specifies a package on the local filesystem.
+type PackageSpec struct {
+	// Path is the path of the directory or .tar.gz archive of the package.
+	Path string
+
+	// Hash is the hash of the content of the package that was copied to Dir.
+	Hash string
+
+	// Dir is the directory the package is copied to.
+	Dir string
+}
+
+// AbsPath is the absolute path to the package.
+func (s PackageSpec) AbsPath() string {
+	return s.Dir
+}
+
+// IsArchive returns true if path refers to a .tar.gz archive.
+func IsArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// Load copies the package at spec.Path to a new temporary directory, which is
+// stored in spec.Dir, and stores the hash of its content in spec.Hash. A
+// relative spec.Path is relative to baseDir. The caller is responsible for
+// removing the directory.
+func Load(spec *PackageSpec, baseDir string) error {
+	const op errors.Op = "local.Load"
+	p := spec.Path
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(baseDir, p)
+	}
+
+	dir, err := ioutil.TempDir("", "kpt-get-")
+	if err != nil {
+		return errors.E(op, errors.Internal, fmt.Errorf("error creating temp directory: %w", err))
+	}
+	if err := load(p, dir); err != nil {
+		os.RemoveAll(dir)
+		return errors.E(op, errors.IO, fmt.Errorf("error reading package %q: %w", spec.Path, err))
+	}
+	// the hash is computed from the extracted files, so that a directory and
+	// an archive of it have the same hash
+	b, err := archive.Tar(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return errors.E(op, errors.IO, err)
+	}
+	sum := sha256.Sum256(b)
+	hash := hashPrefix + hex.EncodeToString(sum[:])
+	if err := storeSnapshot(hash, b); err != nil {
+		os.RemoveAll(dir)
+		return errors.E(op, errors.IO, err)
+	}
+	spec.Hash = hash
+	spec.Dir = dir
+	return nil
+}
+
+// LoadSnapshot copies the snapshot of the package content with the hash
+// spec.Hash to a new temporary directory, which is stored in spec.Dir. If
+// there is no snapshot, the package at spec.Path is used if its content still
+// has the same hash. A relative spec.Path is relative to baseDir.
+func LoadSnapshot(spec *PackageSpec, baseDir string) error {
+	const op errors.Op = "local.LoadSnapshot"
+	snapshot, err := snapshotPath(spec.Hash)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	b, err := ioutil.ReadFile(snapshot)
+	if os.IsNotExist(err) {
+		s := PackageSpec{Path: spec.Path}
+		if err := Load(&s, baseDir); err != nil {
+			return errors.E(op, fmt.Errorf("package %q with hash %s is not available: %w",
+				spec.Path, spec.Hash, err))
+		}
+		if s.Hash != spec.Hash {
+			os.RemoveAll(s.Dir)
+			return errors.E(op, fmt.Errorf("package %q with hash %s is not available: "+
+				"the package has changed since it was fetched", spec.Path, spec.Hash))
+		}
+		spec.Dir = s.Dir
+		return nil
+	}
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+
+	dir, err := ioutil.TempDir("", "kpt-get-")
+	if err != nil {
+		return errors.E(op, errors.Internal, fmt.Errorf("error creating temp directory: %w", err))
+	}
+	if err := archive.Extract(bytes.NewReader(b), dir); err != nil {
+		os.RemoveAll(dir)
+		return errors.E(op, errors.IO, err)
+	}
+	spec.Dir = dir
+	return nil
+}
+
+// load copies the files of the directory or archive p to dir.
+func load(p, dir string) error {
+	if !IsArchive(p) {
+		if err := checkSymlinks(p); err != nil {
+			return err
+		}
+		b, err := archive.Tar(p)
+		if err != nil {
+			return err
+		}
+		return archive.Extract(bytes.NewReader(b), dir)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	return archive.Extract(gr, dir)
+}
+
+// checkSymlinks returns an error if the directory p contains symlinks. They
+// would be skipped by archive.Tar, so the package would silently miss them.
+func checkSymlinks(p string) error {
+	return filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == p {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			rel, err := filepath.Rel(p, path)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("symlinks are not supported in local packages: %q", filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// storeSnapshot stores the tar archive b of the package content with the
+// given hash in the kpt cache, unless it already exists.
+func storeSnapshot(hash string, b []byte) error {
+	p, err := snapshotPath(hash)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	// write to a temp file first, so that an interrupted write doesn't leave a
+	// corrupt snapshot behind
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func snapshotPath(hash string) (string, error) {
+	if !strings.HasPrefix(hash, hashPrefix) {
+		return "", fmt.Errorf("invalid hash %q", hash)
+	}
+	hex := strings.TrimPrefix(hash, hashPrefix)
+	if len(hex) != sha256.Size*2 || strings.ContainsAny(hex, "/\\.") {
+		return "", fmt.Errorf("invalid hash %q", hash)
+	}
+	dir, err := gitutil.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snapshots", hex+".tar"), nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/archive"
+	"github.com/stretchr/testify/assert"
+)
+
+var files = map[string]string{
+	"Kptfile":         "apiVersion: kpt.dev/v1\nkind: Kptfile\n",
+	"deployment.yaml": "kind: Deployment\n",
+	"sub/cm.yaml":     "kind: ConfigMap\n",
+}
+
+// setup writes a package to a new directory, and an archive of it next to
+// the directory. It returns the parent directory.
+func setup(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cacheDir := filepath.Join(dir, "cache")
+	old := os.Getenv(gitutil.RepoCacheDirEnv)
+	os.Setenv(gitutil.RepoCacheDirEnv, cacheDir)
+	t.Cleanup(func() {
+		os.Setenv(gitutil.RepoCacheDirEnv, old)
+		os.RemoveAll(dir)
+	})
+
+	for p, content := range files {
+		p = filepath.Join(dir, "pkg", filepath.FromSlash(p))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+		assert.NoError(t, ioutil.WriteFile(p, []byte(content), 0600))
+	}
+	b, err := archive.Tar(filepath.Join(dir, "pkg"))
+	assert.NoError(t, err)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err = gw.Write(b)
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg.tar.gz"), buf.Bytes(), 0600))
+	return dir
+}
+
+func assertFiles(t *testing.T, dir string) {
+	for p, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+		if assert.NoError(t, err) {
+			assert.Equal(t, content, string(b))
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := setup(t)
+
+	fromDir := &PackageSpec{Path: "pkg"}
+	if !assert.NoError(t, Load(fromDir, dir)) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(fromDir.AbsPath())
+	assertFiles(t, fromDir.AbsPath())
+
+	fromArchive := &PackageSpec{Path: filepath.Join(dir, "pkg.tar.gz")}
+	if !assert.NoError(t, Load(fromArchive, "")) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(fromArchive.AbsPath())
+	assertFiles(t, fromArchive.AbsPath())
+
+	// the directory and the archive of it have the same content
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", fromDir.Hash)
+	assert.Equal(t, fromDir.Hash, fromArchive.Hash)
+}
+
+func TestLoad_symlinks(t *testing.T) {
+	dir := setup(t)
+	if !assert.NoError(t, os.Symlink("deployment.yaml", filepath.Join(dir, "pkg", "sub", "link.yaml"))) {
+		t.FailNow()
+	}
+
+	spec := &PackageSpec{Path: "pkg"}
+	err := Load(spec, dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `symlinks are not supported in local packages: "sub/link.yaml"`)
+	}
+	assert.Empty(t, spec.Dir)
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	dir := setup(t)
+
+	spec := &PackageSpec{Path: "pkg"}
+	if !assert.NoError(t, Load(spec, dir)) {
+		t.FailNow()
+	}
+	os.RemoveAll(spec.AbsPath())
+
+	// the snapshot is used after the package has been replaced
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg", "deployment.yaml"), []byte("kind: StatefulSet\n"), 0600))
+	snapshot := &PackageSpec{Path: "pkg", Hash: spec.Hash}
+	if !assert.NoError(t, LoadSnapshot(snapshot, dir)) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(snapshot.AbsPath())
+	assertFiles(t, snapshot.AbsPath())
+}
+
+func TestLoadSnapshot_noSnapshot(t *testing.T) {
+	dir := setup(t)
+
+	spec := &PackageSpec{Path: "pkg"}
+	if !assert.NoError(t, Load(spec, dir)) {
+		t.FailNow()
+	}
+	os.RemoveAll(spec.AbsPath())
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, "cache")))
+
+	// without a snapshot, the package is used if it hasn't changed
+	unchanged := &PackageSpec{Path: "pkg", Hash: spec.Hash}
+	if !assert.NoError(t, LoadSnapshot(unchanged, dir)) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(unchanged.AbsPath())
+	assertFiles(t, unchanged.AbsPath())
+
+	assert.NoError(t, os.RemoveAll(filepath.Join(dir, "cache")))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pkg", "deployment.yaml"), []byte("kind: StatefulSet\n"), 0600))
+	changed := &PackageSpec{Path: "pkg", Hash: spec.Hash}
+	err := LoadSnapshot(changed, dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the package has changed since it was fetched")
+	}
+}
@@ -0,0 +1,583 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// ResolutionKeptLocal is the resolution of a resource which was deleted
+	// upstream, but kept since it was changed locally.
+	ResolutionKeptLocal = "kept-local"
+
+	// ResolutionTookUpstream is the resolution of a field which was changed
+	// both upstream and locally, and set to the upstream value.
+	ResolutionTookUpstream = "took-upstream"
+
+	// ResolutionTookLocal, ResolutionTookOrigin and ResolutionEdited are the
+	// resolutions of fields chosen by a ConflictResolver to be set to the
+	// local value, the origin value or an edited value.
+	ResolutionTookLocal  = "took-local"
+	ResolutionTookOrigin = "took-origin"
+	ResolutionEdited     = "edited"
+
+	// ResolutionConflictMarkers is the resolution of a non-KRM file which was
+	// changed on the same lines both upstream and locally, and merged with
+	// conflict markers that need to be resolved by the user.
+	ResolutionConflictMarkers = "conflict-markers"
+)
+
+// Conflict is a decision made by a merge for a resource or a field which was
+// changed both upstream and locally.
+type Conflict struct {
+	// Resource identifies the resource by its group, kind, namespace and
+	// name, e.g. apps/Deployment/default/nginx. It is empty for conflicts of
+	// non-KRM files.
+	Resource string `yaml:"resource,omitempty"`
+
+	// File is the slash-separated path of the file of the resource in the
+	// local package.
+	File string `yaml:"file"`
+
+	// Field is the path of the field, e.g. spec.containers[name=nginx].image.
+	// It is empty for conflicts of the whole resource.
+	Field string `yaml:"field,omitempty"`
+
+	// Origin, Local and Upstream are the origin, local and upstream values
+	// of the field. They are empty if the field doesn't exist.
+	Origin   string `yaml:"origin,omitempty"`
+	Local    string `yaml:"local,omitempty"`
+	Upstream string `yaml:"upstream,omitempty"`
+
+	// Resolution is how the merge resolved the conflict.
+	Resolution string `yaml:"resolution"`
+
+	// path is the path of the field.
+	path []pathElement
+}
+
+// pathElement is a field of a mapping, or the element of an associative list
+// with the values of its keys.
+type pathElement struct {
+	field  string
+	keys   []string
+	values []string
+}
+
+func (e pathElement) String() string {
+	if e.field != "" {
+		return e.field
+	}
+	var pairs []string
+	for i := range e.keys {
+		pairs = append(pairs, e.keys[i]+"="+e.values[i])
+	}
+	return "[" + strings.Join(pairs, ",") + "]"
+}
+
+// filter returns the filter looking up the element. If create is set, a
+// missing element is created with kind.
+func (e pathElement) filter(create bool, kind yaml.Kind) yaml.Filter {
+	if e.field != "" {
+		if !create {
+			return yaml.Get(e.field)
+		}
+		return yaml.LookupCreate(kind, e.field)
+	}
+	m := yaml.ElementMatcher{Keys: e.keys, Values: e.values}
+	if create {
+		element := yaml.NewMapRNode(nil)
+		for i := range e.keys {
+			_ = element.PipeE(yaml.SetField(e.keys[i], yaml.NewScalarRNode(e.values[i])))
+		}
+		m.Create = element
+	}
+	return m
+}
+
+// ConflictResolver chooses the values of fields which were changed both
+// upstream and locally.
+type ConflictResolver interface {
+	// Resolve returns the resolution for the field of the conflict. If the
+	// resolution is ResolutionEdited, value is the new value of the field in
+	// YAML, or an empty string if the field should be removed.
+	Resolve(c Conflict) (resolution string, value string, err error)
+}
+
+func (c Conflict) String() string {
+	if c.Resolution == ResolutionConflictMarkers {
+		return fmt.Sprintf("%s: changed both upstream and locally, conflict markers added", c.File)
+	}
+	if c.Field == "" {
+		return fmt.Sprintf("%s (%s): deleted upstream, but kept since it has local changes",
+			c.Resource, c.File)
+	}
+	resolution := map[string]string{
+		ResolutionTookUpstream: "took the upstream value",
+		ResolutionTookLocal:    "took the local value",
+		ResolutionTookOrigin:   "took the origin value",
+		ResolutionEdited:       "edited the value",
+	}[c.Resolution]
+	return fmt.Sprintf("%s (%s): field %s %s locally and %s upstream, %s",
+		c.Resource, c.File, c.Field, describeChange(c.Local), describeChange(c.Upstream), resolution)
+}
+
+func describeChange(v string) string {
+	if v == "" {
+		return "deleted"
+	}
+	return fmt.Sprintf("changed to %q", v)
+}
+
+// ConflictReport collects the conflicts of merges.
+type ConflictReport struct {
+	Conflicts []Conflict `yaml:"conflicts"`
+}
+
+// Add adds conflicts to the report, with the file paths prefixed with dir.
+func (r *ConflictReport) Add(dir string, conflicts ...Conflict) {
+	for _, c := range conflicts {
+		c.File = path.Join(dir, c.File)
+		r.Conflicts = append(r.Conflicts, c)
+	}
+}
+
+// Len returns the number of conflicts in the report.
+func (r *ConflictReport) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.Conflicts)
+}
+
+// Unresolved returns the number of conflicts in the report which were
+// merged with conflict markers, and need to be resolved by the user.
+func (r *ConflictReport) Unresolved() int {
+	if r == nil {
+		return 0
+	}
+	n := 0
+	for _, c := range r.Conflicts {
+		if c.Resolution == ResolutionConflictMarkers {
+			n++
+		}
+	}
+	return n
+}
+
+// keptConflict returns the conflict of a resource which was deleted
+// upstream and kept locally.
+func keptConflict(local *yaml.RNode) (Conflict, error) {
+	c, err := newConflict(local)
+	if err != nil {
+		return c, err
+	}
+	c.Resolution = ResolutionKeptLocal
+	return c, nil
+}
+
+// fieldConflicts returns the conflicts of the fields of a resource which
+// were changed both upstream and locally to different values, following
+// the rules of the 3-way merge. The upstream value is used for those fields,
+// unless a resolver is provided to choose the values. The chosen values are
+// returned to be set in the result of the merge with setResolvedFields.
+func fieldConflicts(origin, upstream, local *yaml.RNode, schema *openapi.ResourceSchema,
+	resolver ConflictResolver) ([]Conflict, []resolvedField, error) {
+	base, err := newConflict(local)
+	if err != nil {
+		return nil, nil, err
+	}
+	var conflicts []Conflict
+	err = walkConflicts(nil, origin, upstream, local, schema, func(path []pathElement, o, u, l string) {
+		c := base
+		c.Field = fieldString(path)
+		c.Origin = o
+		c.Local = l
+		c.Upstream = u
+		c.Resolution = ResolutionTookUpstream
+		c.path = path
+		conflicts = append(conflicts, c)
+	})
+	if err != nil || resolver == nil {
+		return conflicts, nil, err
+	}
+
+	var resolved []resolvedField
+	for i := range conflicts {
+		c := &conflicts[i]
+		resolution, edited, err := resolver.Resolve(*c)
+		if err != nil {
+			return nil, nil, err
+		}
+		var value *yaml.RNode
+		switch resolution {
+		case ResolutionTookUpstream:
+			continue
+		case ResolutionTookLocal:
+			value, err = lookup(local, c.path)
+		case ResolutionTookOrigin:
+			value, err = lookup(origin, c.path)
+		case ResolutionEdited:
+			if strings.TrimSpace(edited) != "" {
+				value, err = yaml.Parse(edited)
+			}
+		default:
+			err = fmt.Errorf("unknown resolution %q", resolution)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if value != nil {
+			// the merge may modify the local value
+			value = yaml.NewRNode(yaml.CopyYNode(value.YNode()))
+		}
+		resolved = append(resolved, resolvedField{path: c.path, value: value})
+		c.Resolution = resolution
+	}
+	return conflicts, resolved, nil
+}
+
+// resolvedField is the value chosen for a conflicting field, or nil if the
+// field is removed.
+type resolvedField struct {
+	path  []pathElement
+	value *yaml.RNode
+}
+
+// setResolvedFields sets the chosen values of the fields in merged, the
+// result of the merge.
+func setResolvedFields(merged *yaml.RNode, fields []resolvedField) error {
+	for _, f := range fields {
+		if err := setField(merged, f.path, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldString returns the path of a field for display, e.g.
+// spec.containers[name=nginx].image.
+func fieldString(path []pathElement) string {
+	var b strings.Builder
+	for i, p := range path {
+		if i > 0 && p.field != "" {
+			b.WriteString(".")
+		}
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// lookup returns the value of the field at path, or nil if it doesn't
+// exist.
+func lookup(n *yaml.RNode, path []pathElement) (*yaml.RNode, error) {
+	if yaml.IsMissingOrNull(n) {
+		return nil, nil
+	}
+	var filters []yaml.Filter
+	for _, p := range path {
+		filters = append(filters, p.filter(false, 0))
+	}
+	v, err := n.Pipe(filters...)
+	if err != nil || yaml.IsMissingOrNull(v) {
+		return nil, err
+	}
+	return v, nil
+}
+
+// setField sets the field at path to a copy of value, or removes the field
+// if value is nil. The field is always a field of a mapping, since the
+// elements of lists are walked by their fields.
+func setField(n *yaml.RNode, path []pathElement, value *yaml.RNode) error {
+	last := len(path) - 1
+	if value == nil {
+		parent, err := lookup(n, path[:last])
+		if err != nil || parent == nil {
+			return err
+		}
+		_, err = parent.Pipe(yaml.Clear(path[last].field))
+		return err
+	}
+	var filters []yaml.Filter
+	for i, p := range path[:last] {
+		kind := yaml.MappingNode
+		if path[i+1].field == "" {
+			kind = yaml.SequenceNode
+		}
+		filters = append(filters, p.filter(true, kind))
+	}
+	parent, err := n.Pipe(filters...)
+	if err != nil {
+		return err
+	}
+	return parent.PipeE(yaml.SetField(path[last].field, yaml.NewRNode(yaml.CopyYNode(value.YNode()))))
+}
+
+func newConflict(local *yaml.RNode) (Conflict, error) {
+	meta, err := local.GetMeta()
+	if err != nil {
+		return Conflict{}, err
+	}
+	id := []string{meta.Kind, meta.Name}
+	if meta.Namespace != "" {
+		id = []string{meta.Kind, meta.Namespace, meta.Name}
+	}
+	if group := resolveGroup(meta); group != "" {
+		id = append([]string{group}, id...)
+	}
+	return Conflict{
+		Resource: strings.Join(id, "/"),
+		File:     meta.Annotations[kioutil.PathAnnotation],
+	}, nil
+}
+
+// walkConflicts walks the origin, upstream and local values of a field and
+// calls found for every value which was changed both upstream and locally.
+// Mappings and associative lists are walked recursively, other values are
+// compared as a whole like the merge does. The keys of associative lists are
+// taken from schema, lists without a schema are compared as a whole.
+func walkConflicts(field []pathElement, origin, upstream, local *yaml.RNode, schema *openapi.ResourceSchema,
+	found func(path []pathElement, origin, upstream, local string)) error {
+	nodes := []*yaml.RNode{origin, upstream, local}
+	switch {
+	case allKind(yaml.MappingNode, nodes...):
+		keys := map[string]bool{}
+		for _, n := range nodes {
+			if yaml.IsMissingOrNull(n) {
+				continue
+			}
+			fields, err := n.Fields()
+			if err != nil {
+				return err
+			}
+			for _, f := range fields {
+				keys[f] = true
+			}
+		}
+		for _, k := range sortedKeys(keys) {
+			if len(field) == 2 && field[0].field == yaml.MetadataField &&
+				field[1].field == yaml.AnnotationsField && isMergeAnnotation(k) {
+				continue
+			}
+			err := walkConflicts(append(field, pathElement{field: k}), fieldValue(origin, k),
+				fieldValue(upstream, k), fieldValue(local, k), fieldSchema(schema, k), found)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case allKind(yaml.SequenceNode, nodes...):
+		strategy, keys := schemaListKeys(schema)
+		if strategy == "merge" && len(keys) == 0 {
+			// the values of sets are merged, so they don't conflict
+			return nil
+		}
+		if len(keys) > 0 {
+			for _, e := range listElements(keys, nodes...) {
+				elementField := append(append([]pathElement{}, field...), e)
+				err := walkConflicts(elementField, element(origin, e), element(upstream, e),
+					element(local, e), elementSchema(schema), found)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	o, err := valueString(origin)
+	if err != nil {
+		return err
+	}
+	u, err := valueString(upstream)
+	if err != nil {
+		return err
+	}
+	l, err := valueString(local)
+	if err != nil {
+		return err
+	}
+	if o != u && o != l && u != l {
+		found(append([]pathElement{}, field...), o, u, l)
+	}
+	return nil
+}
+
+func fieldValue(n *yaml.RNode, field string) *yaml.RNode {
+	if yaml.IsMissingOrNull(n) {
+		return nil
+	}
+	f := n.Field(field)
+	if f == nil {
+		return nil
+	}
+	return f.Value
+}
+
+// allKind returns true if the nodes which are present are of kind, and at
+// least one node is present.
+func allKind(kind yaml.Kind, nodes ...*yaml.RNode) bool {
+	present := false
+	for _, n := range nodes {
+		if yaml.IsMissingOrNull(n) {
+			continue
+		}
+		if n.YNode().Kind != kind {
+			return false
+		}
+		present = true
+	}
+	return present
+}
+
+func elements(list *yaml.RNode) []*yaml.RNode {
+	if yaml.IsMissingOrNull(list) {
+		return nil
+	}
+	var elements []*yaml.RNode
+	for _, e := range list.Content() {
+		elements = append(elements, yaml.NewRNode(e))
+	}
+	return elements
+}
+
+// listElements returns the elements of the associative lists with keys,
+// identified like the merge does: keys which none of the elements have are
+// ignored, and elements which only differ in keys missing from one of them
+// are the same element.
+func listElements(keys []string, lists ...*yaml.RNode) []pathElement {
+	var valuesList [][]string
+	for _, l := range lists {
+		if yaml.IsMissingOrNull(l) {
+			continue
+		}
+		values, err := l.ElementValuesList(keys)
+		if err != nil {
+			continue
+		}
+		valuesList = append(valuesList, values...)
+	}
+
+	present := make([]bool, len(keys))
+	for _, values := range valuesList {
+		for i, v := range values {
+			if v != "" {
+				present[i] = true
+			}
+		}
+	}
+	for i := range valuesList {
+		for j := range valuesList {
+			if values, matched := matchValues(valuesList[i], valuesList[j]); matched {
+				valuesList[i], valuesList[j] = values, values
+			}
+		}
+	}
+
+	seen := map[string]pathElement{}
+	for _, values := range valuesList {
+		var e pathElement
+		for i := range keys {
+			if present[i] {
+				e.keys = append(e.keys, keys[i])
+				e.values = append(e.values, values[i])
+			}
+		}
+		if len(e.keys) > 0 {
+			seen[e.String()] = e
+		}
+	}
+	var ids []string
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var elements []pathElement
+	for _, id := range ids {
+		elements = append(elements, seen[id])
+	}
+	return elements
+}
+
+// matchValues returns the values of keys of two elements combined, and
+// whether they are the same element: they must have a common value, and can
+// only differ in values missing from one of them.
+func matchValues(values1, values2 []string) ([]string, bool) {
+	common := false
+	var values []string
+	for i := range values1 {
+		switch {
+		case values1[i] == values2[i]:
+			common = true
+			values = append(values, values1[i])
+		case values1[i] == "":
+			values = append(values, values2[i])
+		case values2[i] == "":
+			values = append(values, values1[i])
+		default:
+			return nil, false
+		}
+	}
+	return values, common
+}
+
+func element(list *yaml.RNode, e pathElement) *yaml.RNode {
+	if yaml.IsMissingOrNull(list) {
+		return nil
+	}
+	return list.ElementList(e.keys, e.values)
+}
+
+// valueString returns the value of n in flow style, or an empty string if
+// it is missing.
+func valueString(n *yaml.RNode) (string, error) {
+	if yaml.IsMissingOrNull(n) {
+		return "", nil
+	}
+	if n.YNode().Kind == yaml.ScalarNode {
+		return n.YNode().Value, nil
+	}
+	c := yaml.CopyYNode(n.YNode())
+	c.Style = yaml.FlowStyle
+	s, err := yaml.NewRNode(c).String()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// isMergeAnnotation returns true if the annotation is set by kyaml while
+// reading and merging the resources.
+func isMergeAnnotation(a string) bool {
+	return a == mergeSourceAnnotation || a == kioutil.PathAnnotation ||
+		a == kioutil.IndexAnnotation
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,240 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestFieldConflicts(t *testing.T) {
+	origin := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  annotations:
+    config.kubernetes.io/path: deployment.yaml
+spec:
+  replicas: 3
+  paused: false
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.19
+        args: [a, b]
+      - name: sidecar
+        image: sidecar:1.0
+`
+	upstream := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  annotations:
+    config.kubernetes.io/path: deployment.yaml
+spec:
+  replicas: 5
+  paused: true
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.21
+        args: [a, c]
+      - name: sidecar
+        image: sidecar:2.0
+`
+	local := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+  namespace: default
+  annotations:
+    config.kubernetes.io/path: deployment.yaml
+    config.kubernetes.io/index: '1'
+spec:
+  replicas: 7
+  paused: true
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.20
+        args: [a, d]
+      - name: sidecar
+        image: sidecar:1.0
+`
+	localNode := yaml.MustParse(local)
+	conflicts, _, err := fieldConflicts(yaml.MustParse(origin), yaml.MustParse(upstream), localNode, builtinSchema(localNode), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	base := Conflict{
+		Resource:   "apps/Deployment/default/nginx",
+		File:       "deployment.yaml",
+		Resolution: ResolutionTookUpstream,
+	}
+	expected := []Conflict{
+		{Field: "spec.replicas", Origin: "3", Local: "7", Upstream: "5"},
+		{Field: "spec.template.spec.containers[name=nginx].args", Origin: "[a, b]", Local: "[a, d]", Upstream: "[a, c]"},
+		{Field: "spec.template.spec.containers[name=nginx].image", Origin: "nginx:1.19", Local: "nginx:1.20", Upstream: "nginx:1.21"},
+	}
+	for i := range conflicts {
+		conflicts[i].path = nil
+	}
+	for i := range expected {
+		expected[i].Resource = base.Resource
+		expected[i].File = base.File
+		expected[i].Resolution = base.Resolution
+	}
+	assert.Equal(t, expected, conflicts)
+}
+
+func TestFieldConflicts_deletedLocally(t *testing.T) {
+	origin := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  foo: bar
+`
+	upstream := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  foo: baz
+`
+	local := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data: {}
+`
+	conflicts, _, err := fieldConflicts(yaml.MustParse(origin), yaml.MustParse(upstream), yaml.MustParse(local), nil, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	conflicts[0].path = nil
+	assert.Equal(t, []Conflict{
+		{
+			Resource:   "ConfigMap/cm",
+			Field:      "data.foo",
+			Origin:     "bar",
+			Upstream:   "baz",
+			Resolution: ResolutionTookUpstream,
+		},
+	}, conflicts)
+	assert.Equal(t, `ConfigMap/cm (): field data.foo deleted locally and changed to "baz" upstream, took the upstream value`,
+		conflicts[0].String())
+}
+
+type fakeResolver map[string][2]string
+
+func (r fakeResolver) Resolve(c Conflict) (string, string, error) {
+	choice := r[c.Field]
+	return choice[0], choice[1], nil
+}
+
+func TestFieldConflicts_resolver(t *testing.T) {
+	origin := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: origin
+  b: origin
+  c: origin
+  d: origin
+  e: origin
+`
+	upstream := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: upstream
+  b: upstream
+  c: upstream
+  d: upstream
+  e: upstream
+`
+	local := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  a: local
+  b: local
+  c: local
+  d: local
+  e: local
+`
+	resolver := fakeResolver{
+		"data.a": {ResolutionTookUpstream, ""},
+		"data.b": {ResolutionTookLocal, ""},
+		"data.c": {ResolutionTookOrigin, ""},
+		"data.d": {ResolutionEdited, "edited"},
+		"data.e": {ResolutionEdited, ""},
+	}
+	o, u, l := yaml.MustParse(origin), yaml.MustParse(upstream), yaml.MustParse(local)
+	conflicts, resolved, err := fieldConflicts(o, u, l, nil, resolver)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var resolutions []string
+	for _, c := range conflicts {
+		resolutions = append(resolutions, c.Resolution)
+	}
+	assert.Equal(t, []string{ResolutionTookUpstream, ResolutionTookLocal,
+		ResolutionTookOrigin, ResolutionEdited, ResolutionEdited}, resolutions)
+
+	// The upstream value is left to the merge, the other choices are set
+	// in its result.
+	if !assert.NoError(t, mergeWithSchema(o, u, l, nil)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, setResolvedFields(l, resolved)) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]string{
+		"a": "upstream",
+		"b": "local",
+		"c": "origin",
+		"d": "edited",
+	}, l.GetDataMap())
+}
+
+func TestFieldConflicts_resolverError(t *testing.T) {
+	cm := func(v string) *yaml.RNode {
+		return yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata:\n  a: " + v + "\n")
+	}
+	_, _, err := fieldConflicts(cm("origin"), cm("upstream"), cm("local"), nil,
+		fakeResolver{"data.a": {"unknown", ""}})
+	assert.EqualError(t, err, `unknown resolution "unknown"`)
+}
@@ -47,6 +47,19 @@ type Merge3 struct {
 	MatchFilesGlob     []string
 	MergeOnPath        bool
 	IncludeSubPackages bool
+
+	// Conflicts collects the resources and fields which were changed both
+	// upstream and locally, and how they were merged. It is optional.
+	Conflicts *ConflictReport
+
+	// Resolver chooses the values of fields which were changed both upstream
+	// and locally. The upstream values are used if it isn't set.
+	Resolver ConflictResolver
+
+	// Schemas are the schemas used to merge the lists of custom resources.
+	// The schemas of the CustomResourceDefinitions in the packages are
+	// added to them, unless a schema for the same type is provided.
+	Schemas Schemas
 }
 
 func (m Merge3) Merge() error {
@@ -99,16 +112,37 @@ func (m Merge3) Merge() error {
 		Exclusions: relPaths,
 	})
 
-	rmMatcher := ResourceMergeMatcher{MergeOnPath: m.MergeOnPath}
-	resourceHandler := resourceHandler{}
+	renames := renames{}
+	rmMatcher := ResourceMergeMatcher{MergeOnPath: m.MergeOnPath, renames: renames}
+	schemas := Schemas{}
+	resourceHandler := resourceHandler{
+		conflicts: m.Conflicts,
+		resolver:  m.Resolver,
+		schemas:   schemas,
+		renames:   renames,
+	}
 	kyamlMerge := filters.Merge3{
 		Matcher: &rmMatcher,
 		Handler: &resourceHandler,
 	}
+	// the schemas of the CRDs must be known before any resource is merged
+	addSchemas := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		if err := schemas.addCRDs(nodes); err != nil {
+			return nil, err
+		}
+		for t, s := range m.Schemas {
+			schemas[t] = s
+		}
+		return nodes, nil
+	})
+	// the renames must be found before the resources are matched
+	findRenames := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		return nodes, renames.find(nodes, &rmMatcher)
+	})
 
 	return kio.Pipeline{
 		Inputs:  inputs,
-		Filters: []kio.Filter{kyamlMerge},
+		Filters: []kio.Filter{addSchemas, findRenames, kyamlMerge},
 		Outputs: []kio.Writer{dest},
 	}.Execute()
 }
@@ -174,17 +208,26 @@ func (p PruningLocalPackageReader) isExcluded(path string) bool {
 
 type ResourceMergeMatcher struct {
 	MergeOnPath bool
+
+	// renames are the resources renamed upstream, which match the original
+	// resources regardless of their identity.
+	renames renames
 }
 
 // IsSameResource determines if 2 resources are same to be merged by matching GKNN+filepath
 // Group, Kind are derived from resource metadata directly, Namespace and Name are derived
 // from merge comment which is of format "kpt-merge: namespace/name", if the merge comment
-// is not present, then it falls back to Namespace and Name on the resource meta
+// is not present, then it falls back to Namespace and Name on the resource meta.
+// Resources renamed upstream match their original resources.
 func (rm *ResourceMergeMatcher) IsSameResource(node1, node2 *yaml.RNode) bool {
 	if node1 == nil || node2 == nil {
 		return false
 	}
 
+	if rm.renames.isRename(node1, node2) {
+		return true
+	}
+
 	meta1, err := node1.GetMeta()
 	if err != nil {
 		return false
@@ -283,6 +326,21 @@ func metadataComment(node *yaml.RNode) string {
 // there is no diff between origin and local.
 type resourceHandler struct {
 	keptResources []*yaml.RNode
+
+	// conflicts collects the merge decisions for resources and fields which
+	// were changed both upstream and locally. It is optional.
+	conflicts *ConflictReport
+
+	// resolver chooses the values of fields which were changed both
+	// upstream and locally. It is optional.
+	resolver ConflictResolver
+
+	// schemas are used to merge the resources of types without built in
+	// schemas.
+	schemas Schemas
+
+	// renames are the resources renamed upstream.
+	renames renames
 }
 
 func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.ResourceMergeStrategy, error) {
@@ -308,12 +366,55 @@ func (r *resourceHandler) Handle(origin, upstream, local *yaml.RNode) (filters.R
 		} else {
 			r.keptResources = append(r.keptResources, local)
 			strategy = filters.KeepDest
+			if r.conflicts != nil {
+				c, err := keptConflict(local)
+				if err != nil {
+					return strategy, err
+				}
+				r.conflicts.Add("", c)
+			}
 		}
 	// Do not re-add if deleted from local.
 	case origin != nil && local == nil:
 		strategy = filters.Skip
 	default:
 		strategy = filters.Merge
+		if r.renames.isRename(origin, upstream) {
+			// the local resource follows the rename, and must match the
+			// renamed resource in later updates
+			if err := setIdentity(local, upstream); err != nil {
+				return strategy, err
+			}
+		}
+		schema := r.schemas.forResource(local)
+		var resolved []resolvedField
+		if r.conflicts != nil || r.resolver != nil {
+			conflictSchema := schema
+			if conflictSchema == nil {
+				conflictSchema = builtinSchema(local)
+			}
+			var conflicts []Conflict
+			var err error
+			conflicts, resolved, err = fieldConflicts(origin, upstream, local, conflictSchema, r.resolver)
+			if err != nil {
+				return strategy, err
+			}
+			if r.conflicts != nil {
+				r.conflicts.Add("", conflicts...)
+			}
+		}
+		if schema != nil || len(resolved) > 0 {
+			// the merge of kyaml only uses the built in schemas, and the
+			// values chosen for conflicts are set in its result, so these
+			// resources are merged here and kept as is
+			if err := mergeWithSchema(origin, upstream, local, schema); err != nil {
+				return strategy, err
+			}
+			if err := setResolvedFields(local, resolved); err != nil {
+				return strategy, err
+			}
+			strategy = filters.KeepDest
+		}
 	}
 	return strategy, nil
 }
@@ -577,3 +577,956 @@ spec:
 		})
 	}
 }
+
+func TestMerge3_schemas(t *testing.T) {
+	crd := `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: apps.example.com
+spec:
+  group: example.com
+  names:
+    kind: App
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              containers:
+                type: array
+                x-kubernetes-list-type: map
+                x-kubernetes-list-map-keys: [name]
+                items:
+                  type: object
+                  properties:
+                    name:
+                      type: string
+                    image:
+                      type: string
+              tags:
+                type: array
+                x-kubernetes-list-type: set
+                items:
+                  type: string
+`
+	openAPI := `
+definitions:
+  com.example.v1.App:
+    x-kubernetes-group-version-kind:
+    - group: example.com
+      version: v1
+      kind: App
+    type: object
+    properties:
+      spec:
+        $ref: '#/definitions/com.example.v1.AppSpec'
+  com.example.v1.AppSpec:
+    type: object
+    properties:
+      containers:
+        type: array
+        x-kubernetes-list-type: map
+        x-kubernetes-list-map-keys: [name]
+        items:
+          type: object
+      tags:
+        type: array
+        x-kubernetes-list-type: set
+        items:
+          type: string
+`
+	origin := `
+apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  containers:
+  - name: web
+    image: web:1
+  - name: log
+    image: log:1
+  tags: [a]
+`
+	upstream := `
+apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  containers:
+  - name: web
+    image: web:2
+  - name: log
+    image: log:1
+  tags: [a, b]
+`
+	local := `
+apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  containers:
+  - name: web
+    image: web:1
+  - name: log
+    image: log:1
+  - name: sidecar
+    image: sidecar:1
+  tags: [a, c]
+`
+	merged := `apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  containers:
+  - name: web
+    image: web:2
+  - name: log
+    image: log:1
+  - name: sidecar
+    image: sidecar:1
+  tags: [a, c, b]
+`
+
+	testCases := map[string]struct {
+		crd      string
+		openAPI  string
+		expected string
+	}{
+		"lists are merged with the schema of the CRD in the package": {
+			crd:      crd,
+			expected: merged,
+		},
+		"lists are merged with the schema from OpenAPI": {
+			openAPI:  openAPI,
+			expected: merged,
+		},
+		"lists are replaced without a schema": {
+			expected: strings.TrimSpace(upstream) + "\n",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range map[string]string{
+				"originalDir": origin,
+				"updatedDir":  upstream,
+				"localDir":    local,
+			} {
+				if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0700)) {
+					t.FailNow()
+				}
+				err := ioutil.WriteFile(filepath.Join(dir, name, "app.yaml"), []byte(strings.TrimSpace(content)), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				if tc.crd != "" {
+					err = ioutil.WriteFile(filepath.Join(dir, name, "crd.yaml"), []byte(strings.TrimSpace(tc.crd)), 0600)
+					if !assert.NoError(t, err) {
+						t.FailNow()
+					}
+				}
+			}
+			schemas := merge.Schemas{}
+			if tc.openAPI != "" {
+				if !assert.NoError(t, schemas.AddOpenAPI(yaml.MustParse(tc.openAPI))) {
+					t.FailNow()
+				}
+			}
+
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+				Schemas:      schemas,
+			}.Merge()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, "localDir", "app.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+}
+
+// TestMerge3_multipleKeys verifies that the elements of lists with several
+// list map keys are merged by all of the keys.
+func TestMerge3_multipleKeys(t *testing.T) {
+	crd := `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: routers.example.com
+spec:
+  group: example.com
+  names:
+    kind: Router
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              ports:
+                type: array
+                x-kubernetes-list-type: map
+                x-kubernetes-list-map-keys: [containerPort, protocol]
+                items:
+                  type: object
+                  properties:
+                    containerPort:
+                      type: integer
+                    protocol:
+                      type: string
+                    name:
+                      type: string
+`
+	origin := `
+apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - containerPort: 53
+    protocol: TCP
+    name: dns-tcp
+  - containerPort: 53
+    protocol: UDP
+    name: dns-udp
+`
+	upstream := `
+apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - containerPort: 53
+    protocol: TCP
+    name: dns-tcp
+  - containerPort: 53
+    protocol: UDP
+    name: dns
+`
+	local := `
+apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - containerPort: 53
+    protocol: TCP
+    name: tcp
+  - containerPort: 53
+    protocol: UDP
+    name: dns-udp
+`
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"originalDir": origin,
+		"updatedDir":  upstream,
+		"localDir":    local,
+	} {
+		if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(dir, name, "router.yaml"), []byte(strings.TrimSpace(content)), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, name, "crd.yaml"), []byte(strings.TrimSpace(crd)), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	conflicts := &merge.ConflictReport{}
+	err := merge.Merge3{
+		OriginalPath: filepath.Join(dir, "originalDir"),
+		UpdatedPath:  filepath.Join(dir, "updatedDir"),
+		DestPath:     filepath.Join(dir, "localDir"),
+		MergeOnPath:  true,
+		Conflicts:    conflicts,
+	}.Merge()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "localDir", "router.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - containerPort: 53
+    protocol: TCP
+    name: tcp
+  - containerPort: 53
+    protocol: UDP
+    name: dns
+`, string(b))
+	assert.Empty(t, conflicts.Conflicts)
+}
+
+type resolverFunc func(merge.Conflict) (string, string, error)
+
+func (f resolverFunc) Resolve(c merge.Conflict) (string, string, error) {
+	return f(c)
+}
+
+// TestMerge3_conflicts verifies that the lists of custom resources are merged
+// by the same keys the conflicts are found with.
+func TestMerge3_conflicts(t *testing.T) {
+	crd := `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: policies.example.com
+spec:
+  group: example.com
+  names:
+    kind: Policy
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              rules:
+                type: array
+                x-kubernetes-list-type: map
+                x-kubernetes-list-map-keys: [type]
+                items:
+                  type: object
+                  properties:
+                    type:
+                      type: string
+                    value:
+                      type: string
+`
+	origin := `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "1"
+  - type: b
+    value: "1"
+`
+	testCases := map[string]struct {
+		crd       string
+		upstream  string
+		local     string
+		resolver  merge.ConflictResolver
+		expected  string
+		conflicts []merge.Conflict
+	}{
+		"elements changed upstream and locally": {
+			crd: crd,
+			upstream: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "1"
+`,
+			local: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "1"
+  - type: b
+    value: "3"
+`,
+			expected: `apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "3"
+`,
+		},
+		"element changed upstream and locally": {
+			crd: crd,
+			upstream: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "1"
+`,
+			local: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "3"
+  - type: b
+    value: "3"
+`,
+			expected: `apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "3"
+`,
+			conflicts: []merge.Conflict{{
+				Resource:   "example.com/Policy/policy",
+				File:       "policy.yaml",
+				Field:      "spec.rules[type=a].value",
+				Origin:     "1",
+				Upstream:   "2",
+				Local:      "3",
+				Resolution: merge.ResolutionTookUpstream,
+			}},
+		},
+		"element conflict resolved with the local value": {
+			crd: crd,
+			upstream: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "2"
+`,
+			local: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "3"
+  - type: b
+    value: "1"
+`,
+			resolver: resolverFunc(func(merge.Conflict) (string, string, error) {
+				return merge.ResolutionTookLocal, "", nil
+			}),
+			expected: `apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "3"
+  - type: b
+    value: "2"
+`,
+			conflicts: []merge.Conflict{{
+				Resource:   "example.com/Policy/policy",
+				File:       "policy.yaml",
+				Field:      "spec.rules[type=a].value",
+				Origin:     "1",
+				Upstream:   "2",
+				Local:      "3",
+				Resolution: merge.ResolutionTookLocal,
+			}},
+		},
+		"lists without a schema are compared as a whole": {
+			upstream: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "1"
+`,
+			local: `
+apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "1"
+  - type: b
+    value: "3"
+`,
+			expected: `apiVersion: example.com/v1
+kind: Policy
+metadata:
+  name: policy
+spec:
+  rules:
+  - type: a
+    value: "2"
+  - type: b
+    value: "1"
+`,
+			conflicts: []merge.Conflict{{
+				Resource:   "example.com/Policy/policy",
+				File:       "policy.yaml",
+				Field:      "spec.rules",
+				Origin:     `[{type: a, value: "1"}, {type: b, value: "1"}]`,
+				Upstream:   `[{type: a, value: "2"}, {type: b, value: "1"}]`,
+				Local:      `[{type: a, value: "1"}, {type: b, value: "3"}]`,
+				Resolution: merge.ResolutionTookUpstream,
+			}},
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range map[string]string{
+				"originalDir": origin,
+				"updatedDir":  tc.upstream,
+				"localDir":    tc.local,
+			} {
+				if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0700)) {
+					t.FailNow()
+				}
+				err := ioutil.WriteFile(filepath.Join(dir, name, "policy.yaml"), []byte(strings.TrimSpace(content)), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				if tc.crd != "" {
+					err = ioutil.WriteFile(filepath.Join(dir, name, "crd.yaml"), []byte(strings.TrimSpace(tc.crd)), 0600)
+					if !assert.NoError(t, err) {
+						t.FailNow()
+					}
+				}
+			}
+
+			conflicts := &merge.ConflictReport{}
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+				Conflicts:    conflicts,
+				Resolver:     tc.resolver,
+			}.Merge()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b, err := ioutil.ReadFile(filepath.Join(dir, "localDir", "policy.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, string(b))
+			var actual []merge.Conflict
+			for _, c := range conflicts.Conflicts {
+				actual = append(actual, merge.Conflict{
+					Resource:   c.Resource,
+					File:       c.File,
+					Field:      c.Field,
+					Origin:     c.Origin,
+					Upstream:   c.Upstream,
+					Local:      c.Local,
+					Resolution: c.Resolution,
+				})
+			}
+			assert.Equal(t, tc.conflicts, actual)
+		})
+	}
+}
+
+// TestMerge3_builtinSchemas verifies that built in resources are merged by
+// the default merge when conflicts are reported.
+func TestMerge3_builtinSchemas(t *testing.T) {
+	origin := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        args: [a]
+        ports:
+        - containerPort: 80
+`
+	upstream := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+        args: [a, b]
+        ports:
+        - containerPort: 80
+        - containerPort: 443
+      - name: sidecar
+        image: sidecar:1
+`
+	local := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+        args: [c]
+        env:
+        - name: FOO
+          value: bar
+        ports:
+        - containerPort: 80
+          name: http
+`
+	for _, withConflicts := range []bool{false, true} {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"originalDir": origin,
+			"updatedDir":  upstream,
+			"localDir":    local,
+		} {
+			if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0700)) {
+				t.FailNow()
+			}
+			err := ioutil.WriteFile(filepath.Join(dir, name, "app.yaml"), []byte(strings.TrimSpace(content)), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+		}
+		m := merge.Merge3{
+			OriginalPath: filepath.Join(dir, "originalDir"),
+			UpdatedPath:  filepath.Join(dir, "updatedDir"),
+			DestPath:     filepath.Join(dir, "localDir"),
+			MergeOnPath:  true,
+		}
+		if withConflicts {
+			m.Conflicts = &merge.ConflictReport{}
+		}
+		if !assert.NoError(t, m.Merge()) {
+			t.FailNow()
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, "localDir", "app.yaml"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+        args: [a, b]
+        env:
+        - name: FOO
+          value: bar
+        ports:
+        - containerPort: 80
+          name: http
+        - containerPort: 443
+      - image: sidecar:1
+        name: sidecar
+`, string(b))
+	}
+}
+
+func TestMerge3_renames(t *testing.T) {
+	testCases := map[string]struct {
+		origin   map[string]string
+		upstream map[string]string
+		local    map[string]string
+		expected map[string]string
+	}{
+		"local changes are kept when upstream renames a resource": {
+			origin: map[string]string{
+				"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+`,
+			},
+			upstream: map[string]string{
+				"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/bar
+  name: bar
+  namespace: default
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+`,
+			},
+			local: map[string]string{
+				"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+`,
+			},
+			expected: map[string]string{
+				"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/bar
+  name: bar
+  namespace: default
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+`,
+			},
+		},
+		"resources with the same identity are matched across files": {
+			origin: map[string]string{
+				"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 1
+`,
+			},
+			upstream: map[string]string{
+				"app/deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 1
+  paused: true
+`,
+			},
+			local: map[string]string{
+				"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 3
+`,
+			},
+			expected: map[string]string{
+				"app/deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+spec:
+  replicas: 3
+  paused: true
+`,
+			},
+		},
+		"dissimilar resources are not renames": {
+			origin: map[string]string{
+				"cm.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+data:
+  a: "1"
+`,
+			},
+			upstream: map[string]string{
+				"cm.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata: # kpt-merge: default/bar
+  name: bar
+  namespace: default
+data:
+  b: "2"
+  c: "3"
+`,
+			},
+			local: map[string]string{
+				"cm.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+data:
+  a: "2"
+`,
+			},
+			expected: map[string]string{
+				"cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata: # kpt-merge: default/foo
+  name: foo
+  namespace: default
+data:
+  a: "2"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata: # kpt-merge: default/bar
+  name: bar
+  namespace: default
+data:
+  b: "2"
+  c: "3"
+`,
+			},
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, files := range map[string]map[string]string{
+				"originalDir": tc.origin,
+				"updatedDir":  tc.upstream,
+				"localDir":    tc.local,
+			} {
+				for file, content := range files {
+					p := filepath.Join(dir, name, file)
+					if !assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700)) {
+						t.FailNow()
+					}
+					if !assert.NoError(t, ioutil.WriteFile(p, []byte(strings.TrimSpace(content)), 0600)) {
+						t.FailNow()
+					}
+				}
+			}
+
+			err := merge.Merge3{
+				OriginalPath: filepath.Join(dir, "originalDir"),
+				UpdatedPath:  filepath.Join(dir, "updatedDir"),
+				DestPath:     filepath.Join(dir, "localDir"),
+				MergeOnPath:  true,
+			}.Merge()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			actual := map[string]string{}
+			err = filepath.Walk(filepath.Join(dir, "localDir"), func(p string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				b, err := ioutil.ReadFile(p)
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(filepath.Join(dir, "localDir"), p)
+				if err != nil {
+					return err
+				}
+				actual[filepath.ToSlash(rel)] = string(b)
+				return nil
+			})
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// renameSimilarity is the minimum similarity of the contents of a resource
+// deleted from upstream and a resource of the same kind added to upstream
+// for the latter to be considered a rename of the former.
+const renameSimilarity = 0.6
+
+// renames maps the resources added to upstream which are renames of
+// resources deleted from upstream to the original resources.
+type renames map[*yaml.RNode]*yaml.RNode
+
+// find finds the resources renamed upstream in the nodes from origin,
+// upstream and local. A resource added to upstream is a rename of a
+// resource deleted from upstream if both have the same group and kind, and
+// either the same kpt-merge identity, or similar contents apart from the
+// name. Resources whose new identity also exists locally aren't renames.
+func (r renames) find(nodes []*yaml.RNode, matcher *ResourceMergeMatcher) error {
+	var origin, upstream, local []*yaml.RNode
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return err
+		}
+		switch meta.Annotations[mergeSourceAnnotation] {
+		case mergeSourceOriginal:
+			origin = append(origin, n)
+		case mergeSourceUpdated:
+			upstream = append(upstream, n)
+		case mergeSourceDest:
+			local = append(local, n)
+		}
+	}
+	deleted := unmatched(origin, upstream, matcher)
+	added := unmatched(unmatched(upstream, origin, matcher), local, matcher)
+	if len(deleted) == 0 || len(added) == 0 {
+		return nil
+	}
+
+	type candidate struct {
+		deleted, added int
+		similarity     float64
+	}
+	var candidates []candidate
+	for i, d := range deleted {
+		for j, a := range added {
+			similarity, err := renameScore(d, a)
+			if err != nil {
+				return err
+			}
+			if similarity >= renameSimilarity {
+				candidates = append(candidates, candidate{deleted: i, added: j, similarity: similarity})
+			}
+		}
+	}
+	// pair the most similar resources first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+	pairedDeleted, pairedAdded := map[int]bool{}, map[int]bool{}
+	for _, c := range candidates {
+		if pairedDeleted[c.deleted] || pairedAdded[c.added] {
+			continue
+		}
+		pairedDeleted[c.deleted], pairedAdded[c.added] = true, true
+		r[added[c.added]] = deleted[c.deleted]
+	}
+	return nil
+}
+
+// isRename returns true if one of the nodes is a rename of the other.
+func (r renames) isRename(node1, node2 *yaml.RNode) bool {
+	if node1 == nil || node2 == nil {
+		return false
+	}
+	return r[node1] == node2 || r[node2] == node1
+}
+
+// unmatched returns the nodes which don't match any of others.
+func unmatched(nodes, others []*yaml.RNode, matcher *ResourceMergeMatcher) []*yaml.RNode {
+	var result []*yaml.RNode
+	for _, n := range nodes {
+		found := false
+		for _, o := range others {
+			if matcher.IsSameResource(n, o) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// renameScore returns how likely it is that added is a rename of deleted,
+// from 0 to 2. Resources with the same kpt-merge identity, which only fail
+// to match because they were moved to another file, score 2. Otherwise the
+// score is the similarity of their contents without the identity.
+func renameScore(deleted, added *yaml.RNode) (float64, error) {
+	deletedMeta, err := deleted.GetMeta()
+	if err != nil {
+		return 0, err
+	}
+	addedMeta, err := added.GetMeta()
+	if err != nil {
+		return 0, err
+	}
+	if resolveGroup(deletedMeta) != resolveGroup(addedMeta) || deletedMeta.Kind != addedMeta.Kind {
+		return 0, nil
+	}
+	if identity(deleted, deletedMeta) == identity(added, addedMeta) {
+		return 2, nil
+	}
+
+	deletedContent, err := renameContent(deleted)
+	if err != nil {
+		return 0, err
+	}
+	addedContent, err := renameContent(added)
+	if err != nil {
+		return 0, err
+	}
+	m := difflib.NewMatcherWithJunk(splitLines(deletedContent), splitLines(addedContent), false, nil)
+	return m.Ratio(), nil
+}
+
+// renameContent returns the contents of the resource which are compared to
+// detect renames, i.e. all but its type, name, namespace, kpt-merge comment
+// and the annotations used by the merge.
+func renameContent(n *yaml.RNode) ([]byte, error) {
+	c := n.Copy()
+	for _, field := range []string{yaml.APIVersionField, yaml.KindField} {
+		if _, err := c.Pipe(yaml.Clear(field)); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range []string{yaml.NameField, yaml.NamespaceField} {
+		if _, err := c.Pipe(yaml.Lookup(yaml.MetadataField), yaml.Clear(field)); err != nil {
+			return nil, err
+		}
+	}
+	if err := stripKyamlAnnos(c); err != nil {
+		return nil, err
+	}
+	if err := c.PipeE(yaml.ClearAnnotation(kioutil.SeqIndentAnnotation)); err != nil {
+		return nil, err
+	}
+	if err := c.PipeE(yaml.Lookup(yaml.MetadataField), yaml.FieldClearer{Name: yaml.AnnotationsField, IfEmpty: true}); err != nil {
+		return nil, err
+	}
+	if err := c.PipeE(yaml.FieldClearer{Name: yaml.MetadataField, IfEmpty: true}); err != nil {
+		return nil, err
+	}
+	if mf := c.Field(yaml.MetadataField); mf != nil {
+		mf.Key.YNode().LineComment = ""
+	}
+	s, err := c.String()
+	return []byte(s), err
+}
+
+// identity returns the namespace and name used to match the resource, in
+// the format of the kpt-merge comment.
+func identity(n *yaml.RNode, meta yaml.ResourceMeta) string {
+	comment := metadataComment(n)
+	return fmt.Sprintf("%s/%s", resolveNamespace(meta, comment), resolveName(meta, comment))
+}
+
+// setIdentity sets the kpt-merge comment of the local resource to the
+// identity of the upstream resource it was renamed to, so it keeps matching
+// upstream in later updates.
+func setIdentity(local, upstream *yaml.RNode) error {
+	meta, err := upstream.GetMeta()
+	if err != nil {
+		return err
+	}
+	mf := local.Field(yaml.MetadataField)
+	if mf == nil {
+		return nil
+	}
+	mf.Key.YNode().LineComment = fmt.Sprintf("%s %s", MergeCommentPrefix, identity(upstream, meta))
+	return nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge3"
+	"sigs.k8s.io/kustomize/kyaml/yaml/walk"
+)
+
+const (
+	crdKind = "CustomResourceDefinition"
+
+	listTypeExtension      = "x-kubernetes-list-type"
+	listMapKeysExtension   = "x-kubernetes-list-map-keys"
+	patchStrategyExtension = "x-kubernetes-patch-strategy"
+	patchMergeKeyExtension = "x-kubernetes-patch-merge-key"
+	gvkExtension           = "x-kubernetes-group-version-kind"
+
+	// maxRefDepth limits how deep references are inlined in the schemas
+	// from OpenAPI documents, so recursive definitions terminate.
+	maxRefDepth = 10
+)
+
+// Schemas are the OpenAPI schemas of resources, indexed by their apiVersion
+// and kind. They are used to merge the lists of custom resources, which
+// don't have built in schemas: lists with the x-kubernetes-list-type map are
+// merged by their x-kubernetes-list-map-keys, and lists with the type set are
+// merged by their values, rather than replaced as a whole.
+type Schemas map[yaml.TypeMeta]*openapi.ResourceSchema
+
+// AddCRD adds the schemas of the versions of a CustomResourceDefinition. It
+// is a no-op if crd isn't a CustomResourceDefinition.
+func (s Schemas) AddCRD(crd *yaml.RNode) error {
+	meta, err := crd.GetMeta()
+	if err != nil {
+		return err
+	}
+	if meta.Kind != crdKind || resolveGroup(meta) != "apiextensions.k8s.io" {
+		return nil
+	}
+	group, err := stringField(crd, "spec", "group")
+	if err != nil {
+		return err
+	}
+	kind, err := stringField(crd, "spec", "names", "kind")
+	if err != nil {
+		return err
+	}
+	if group == "" || kind == "" {
+		return nil
+	}
+
+	// v1beta1 CRDs may have a single schema for all versions
+	common, err := crd.Pipe(yaml.Lookup("spec", "validation", "openAPIV3Schema"))
+	if err != nil {
+		return err
+	}
+	versions := map[string]*yaml.RNode{}
+	if version, err := stringField(crd, "spec", "version"); err != nil {
+		return err
+	} else if version != "" {
+		versions[version] = common
+	}
+	versionList, err := crd.Pipe(yaml.Lookup("spec", "versions"))
+	if err != nil {
+		return err
+	}
+	for _, v := range elements(versionList) {
+		name, err := stringField(v, "name")
+		if err != nil {
+			return err
+		}
+		schema, err := v.Pipe(yaml.Lookup("schema", "openAPIV3Schema"))
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			schema = common
+		}
+		versions[name] = schema
+	}
+
+	for version, schema := range versions {
+		if schema == nil {
+			continue
+		}
+		rs, err := resourceSchema(schema, nil)
+		if err != nil {
+			return fmt.Errorf("invalid schema for version %q of CRD %q: %w", version, meta.Name, err)
+		}
+		s[yaml.TypeMeta{APIVersion: group + "/" + version, Kind: kind}] = rs
+	}
+	return nil
+}
+
+// AddOpenAPI adds the schemas of the definitions of an OpenAPI document
+// which have the x-kubernetes-group-version-kind extension. References to
+// other definitions are resolved within the document.
+func (s Schemas) AddOpenAPI(doc *yaml.RNode) error {
+	definitions, err := doc.Pipe(yaml.Lookup("definitions"))
+	if err != nil || definitions == nil {
+		return err
+	}
+	return definitions.VisitFields(func(node *yaml.MapNode) error {
+		for _, gvk := range elements(fieldValue(node.Value, gvkExtension)) {
+			group, err := stringField(gvk, "group")
+			if err != nil {
+				return err
+			}
+			version, err := stringField(gvk, "version")
+			if err != nil {
+				return err
+			}
+			kind, err := stringField(gvk, "kind")
+			if err != nil {
+				return err
+			}
+			apiVersion := version
+			if group != "" {
+				apiVersion = group + "/" + version
+			}
+			rs, err := resourceSchema(node.Value, definitions)
+			if err != nil {
+				return fmt.Errorf("invalid definition %q: %w", node.Key.YNode().Value, err)
+			}
+			s[yaml.TypeMeta{APIVersion: apiVersion, Kind: kind}] = rs
+		}
+		return nil
+	})
+}
+
+// AddOpenAPIFile adds the schemas of the definitions of the OpenAPI
+// document in the JSON or YAML file at path.
+func (s Schemas) AddOpenAPIFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	doc, err := yaml.Parse(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid OpenAPI file %q: %w", path, err)
+	}
+	return s.AddOpenAPI(doc)
+}
+
+// addCRDs adds the schemas of the CustomResourceDefinitions in nodes. The
+// CRDs from upstream take precedence over the ones from local, which take
+// precedence over the ones from origin.
+func (s Schemas) addCRDs(nodes []*yaml.RNode) error {
+	for _, source := range []string{mergeSourceOriginal, mergeSourceDest, mergeSourceUpdated} {
+		for _, n := range nodes {
+			meta, err := n.GetMeta()
+			if err != nil {
+				return err
+			}
+			if meta.Kind != crdKind || meta.Annotations[mergeSourceAnnotation] != source {
+				continue
+			}
+			if err := s.AddCRD(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// forResource returns the schema for the type of the resource, or nil if
+// there is none.
+func (s Schemas) forResource(n *yaml.RNode) *openapi.ResourceSchema {
+	if len(s) == 0 || n == nil {
+		return nil
+	}
+	meta, err := n.GetMeta()
+	if err != nil {
+		return nil
+	}
+	return s[meta.TypeMeta]
+}
+
+// builtinSchema returns the built in schema for the type of the resource, or
+// nil if it isn't a built in type.
+func builtinSchema(n *yaml.RNode) *openapi.ResourceSchema {
+	meta, err := n.GetMeta()
+	if err != nil {
+		return nil
+	}
+	return openapi.SchemaForResourceType(meta.TypeMeta)
+}
+
+// mergeWithSchema performs a 3-way merge of the resource like the default
+// merge, but uses schema rather than the built in schemas if it is set, and
+// updates local with the result.
+func mergeWithSchema(origin, upstream, local *yaml.RNode, schema *openapi.ResourceSchema) error {
+	merged, err := walk.Walker{
+		Visitor:            merge3.Visitor{},
+		VisitKeysAsScalars: true,
+		Schema:             schema,
+		Sources:            []*yaml.RNode{local, origin, upstream},
+	}.Walk()
+	if err != nil {
+		return err
+	}
+	if merged != nil && merged.YNode() != local.YNode() {
+		local.SetYNode(merged.YNode())
+	}
+	return nil
+}
+
+// resourceSchema returns a ResourceSchema for the schema node. References
+// are inlined from definitions, and the lists with the type map or set get
+// the patch strategy merge, since that is what the merge looks up.
+func resourceSchema(schema, definitions *yaml.RNode) (*openapi.ResourceSchema, error) {
+	n := yaml.NewRNode(yaml.CopyYNode(schema.YNode()))
+	if err := inlineRefs(n, definitions, 0); err != nil {
+		return nil, err
+	}
+	if err := setPatchStrategies(n); err != nil {
+		return nil, err
+	}
+	b, err := n.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return openapi.GetSchema(string(b), nil)
+}
+
+// inlineRefs replaces the schemas in n which are references to
+// definitions with copies of the definitions.
+func inlineRefs(n, definitions *yaml.RNode, depth int) error {
+	switch n.YNode().Kind {
+	case yaml.MappingNode:
+		if ref := n.Field("$ref"); ref != nil {
+			name := strings.TrimPrefix(ref.Value.YNode().Value, "#/definitions/")
+			def := fieldValue(definitions, name)
+			if def == nil {
+				return fmt.Errorf("unresolved reference %q", ref.Value.YNode().Value)
+			}
+			if depth >= maxRefDepth {
+				// leave the schema unknown rather than recursing forever
+				n.SetYNode(yaml.NewMapRNode(nil).YNode())
+				return nil
+			}
+			n.SetYNode(yaml.CopyYNode(def.YNode()))
+			return inlineRefs(n, definitions, depth+1)
+		}
+		return n.VisitFields(func(node *yaml.MapNode) error {
+			return inlineRefs(node.Value, definitions, depth)
+		})
+	case yaml.SequenceNode:
+		for _, e := range elements(n) {
+			if err := inlineRefs(e, definitions, depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setPatchStrategies sets the patch strategy and merge key extensions of
+// the lists in the schema n from their list type and map keys.
+func setPatchStrategies(n *yaml.RNode) error {
+	switch n.YNode().Kind {
+	case yaml.MappingNode:
+		listType, err := stringField(n, listTypeExtension)
+		if err != nil {
+			return err
+		}
+		if listType == "map" || listType == "set" {
+			if err := n.PipeE(yaml.SetField(patchStrategyExtension, yaml.NewScalarRNode("merge"))); err != nil {
+				return err
+			}
+		}
+		// the merge reads all the keys from the list map keys, the patch
+		// merge key can only be set for lists with a single key
+		if keys := n.Field(listMapKeysExtension); listType == "map" && keys != nil {
+			if content := keys.Value.Content(); len(content) == 1 {
+				key := yaml.NewScalarRNode(content[0].Value)
+				if err := n.PipeE(yaml.SetField(patchMergeKeyExtension, key)); err != nil {
+					return err
+				}
+			}
+		}
+		return n.VisitFields(func(node *yaml.MapNode) error {
+			return setPatchStrategies(node.Value)
+		})
+	case yaml.SequenceNode:
+		for _, e := range elements(n) {
+			if err := setPatchStrategies(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fieldSchema returns the schema of a field of a mapping, or nil if it is
+// unknown.
+func fieldSchema(s *openapi.ResourceSchema, field string) *openapi.ResourceSchema {
+	if s == nil || s.Schema == nil {
+		return nil
+	}
+	return s.Field(field)
+}
+
+// elementSchema returns the schema of the elements of a list, or nil if it
+// is unknown.
+func elementSchema(s *openapi.ResourceSchema) *openapi.ResourceSchema {
+	if s == nil || s.Schema == nil {
+		return nil
+	}
+	return s.Elements()
+}
+
+// schemaListKeys returns the keys identifying the elements of a list with
+// schema s, and "merge" if the list is merged by them or by its values.
+// strategy is empty if the list is replaced as a whole or s is unknown.
+func schemaListKeys(s *openapi.ResourceSchema) (strategy string, keys []string) {
+	if s == nil || s.Schema == nil {
+		return "", nil
+	}
+	strategy, keys = s.PatchStrategyAndKeyList()
+	for _, st := range strings.Split(strategy, ",") {
+		if st == "merge" {
+			return st, keys
+		}
+	}
+	return "", nil
+}
+
+// stringField returns the value of the scalar field at path, or an empty
+// string if it doesn't exist.
+func stringField(n *yaml.RNode, path ...string) (string, error) {
+	f, err := n.Pipe(yaml.Lookup(path...))
+	if err != nil || f == nil {
+		return "", err
+	}
+	return f.YNode().Value, nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const v1beta1CRD = `
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: routers.example.com
+spec:
+  group: example.com
+  names:
+    kind: Router
+  versions:
+  - name: v1alpha1
+  - name: v1
+  validation:
+    openAPIV3Schema:
+      type: object
+      properties:
+        spec:
+          type: object
+          properties:
+            ports:
+              type: array
+              x-kubernetes-list-type: map
+              x-kubernetes-list-map-keys: [id]
+              items:
+                type: object
+                properties:
+                  id:
+                    type: integer
+                  protocol:
+                    type: string
+`
+
+func TestSchemas_AddCRD(t *testing.T) {
+	s := Schemas{}
+	if !assert.NoError(t, s.AddCRD(yaml.MustParse(v1beta1CRD))) {
+		t.FailNow()
+	}
+	if !assert.Len(t, s, 2) {
+		t.FailNow()
+	}
+	for _, version := range []string{"v1alpha1", "v1"} {
+		rs := s[yaml.TypeMeta{APIVersion: "example.com/" + version, Kind: "Router"}]
+		if !assert.NotNil(t, rs, version) {
+			continue
+		}
+		strategy, keys := rs.Lookup("spec", "ports").PatchStrategyAndKeyList()
+		assert.Equal(t, "merge", strategy)
+		assert.Equal(t, []string{"id"}, keys)
+	}
+
+	// resources other than CRDs are ignored
+	assert.NoError(t, s.AddCRD(yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")))
+	assert.Len(t, s, 2)
+}
+
+func TestSchemas_AddOpenAPI_unresolvedRef(t *testing.T) {
+	err := Schemas{}.AddOpenAPI(yaml.MustParse(`
+definitions:
+  com.example.v1.Router:
+    x-kubernetes-group-version-kind:
+    - {group: example.com, version: v1, kind: Router}
+    properties:
+      spec:
+        $ref: '#/definitions/com.example.v1.Missing'
+`))
+	assert.EqualError(t, err,
+		`invalid definition "com.example.v1.Router": unresolved reference "#/definitions/com.example.v1.Missing"`)
+}
+
+func TestFieldConflicts_schema(t *testing.T) {
+	s := Schemas{}
+	if !assert.NoError(t, s.AddCRD(yaml.MustParse(v1beta1CRD))) {
+		t.FailNow()
+	}
+	router := func(protocol80, protocol443 string) *yaml.RNode {
+		return yaml.MustParse(`
+apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - id: 80
+    protocol: ` + protocol80 + `
+  - id: 443
+    protocol: ` + protocol443 + `
+`)
+	}
+	origin, upstream, local := router("http", "tcp"), router("http2", "udp"), router("http3", "tcp")
+
+	conflicts, _, err := fieldConflicts(origin, upstream, local, s.forResource(local), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, conflicts, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "spec.ports[id=80].protocol", conflicts[0].Field)
+
+	// without the schema, the lists are compared as a whole
+	conflicts, _, err = fieldConflicts(origin, upstream, local, nil, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, conflicts, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "spec.ports", conflicts[0].Field)
+}
+
+func TestFieldConflicts_schemaMultipleKeys(t *testing.T) {
+	s := Schemas{}
+	err := s.AddCRD(yaml.MustParse(`
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: routers.example.com
+spec:
+  group: example.com
+  names:
+    kind: Router
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              ports:
+                type: array
+                x-kubernetes-list-type: map
+                x-kubernetes-list-map-keys: [port, protocol]
+                items:
+                  type: object
+              hosts:
+                type: array
+                x-kubernetes-list-type: set
+                items:
+                  type: string
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	router := func(tcp, udp string, hosts string) *yaml.RNode {
+		return yaml.MustParse(`
+apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - port: 53
+    protocol: TCP
+    name: ` + tcp + `
+  - port: 53
+    protocol: UDP
+    name: ` + udp + `
+  hosts: ` + hosts + `
+`)
+	}
+	origin, upstream, local := router("dns", "dns", "[a]"), router("dns-tcp", "dns-udp", "[a, b]"), router("tcp", "dns", "[a, c]")
+	schema := s.forResource(local)
+
+	strategy, keys := schema.Lookup("spec", "ports").PatchStrategyAndKeyList()
+	assert.Equal(t, "merge", strategy)
+	assert.Equal(t, []string{"port", "protocol"}, keys)
+
+	// the elements are identified by both keys, and the values of sets are
+	// merged, like the merge does
+	conflicts, resolved, err := fieldConflicts(origin, upstream, local, schema, fakeResolver{
+		"spec.ports[port=53,protocol=TCP].name": {ResolutionTookLocal, ""},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, conflicts, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, "spec.ports[port=53,protocol=TCP].name", conflicts[0].Field)
+	assert.Equal(t, ResolutionTookLocal, conflicts[0].Resolution)
+
+	if !assert.NoError(t, mergeWithSchema(origin, upstream, local, schema)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, setResolvedFields(local, resolved)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: example.com/v1
+kind: Router
+metadata:
+  name: router
+spec:
+  ports:
+  - port: 53
+    protocol: TCP
+    name: tcp
+  - port: 53
+    protocol: UDP
+    name: dns-udp
+  hosts: [a, c, b]
+`, local.MustString())
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+const (
+	// LocalMarker, SeparatorMarker and UpstreamMarker delimit the local and
+	// upstream lines of a conflict in a text merge.
+	LocalMarker     = "<<<<<<< local"
+	SeparatorMarker = "======="
+	UpstreamMarker  = ">>>>>>> upstream"
+)
+
+// Text performs a line-based 3-way merge of the origin, local and upstream
+// contents of a file, using origin as the common ancestor. Lines changed on
+// only one side are taken from that side. Lines changed on both sides to
+// different contents are written between conflict markers, with the local
+// lines first. It returns the merged contents and the number of conflicts.
+func Text(origin, local, upstream []byte) ([]byte, int) {
+	o, l, u := splitLines(origin), splitLines(local), splitLines(upstream)
+	localMatches := matchLines(o, l)
+	upstreamMatches := matchLines(o, u)
+
+	var out bytes.Buffer
+	conflicts := 0
+	var oi, li, ui int
+	for {
+		// copy the lines which are unchanged on both sides
+		for oi < len(o) && li < len(l) && ui < len(u) &&
+			isMatch(localMatches, oi, li) && isMatch(upstreamMatches, oi, ui) {
+			out.WriteString(o[oi])
+			oi++
+			li++
+			ui++
+		}
+		if oi == len(o) && li == len(l) && ui == len(u) {
+			break
+		}
+
+		// find the next origin line which is unchanged on both sides, the
+		// lines before it were changed on at least one side
+		next := oi
+		for next < len(o) && !(hasMatch(localMatches, next) && hasMatch(upstreamMatches, next)) {
+			next++
+		}
+		nextLocal, nextUpstream := len(l), len(u)
+		if next < len(o) {
+			nextLocal, nextUpstream = localMatches[next], upstreamMatches[next]
+		}
+		originChunk, localChunk, upstreamChunk := o[oi:next], l[li:nextLocal], u[ui:nextUpstream]
+
+		switch {
+		case equalLines(originChunk, localChunk):
+			writeLines(&out, upstreamChunk)
+		case equalLines(originChunk, upstreamChunk), equalLines(localChunk, upstreamChunk):
+			writeLines(&out, localChunk)
+		default:
+			conflicts++
+			writeConflict(&out, localChunk, upstreamChunk)
+		}
+		oi, li, ui = next, nextLocal, nextUpstream
+	}
+	return out.Bytes(), conflicts
+}
+
+// splitLines splits b into lines, keeping the line endings.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns the index of the matching line in b of each matched
+// line in a.
+func matchLines(a, b []string) map[int]int {
+	matches := make(map[int]int)
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, block := range m.GetMatchingBlocks() {
+		for i := 0; i < block.Size; i++ {
+			matches[block.A+i] = block.B + i
+		}
+	}
+	return matches
+}
+
+func hasMatch(matches map[int]int, i int) bool {
+	_, found := matches[i]
+	return found
+}
+
+func isMatch(matches map[int]int, i, j int) bool {
+	m, found := matches[i]
+	return found && m == j
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+func writeConflict(out *bytes.Buffer, local, upstream []string) {
+	// the markers must be on their own lines, so the last line of each side
+	// gets a line ending if it is the last line of the file
+	writeSide := func(lines []string) {
+		writeLines(out, lines)
+		if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+			out.WriteString("\n")
+		}
+	}
+	out.WriteString(LocalMarker + "\n")
+	writeSide(local)
+	out.WriteString(SeparatorMarker + "\n")
+	writeSide(upstream)
+	out.WriteString(UpstreamMarker + "\n")
+}
@@ -0,0 +1,91 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestText(t *testing.T) {
+	testCases := map[string]struct {
+		origin            string
+		local             string
+		upstream          string
+		expected          string
+		expectedConflicts int
+	}{
+		"no changes": {
+			origin:   "a\nb\nc\n",
+			local:    "a\nb\nc\n",
+			upstream: "a\nb\nc\n",
+			expected: "a\nb\nc\n",
+		},
+		"changes on different lines": {
+			origin:   "a\nb\nc\nd\ne\n",
+			local:    "a\nB\nc\nd\ne\n",
+			upstream: "a\nb\nc\nD\ne\nf\n",
+			expected: "a\nB\nc\nD\ne\nf\n",
+		},
+		"lines added and deleted": {
+			origin:   "a\nb\nc\nd\n",
+			local:    "local\na\nb\nc\nd\n",
+			upstream: "a\nc\nd\n",
+			expected: "local\na\nc\nd\n",
+		},
+		"same change on both sides": {
+			origin:   "a\nb\nc\n",
+			local:    "a\nx\nc\n",
+			upstream: "a\nx\nc\n",
+			expected: "a\nx\nc\n",
+		},
+		"conflicting changes": {
+			origin:            "a\nb\nc\n",
+			local:             "a\nlocal\nc\n",
+			upstream:          "a\nupstream\nc\n",
+			expected:          "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\nc\n",
+			expectedConflicts: 1,
+		},
+		"conflict at the end without line ending": {
+			origin:            "a\nb",
+			local:             "a\nlocal",
+			upstream:          "a\nupstream",
+			expected:          "a\n<<<<<<< local\nlocal\n=======\nupstream\n>>>>>>> upstream\n",
+			expectedConflicts: 1,
+		},
+		"deleted locally and changed upstream": {
+			origin:            "a\nb\nc\n",
+			local:             "a\nc\n",
+			upstream:          "a\nB\nc\n",
+			expected:          "a\n<<<<<<< local\n=======\nB\n>>>>>>> upstream\nc\n",
+			expectedConflicts: 1,
+		},
+		"empty origin": {
+			origin:   "",
+			local:    "a\n",
+			upstream: "",
+			expected: "a\n",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			merged, conflicts := Text([]byte(tc.origin), []byte(tc.local), []byte(tc.upstream))
+			assert.Equal(t, tc.expected, string(merged))
+			assert.Equal(t, tc.expectedConflicts, conflicts)
+		})
+	}
+}
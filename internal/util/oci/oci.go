@@ -0,0 +1,209 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oci contains functions for publishing packages to and fetching
+// packages from OCI registries. A package is stored as an image with a
+// single layer, which contains the files of the package.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/util/archive"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// ImageSpec specifies a package in an OCI registry.
+type ImageSpec struct {
+	// Image is the image of the package, with a tag or digest.
+	// e.g. us-docker.pkg.dev/example/packages/mysql:v1
+	Image string
+
+	// Digest is the digest of the image manifest that was pulled to Dir.
+	Digest string
+
+	// Dir is the directory the package is extracted to.
+	Dir string
+}
+
+// AbsPath is the absolute path to the package.
+func (s ImageSpec) AbsPath() string {
+	return s.Dir
+}
+
+// Repository returns the image without the tag or digest.
+func Repository(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	return ref.Context().Name(), nil
+}
+
+// WithTag returns the image with its tag or digest replaced by tag.
+func WithTag(image, tag string) (string, error) {
+	repo, err := Repository(image)
+	if err != nil {
+		return "", err
+	}
+	return repo + ":" + tag, nil
+}
+
+// WithDigest returns the image with its tag or digest replaced by digest.
+func WithDigest(image, digest string) (string, error) {
+	repo, err := Repository(image)
+	if err != nil {
+		return "", err
+	}
+	return repo + "@" + digest, nil
+}
+
+// Pull pulls the package image of spec and extracts it to a new temporary
+// directory, which is stored in spec.Dir. The caller is responsible for
+// removing the directory.
+func Pull(ctx context.Context, spec *ImageSpec) error {
+	const op errors.Op = "oci.Pull"
+	ref, err := name.ParseReference(spec.Image)
+	if err != nil {
+		return errors.E(op, errors.InvalidParam, fmt.Errorf("invalid image %q: %w", spec.Image, err))
+	}
+	img, err := remote.Image(ref, remoteOptions(ctx)...)
+	if err != nil {
+		return errors.E(op, fmt.Errorf("error pulling image %q: %w", spec.Image, err))
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return errors.E(op, fmt.Errorf("error pulling image %q: %w", spec.Image, err))
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return errors.E(op, fmt.Errorf("error pulling image %q: %w", spec.Image, err))
+	}
+
+	dir, err := ioutil.TempDir("", "kpt-get-")
+	if err != nil {
+		return errors.E(op, errors.Internal, fmt.Errorf("error creating temp directory: %w", err))
+	}
+	for _, layer := range layers {
+		if err := extractLayer(layer, dir); err != nil {
+			os.RemoveAll(dir)
+			return errors.E(op, fmt.Errorf("error extracting image %q: %w", spec.Image, err))
+		}
+	}
+	spec.Digest = digest.String()
+	spec.Dir = dir
+	return nil
+}
+
+// Push publishes the package in dir as image. It returns the digest of the
+// pushed image manifest. Git metadata in dir is not published.
+func Push(ctx context.Context, dir, image string) (string, error) {
+	const op errors.Op = "oci.Push"
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", errors.E(op, errors.InvalidParam, fmt.Errorf("invalid image %q: %w", image, err))
+	}
+	b, err := archive.Tar(dir)
+	if err != nil {
+		return "", errors.E(op, errors.IO, err)
+	}
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	})
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	if err := remote.Write(ref, img, remoteOptions(ctx)...); err != nil {
+		return "", errors.E(op, fmt.Errorf("error pushing image %q: %w", image, err))
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	return digest.String(), nil
+}
+
+// Digest returns the digest of the manifest that image currently refers to.
+func Digest(ctx context.Context, image string) (string, error) {
+	const op errors.Op = "oci.Digest"
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", errors.E(op, errors.InvalidParam, fmt.Errorf("invalid image %q: %w", image, err))
+	}
+	desc, err := remote.Head(ref, remoteOptions(ctx)...)
+	if err != nil {
+		return "", errors.E(op, fmt.Errorf("error looking up image %q: %w", image, err))
+	}
+	return desc.Digest.String(), nil
+}
+
+// Tags returns the tags of the repository of image.
+func Tags(ctx context.Context, image string) ([]string, error) {
+	const op errors.Op = "oci.Tags"
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, errors.E(op, errors.InvalidParam, fmt.Errorf("invalid image %q: %w", image, err))
+	}
+	tags, err := remote.List(ref.Context(), remoteOptions(ctx)...)
+	if err != nil {
+		return nil, errors.E(op, fmt.Errorf("error listing tags of %q: %w", ref.Context().Name(), err))
+	}
+	return tags, nil
+}
+
+// Tag returns the tag of image, or an empty string if image refers to a
+// digest.
+func Tag(image string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if t, ok := ref.(name.Tag); ok {
+		return t.TagStr(), nil
+	}
+	return "", nil
+}
+
+func remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+	}
+}
+
+// extractLayer extracts the files of layer to dir.
+func extractLayer(layer v1.Layer, dir string) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return archive.Extract(rc, dir)
+}
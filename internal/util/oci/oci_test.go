@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushPull(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+	repo := strings.TrimPrefix(s.URL, "http://") + "/packages/mysql"
+
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"Kptfile":              "apiVersion: kpt.dev/v1\nkind: Kptfile\n",
+		"deployment.yaml":      "kind: Deployment\n",
+		"sub/Kptfile":          "apiVersion: kpt.dev/v1\nkind: Kptfile\n",
+		"sub/statefulset.yaml": "kind: StatefulSet\n",
+		".git/HEAD":            "ref: refs/heads/main\n",
+	}
+	for p, content := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(p), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(p, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	ctx := context.Background()
+	digest, err := Push(ctx, dir, repo+":v1")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// pushing the same content results in the same digest
+	digest2, err := Push(ctx, dir, repo+":v2")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, digest, digest2)
+
+	spec := &ImageSpec{Image: repo + ":v1"}
+	if !assert.NoError(t, Pull(ctx, spec)) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(spec.AbsPath())
+	assert.Equal(t, digest, spec.Digest)
+	for p, content := range files {
+		b, err := ioutil.ReadFile(filepath.Join(spec.AbsPath(), filepath.FromSlash(p)))
+		if strings.HasPrefix(p, ".git/") {
+			assert.True(t, os.IsNotExist(err), p)
+			continue
+		}
+		if assert.NoError(t, err) {
+			assert.Equal(t, content, string(b))
+		}
+	}
+}
+
+func TestPull_notFound(t *testing.T) {
+	s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+	defer s.Close()
+	repo := strings.TrimPrefix(s.URL, "http://") + "/packages/mysql"
+
+	err := Pull(context.Background(), &ImageSpec{Image: repo + ":v1"})
+	assert.Error(t, err)
+}
+
+func TestWithTagAndDigest(t *testing.T) {
+	image, err := WithTag("example.com/packages/mysql:v1", "v2")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/packages/mysql:v2", image)
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	image, err = WithDigest("example.com/packages/mysql:v1", digest)
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com/packages/mysql@"+digest, image)
+}
@@ -0,0 +1,258 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outdated contains functions for finding packages which have newer
+// versions upstream.
+package outdated
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/types"
+	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
+	"github.com/GoogleContainerTools/kpt/internal/util/local"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/Masterminds/semver/v3"
+)
+
+// Result is the upstream status of a package.
+type Result struct {
+	// Package is the path of the package relative to the parent directory of
+	// the root package.
+	Package string `json:"package"`
+
+	// Upstream is the git repository and directory, the image repository or
+	// the local path of the upstream package.
+	Upstream string `json:"upstream"`
+
+	// Ref is the version of the upstream package specified in the Kptfile,
+	// i.e. a git ref or semantic version constraint, or an image tag.
+	Ref string `json:"ref,omitempty"`
+
+	// Current is the commit, image digest or content hash of the version the
+	// package was last fetched at. It is empty if the package hasn't been
+	// fetched.
+	Current string `json:"current"`
+
+	// Latest is the commit, image digest or content hash Ref currently
+	// refers to upstream.
+	Latest string `json:"latest"`
+
+	// NewerVersions are the semantic version tags of the upstream package
+	// which are newer than the version the package was last fetched at.
+	NewerVersions []string `json:"newerVersions,omitempty"`
+
+	// Outdated is true if Ref refers to a different version than the one
+	// the package was last fetched at.
+	Outdated bool `json:"outdated"`
+}
+
+// Check returns the upstream status of the root package and its remote
+// subpackages, in the order of their paths. Packages without an upstream are
+// not included.
+func Check(ctx context.Context, root *pkg.Pkg) ([]Result, error) {
+	const op errors.Op = "outdated.Check"
+	paths, err := pkg.Subpackages(root.UniquePath.String(), pkg.Remote, true)
+	if err != nil {
+		return nil, errors.E(op, root.UniquePath, err)
+	}
+	sort.Strings(paths)
+	paths = append([]string{"."}, paths...)
+
+	c := &checker{repos: make(map[string]*gitutil.GitUpstreamRepo)}
+	var results []Result
+	for _, p := range paths {
+		pkgPath := filepath.Join(root.UniquePath.String(), p)
+		kf, err := pkg.ReadKptfile(pkgPath)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(pkgPath), err)
+		}
+		if kf.Upstream == nil {
+			continue
+		}
+		r, err := c.check(ctx, kf, pkgPath)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(pkgPath), err)
+		}
+		r.Package = filepath.ToSlash(filepath.Join(string(root.DisplayPath), p))
+		r.Outdated = r.Current != r.Latest
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// checker checks the upstream status of packages. The refs of git
+// repositories are only looked up once for packages with the same upstream
+// repository.
+type checker struct {
+	repos map[string]*gitutil.GitUpstreamRepo
+}
+
+func (c *checker) check(ctx context.Context, kf *kptfilev1.KptFile, pkgPath string) (Result, error) {
+	lock := kf.UpstreamLock
+	if lock == nil {
+		lock = &kptfilev1.UpstreamLock{}
+	}
+	switch {
+	case kf.Upstream.Oci != nil:
+		return checkOci(ctx, kf.Upstream.Oci, lock.Oci)
+	case kf.Upstream.Local != nil:
+		return checkLocal(kf.Upstream.Local, lock.Local, pkgPath)
+	case kf.Upstream.Git != nil:
+		return c.checkGit(ctx, kf.Upstream.Git, lock.Git)
+	default:
+		return Result{}, nil
+	}
+}
+
+func (c *checker) checkGit(ctx context.Context, g *kptfilev1.Git, lock *kptfilev1.GitLock) (Result, error) {
+	r := Result{
+		Upstream: g.Repo,
+		Ref:      g.Ref,
+	}
+	if dir := strings.Trim(g.Directory, "/"); dir != "" {
+		r.Upstream += "/" + dir
+	}
+	gur, found := c.repos[g.Repo]
+	if !found {
+		var err error
+		gur, err = gitutil.NewGitUpstreamRepo(ctx, g.Repo)
+		if err != nil {
+			return r, err
+		}
+		c.repos[g.Repo] = gur
+	}
+
+	ref, err := fetch.ResolvePackageRef(gur, g.Directory, g.Ref)
+	if err != nil {
+		return r, err
+	}
+	commit, found := gur.ResolveRef(ref)
+	switch {
+	case found:
+	case lock != nil && strings.HasPrefix(lock.Commit, ref):
+		// the ref is the, possibly abbreviated, commit that was fetched
+		commit = lock.Commit
+	default:
+		// the ref is a commit
+		commit = ref
+	}
+	r.Latest = commit
+
+	if lock != nil {
+		r.Current = lock.Commit
+		var tags []string
+		for t := range gur.Tags {
+			tags = append(tags, t)
+		}
+		r.NewerVersions = newerVersions(lock.Ref, tags)
+	}
+	return r, nil
+}
+
+func checkOci(ctx context.Context, o *kptfilev1.Oci, lock *kptfilev1.OciLock) (Result, error) {
+	r := Result{}
+	repo, err := oci.Repository(o.Image)
+	if err != nil {
+		return r, err
+	}
+	r.Upstream = repo
+	r.Ref, err = oci.Tag(o.Image)
+	if err != nil {
+		return r, err
+	}
+	r.Latest, err = oci.Digest(ctx, o.Image)
+	if err != nil {
+		return r, err
+	}
+
+	if lock != nil {
+		r.Current = lock.Digest
+		tag, err := oci.Tag(lock.Image)
+		if err != nil {
+			return r, err
+		}
+		tags, err := oci.Tags(ctx, o.Image)
+		if err != nil {
+			return r, err
+		}
+		r.NewerVersions = newerVersions(tag, tags)
+	}
+	return r, nil
+}
+
+func checkLocal(l *kptfilev1.Local, lock *kptfilev1.LocalLock, pkgPath string) (Result, error) {
+	r := Result{Upstream: l.Path}
+	spec := &local.PackageSpec{Path: l.Path}
+	if err := local.Load(spec, pkgPath); err != nil {
+		return r, err
+	}
+	defer os.RemoveAll(spec.AbsPath())
+	r.Latest = spec.Hash
+	if lock != nil {
+		r.Current = lock.Hash
+	}
+	return r, nil
+}
+
+// newerVersions returns the tags with semantic versions newer than the
+// version of tag, in ascending order. If tag has a prefix, e.g.
+// 'packages/mysql/v1.0.0', only tags with the same prefix are considered.
+// Pre-releases are only included if tag is a pre-release.
+func newerVersions(tag string, tags []string) []string {
+	i := strings.LastIndex(tag, "/")
+	prefix := tag[:i+1]
+	current, err := semver.NewVersion(tag[i+1:])
+	if err != nil {
+		return nil
+	}
+
+	type version struct {
+		tag string
+		v   *semver.Version
+	}
+	var newer []version
+	for _, t := range tags {
+		if !strings.HasPrefix(t, prefix) || strings.Contains(strings.TrimPrefix(t, prefix), "/") {
+			continue
+		}
+		v, err := semver.NewVersion(strings.TrimPrefix(t, prefix))
+		if err != nil || !v.GreaterThan(current) {
+			continue
+		}
+		if v.Prerelease() != "" && current.Prerelease() == "" {
+			continue
+		}
+		newer = append(newer, version{tag: t, v: v})
+	}
+	sort.Slice(newer, func(i, j int) bool {
+		if newer[i].v.Equal(newer[j].v) {
+			return newer[i].tag < newer[j].tag
+		}
+		return newer[i].v.LessThan(newer[j].v)
+	})
+	var result []string
+	for _, v := range newer {
+		result = append(result, v.tag)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outdated
+
+import (
+	"os"
+	"testing"
+
+	pkgtest "github.com/GoogleContainerTools/kpt/internal/pkg/testing"
+	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(testutil.ConfigureTestKptCache(m))
+}
+
+func TestCheck(t *testing.T) {
+	testCases := map[string]struct {
+		ref              string
+		expectedOutdated bool
+		expectedNewer    []string
+	}{
+		"tag with newer versions": {
+			ref:              "v1.0.0",
+			expectedOutdated: false,
+			expectedNewer:    []string{"v1.1.0", "v2.0.0"},
+		},
+		"branch with new commits": {
+			ref:              "master",
+			expectedOutdated: true,
+		},
+		"semver constraint with newer match": {
+			ref:              "~1.0.0",
+			expectedOutdated: false,
+			expectedNewer:    []string{"v1.1.0", "v2.0.0"},
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:      t,
+				GetRef: tc.ref,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Data:   testutil.Dataset1,
+							Branch: "master",
+							Tag:    "v1.0.0",
+						},
+						{
+							Data: testutil.Dataset2,
+							Tag:  "v1.1.0",
+						},
+						{
+							Data: testutil.Dataset3,
+							Tag:  "v2.0.0",
+						},
+					},
+				},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				return
+			}
+			upstreamRepo := g.Repos[testutil.Upstream]
+
+			results, err := Check(fake.CtxWithDefaultPrinter(),
+				pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if !assert.Len(t, results, 1) {
+				t.FailNow()
+			}
+			r := results[0]
+			assert.Equal(t, upstreamRepo.RepoDirectory, r.Upstream)
+			assert.Equal(t, tc.ref, r.Ref)
+			assert.Equal(t, tc.expectedOutdated, r.Outdated)
+			assert.Equal(t, tc.expectedNewer, r.NewerVersions)
+			assert.NotEmpty(t, r.Current)
+			if tc.expectedOutdated {
+				head, err := upstreamRepo.GetCommit()
+				assert.NoError(t, err)
+				assert.Equal(t, head, r.Latest)
+			} else {
+				assert.Equal(t, r.Current, r.Latest)
+			}
+		})
+	}
+}
+
+func TestNewerVersions(t *testing.T) {
+	tags := []string{
+		"v1.0.0", "v1.2.0", "v1.10.0", "v2.0.0-rc.1", "latest",
+		"packages/mysql/v1.0.0", "packages/mysql/v1.3.0",
+	}
+	assert.Equal(t, []string{"v1.2.0", "v1.10.0"}, newerVersions("v1.0.0", tags))
+	assert.Equal(t, []string{"v2.0.0-rc.1"}, newerVersions("v2.0.0-alpha", tags))
+	assert.Equal(t, []string{"packages/mysql/v1.3.0"}, newerVersions("packages/mysql/v1.0.0", tags))
+	assert.Empty(t, newerVersions("main", tags))
+}
@@ -39,7 +39,9 @@ func PkgHasUpdatedUpstream(local, origin string) (bool, error) {
 	// If the upstream information in local has changed from origin, it
 	// means the user had updated the package independently and we don't
 	// want to override it.
-	if !reflect.DeepEqual(localKf.Upstream.Git, originKf.Upstream.Git) {
+	if !reflect.DeepEqual(localKf.Upstream.Git, originKf.Upstream.Git) ||
+		!reflect.DeepEqual(localKf.Upstream.Oci, originKf.Upstream.Oci) ||
+		!reflect.DeepEqual(localKf.Upstream.Local, originKf.Upstream.Local) {
 		return true, nil
 	}
 	return false, nil
@@ -0,0 +1,401 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/errors"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
+	"github.com/GoogleContainerTools/kpt/internal/printer"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/sets"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Preview is the result of a dry run of an update. Resources are described
+// by their kind, namespace and name, and the slash-separated path of their
+// file relative to the parent directory of the package.
+type Preview struct {
+	// Diff is the unified diff of the files of the local package and the
+	// updated package.
+	Diff string
+
+	// Added are the resources which would be added to the local package.
+	Added []string
+
+	// Deleted are the resources which would be deleted from the local
+	// package.
+	Deleted []string
+
+	// Modified are the resources which would be changed in the local
+	// package.
+	Modified []string
+
+	// Kept are the resources which were deleted upstream, but would be kept
+	// in the local package since they have local changes.
+	Kept []string
+
+	// Conflicts are the resources and fields which were changed both
+	// upstream and locally, and how they would be merged.
+	Conflicts []merge.Conflict
+
+	// packageCount is the number of packages the update would update.
+	packageCount int
+}
+
+// Empty returns true if the update doesn't change the local package.
+func (p *Preview) Empty() bool {
+	return p.Diff == "" && len(p.Added) == 0 && len(p.Deleted) == 0 &&
+		len(p.Modified) == 0 && len(p.Kept) == 0 && len(p.Conflicts) == 0
+}
+
+// Print prints the diff followed by the lists of resources to w.
+func (p *Preview) Print(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+	fmt.Fprint(w, p.Diff)
+	for _, l := range []struct {
+		title     string
+		resources []string
+	}{
+		{"Resources to add", p.Added},
+		{"Resources to delete", p.Deleted},
+		{"Resources to modify", p.Modified},
+		{"Resources kept due to local changes", p.Kept},
+	} {
+		if len(l.resources) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", l.title)
+		for _, r := range l.resources {
+			fmt.Fprintf(w, "  %s\n", r)
+		}
+	}
+	if len(p.Conflicts) > 0 {
+		fmt.Fprintf(w, "\nConflicts:\n")
+		for _, c := range p.Conflicts {
+			fmt.Fprintf(w, "  %s\n", c)
+		}
+	}
+}
+
+// Preview performs the update in a staging copy of the package, and returns
+// the changes it would make to the local package. The local package isn't
+// changed, so it doesn't need to be committed to git.
+func (u Command) Preview(ctx context.Context) (*Preview, error) {
+	const op errors.Op = "update.Preview"
+	if u.Pkg == nil {
+		return nil, errors.E(op, errors.MissingParam, "pkg must be provided")
+	}
+	dir, err := ioutil.TempDir("", "kpt-update-")
+	if err != nil {
+		return nil, errors.E(op, errors.IO, fmt.Errorf("error creating a temporary directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+
+	stagedPath, p, err := u.stage(ctx, dir)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+
+	name := string(u.Pkg.DisplayPath)
+	p.Diff, err = diffDirs(u.Pkg.UniquePath.String(), stagedPath, name)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	localResources, err := readResources(u.Pkg.UniquePath.String(), name)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	stagedResources, err := readResources(stagedPath, name)
+	if err != nil {
+		return nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	for key, r := range stagedResources {
+		l, found := localResources[key]
+		switch {
+		case !found:
+			p.Added = append(p.Added, r.description)
+		case l.content != r.content:
+			p.Modified = append(p.Modified, r.description)
+		}
+	}
+	for key, l := range localResources {
+		if _, found := stagedResources[key]; !found {
+			p.Deleted = append(p.Deleted, l.description)
+		}
+	}
+	sort.Strings(p.Added)
+	sort.Strings(p.Deleted)
+	sort.Strings(p.Modified)
+	sort.Strings(p.Kept)
+	return p, nil
+}
+
+// stage performs the update in a staging copy of the package in dir, and
+// returns the path of the copy and the preview with the conflicts of the
+// update.
+func (u Command) stage(ctx context.Context, dir string) (string, *Preview, error) {
+	const op errors.Op = "update.stage"
+	// the staging copy has the same name as the package, so packages are
+	// displayed with the same paths
+	stagedPath := filepath.Join(dir, filepath.Base(u.Pkg.UniquePath.String()))
+	if err := copyutil.CopyDir(u.Pkg.UniquePath.String(), stagedPath); err != nil {
+		return "", nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	stagedPkg, err := pkg.New(stagedPath)
+	if err != nil {
+		return "", nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+
+	p := &Preview{}
+	staged := u
+	staged.DryRun = false
+	staged.Pkg = stagedPkg
+	staged.localPkg = u.Pkg
+	staged.preview = p
+	if err := staged.Run(ctx); err != nil {
+		return "", nil, errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return stagedPath, p, nil
+}
+
+// localDir returns the directory of the local package that p is the
+// package, or the staging copy of the package, for. Relative local upstream
+// paths are resolved from this directory.
+func (u Command) localDir(p *pkg.Pkg) (string, error) {
+	if u.localPkg == nil {
+		return p.UniquePath.String(), nil
+	}
+	rel, err := filepath.Rel(u.Pkg.UniquePath.String(), p.UniquePath.String())
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(u.localPkg.UniquePath.String(), rel), nil
+}
+
+// recordKept adds the resources of the staged package p which were deleted
+// between origin and updated to the preview. Those resources were kept by
+// the update since they have local changes.
+func (u Command) recordKept(p *pkg.Pkg, originPath, updatedPath string) error {
+	const op errors.Op = "update.recordKept"
+	rel, err := filepath.Rel(filepath.Dir(u.Pkg.UniquePath.String()), p.UniquePath.String())
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	prefix := filepath.ToSlash(rel)
+	origin, err := readResources(originPath, prefix)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	updated, err := readResources(updatedPath, prefix)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	local, err := readResources(p.UniquePath.String(), prefix)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
+	}
+	for key := range origin {
+		if _, found := updated[key]; found {
+			continue
+		}
+		if l, found := local[key]; found {
+			u.preview.Kept = append(u.preview.Kept, l.description)
+		}
+	}
+	return nil
+}
+
+// resource is a resource read from a package.
+type resource struct {
+	description string
+	content     string
+}
+
+// readResources reads the KRM resources in dir and its subdirectories. The
+// resources are keyed by the directory of their file, their group, kind,
+// namespace and name, since the update strategies match resources within a
+// package regardless of the file they are in. The paths are prefixed with
+// prefix.
+func readResources(dir, prefix string) (map[string]resource, error) {
+	resources := make(map[string]resource)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return resources, nil
+	}
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath:        dir,
+		PackageFileName:    kptfilev1.KptFileName,
+		IncludeSubpackages: true,
+	}).Read()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		p, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, err
+		}
+		p = path.Join(prefix, filepath.ToSlash(p))
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		id := meta.Kind + "/" + meta.Name
+		if meta.Namespace != "" {
+			id = meta.Kind + "/" + meta.Namespace + "/" + meta.Name
+		}
+		group := strings.Split(meta.APIVersion, "/")[0]
+		if !strings.Contains(meta.APIVersion, "/") {
+			group = ""
+		}
+		key := path.Dir(p) + "|" + group + "|" + id
+		for _, a := range []string{kioutil.IndexAnnotation, kioutil.PathAnnotation} {
+			if err := n.PipeE(yaml.ClearAnnotation(a)); err != nil {
+				return nil, err
+			}
+		}
+		// resources are compared without comments and formatting, which
+		// are shown in the diff
+		content, err := n.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		resources[key] = resource{
+			description: fmt.Sprintf("%s (%s)", id, p),
+			content:     string(content),
+		}
+	}
+	return resources, nil
+}
+
+// diffDirs returns the unified diff of the files in the directories a and
+// b, with the file names prefixed with name.
+func diffDirs(a, b, name string) (string, error) {
+	aFiles, err := listFiles(a)
+	if err != nil {
+		return "", err
+	}
+	bFiles, err := listFiles(b)
+	if err != nil {
+		return "", err
+	}
+	files := sets.String{}
+	files.Insert(aFiles.List()...)
+	files.Insert(bFiles.List()...)
+	var out bytes.Buffer
+	for _, f := range files.List() {
+		aContent, err := readFileIfExists(filepath.Join(a, f))
+		if err != nil {
+			return "", err
+		}
+		bContent, err := readFileIfExists(filepath.Join(b, f))
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(aContent, bContent) {
+			continue
+		}
+		fromFile, toFile := path.Join("a", name, f), path.Join("b", name, f)
+		if !aFiles.Has(f) {
+			fromFile = "/dev/null"
+		}
+		if !bFiles.Has(f) {
+			toFile = "/dev/null"
+		}
+		if bytes.IndexByte(aContent, 0) >= 0 || bytes.IndexByte(bContent, 0) >= 0 {
+			fmt.Fprintf(&out, "Binary files %s and %s differ\n", fromFile, toFile)
+			continue
+		}
+		err = difflib.WriteUnifiedDiff(&out, difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(aContent)),
+			B:        difflib.SplitLines(string(bContent)),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	return out.String(), nil
+}
+
+// listFiles returns the slash-separated paths of the files in dir,
+// excluding the .git directory.
+func listFiles(dir string) (sets.String, error) {
+	files := sets.String{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files.Insert(filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+func readFileIfExists(p string) ([]byte, error) {
+	b, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// printPreview performs a dry run of the update and prints the changes to
+// the output stream.
+func (u Command) printPreview(ctx context.Context) error {
+	const op errors.Op = "update.printPreview"
+	p, err := u.Preview(ctx)
+	if err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	pr := printer.FromContextOrDie(ctx)
+	pr.Printf("\nDry run, the local package was not changed.\n")
+	p.Print(pr.OutStream())
+	u.conflicts = &merge.ConflictReport{Conflicts: p.Conflicts}
+	if err := u.writeConflictReport(); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return nil
+}
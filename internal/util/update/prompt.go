@@ -0,0 +1,111 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PromptResolver is a merge.ConflictResolver which prompts the user to
+// choose the origin, upstream or local value of each conflicting field, or
+// to enter a new value for scalar fields.
+type PromptResolver struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewPromptResolver returns a PromptResolver which reads the choices from in
+// and writes the prompts to out.
+func NewPromptResolver(in io.Reader, out io.Writer) *PromptResolver {
+	return &PromptResolver{
+		in:  bufio.NewReader(in),
+		out: out,
+	}
+}
+
+// Resolve implements merge.ConflictResolver.
+func (r *PromptResolver) Resolve(c merge.Conflict) (string, string, error) {
+	fmt.Fprintf(r.out, "\nConflict in %s (%s), field %s:\n", c.Resource, c.File, c.Field)
+	fmt.Fprintf(r.out, "  [o] origin:   %s\n", promptValue(c.Origin))
+	fmt.Fprintf(r.out, "  [u] upstream: %s\n", promptValue(c.Upstream))
+	fmt.Fprintf(r.out, "  [l] local:    %s\n", promptValue(c.Local))
+	choices := "o,u,l"
+	editable := isScalar(c.Origin) && isScalar(c.Upstream) && isScalar(c.Local)
+	if editable {
+		fmt.Fprintf(r.out, "  [e] edit\n")
+		choices += ",e"
+	}
+	for {
+		fmt.Fprintf(r.out, "Choose a value [u]: ")
+		choice, err := r.readLine()
+		if err != nil {
+			return "", "", err
+		}
+		switch strings.ToLower(choice) {
+		case "", "u":
+			return merge.ResolutionTookUpstream, "", nil
+		case "l":
+			return merge.ResolutionTookLocal, "", nil
+		case "o":
+			return merge.ResolutionTookOrigin, "", nil
+		case "e":
+			if !editable {
+				fmt.Fprintf(r.out, "Only scalar values can be edited, must be one of: %s\n", choices)
+				continue
+			}
+			fmt.Fprintf(r.out, "Enter the new value as YAML, or nothing to remove the field: ")
+			value, err := r.readLine()
+			if err != nil {
+				return "", "", err
+			}
+			return merge.ResolutionEdited, value, nil
+		default:
+			fmt.Fprintf(r.out, "Unknown choice %q, must be one of: %s\n", choice, choices)
+		}
+	}
+}
+
+// readLine reads a line from the input. An error is returned if the input
+// ends before a line is read, so the update doesn't silently use defaults.
+func (r *PromptResolver) readLine() (string, error) {
+	line, err := r.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("error reading the choice: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// isScalar returns true if the value of a field is a scalar or missing, so
+// a new value can be entered on a single line.
+func isScalar(v string) bool {
+	if v == "" {
+		return true
+	}
+	n, err := yaml.Parse(v)
+	return err == nil && n.YNode().Kind == yaml.ScalarNode
+}
+
+func promptValue(v string) string {
+	if v == "" {
+		return "<none>"
+	}
+	return v
+}
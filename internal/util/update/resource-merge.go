@@ -16,9 +16,11 @@ package update
 
 import (
 	"bytes"
+	goerrors "errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -63,6 +65,16 @@ func (u ResourceMergeUpdater) Update(options UpdateOptions) error {
 		return errors.E(op, types.UniquePath(options.LocalPath), err)
 	}
 
+	// Check the noMerge patterns of all the packages before any of them is
+	// updated, so an invalid pattern doesn't leave the update half done.
+	for _, subPkgPath := range append([]string{"."}, subPkgPaths...) {
+		for _, p := range []string{options.LocalPath, options.UpdatedPath} {
+			if err := validateUpstream(filepath.Join(p, subPkgPath)); err != nil {
+				return errors.E(op, types.UniquePath(options.LocalPath), err)
+			}
+		}
+	}
+
 	// Update each package and subpackage. Parent package is updated before
 	// subpackages to make sure auto-setters can work correctly.
 	for _, subPkgPath := range append([]string{"."}, subPkgPaths...) {
@@ -74,7 +86,7 @@ func (u ResourceMergeUpdater) Update(options UpdateOptions) error {
 		updatedSubPkgPath := filepath.Join(options.UpdatedPath, subPkgPath)
 		originalSubPkgPath := filepath.Join(options.OriginPath, subPkgPath)
 
-		err := u.updatePackage(subPkgPath, localSubPkgPath, updatedSubPkgPath, originalSubPkgPath, isRootPkg)
+		err := u.updatePackage(subPkgPath, localSubPkgPath, updatedSubPkgPath, originalSubPkgPath, isRootPkg, options.Conflicts, options.Resolver)
 		if err != nil {
 			return errors.E(op, types.UniquePath(localSubPkgPath), err)
 		}
@@ -84,8 +96,10 @@ func (u ResourceMergeUpdater) Update(options UpdateOptions) error {
 
 // updatePackage updates the package in the location specified by localPath
 // using the provided paths to the updated version of the package and the
-// original version of the package.
-func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath, originalPath string, isRootPkg bool) error {
+// original version of the package. The conflicts of the merge are added to
+// conflicts and resolved with resolver, if they are provided.
+func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath, originalPath string, isRootPkg bool,
+	conflicts *merge.ConflictReport, resolver merge.ConflictResolver) error {
 	const op errors.Op = "update.updatePackage"
 	localExists, err := pkgutil.Exists(localPath)
 	if err != nil {
@@ -135,7 +149,7 @@ func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath,
 			}
 		}
 	default:
-		if err := u.mergePackage(localPath, updatedPath, originalPath, subPkgPath, isRootPkg); err != nil {
+		if err := u.mergePackage(localPath, updatedPath, originalPath, subPkgPath, isRootPkg, conflicts, resolver); err != nil {
 			return errors.E(op, types.UniquePath(localPath), err)
 		}
 	}
@@ -143,52 +157,108 @@ func (u ResourceMergeUpdater) updatePackage(subPkgPath, localPath, updatedPath,
 }
 
 // mergePackage merge a package. It does a 3-way merge by using the provided
-// paths to the local, updated and original versions of the package.
-func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath, _ string, isRootPkg bool) error {
+// paths to the local, updated and original versions of the package. The
+// noMerge patterns and the OpenAPI file are taken from the local Kptfile
+// after the upstream changes have been merged into it.
+func (u ResourceMergeUpdater) mergePackage(localPath, updatedPath, originalPath, subPkgPath string, isRootPkg bool,
+	conflicts *merge.ConflictReport, resolver merge.ConflictResolver) error {
 	const op errors.Op = "update.mergePackage"
 	if err := kptfileutil.UpdateKptfile(localPath, updatedPath, originalPath, !isRootPkg); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
 
+	// the files which shouldn't be merged and the OpenAPI file are read
+	// from the local Kptfile after it has been updated, so changes to them
+	// in upstream are used by this update
+	var noMerge []string
+	schemas := merge.Schemas{}
+	localKf, err := pkg.ReadKptfile(localPath)
+	if err != nil && !goerrors.Is(err, os.ErrNotExist) {
+		return errors.E(op, types.UniquePath(localPath), err)
+	}
+	if err == nil && localKf.Upstream != nil {
+		noMerge = localKf.Upstream.NoMerge
+		if localKf.Upstream.OpenAPI != "" {
+			if err := addOpenAPIFile(schemas, localKf.Upstream.OpenAPI, updatedPath, localPath); err != nil {
+				return errors.E(op, types.UniquePath(localPath), err)
+			}
+		}
+	}
+
 	// merge the Resources: original + updated + dest => dest
-	err := merge.Merge3{
+	pkgConflicts := &merge.ConflictReport{}
+	err = merge.Merge3{
 		OriginalPath: originalPath,
 		UpdatedPath:  updatedPath,
 		DestPath:     localPath,
 		// TODO: Write a test to ensure this is set
 		MergeOnPath:        true,
 		IncludeSubPackages: false,
+		Conflicts:          pkgConflicts,
+		Resolver:           resolver,
+		Schemas:            schemas,
 	}.Merge()
 	if err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
+	if conflicts != nil {
+		conflicts.Add(filepath.ToSlash(subPkgPath), pkgConflicts.Conflicts...)
+	}
 
-	if err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath); err != nil {
+	fileConflicts, err := ReplaceNonKRMFiles(updatedPath, originalPath, localPath, noMerge)
+	if err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
+	if conflicts != nil {
+		conflicts.Add(filepath.ToSlash(subPkgPath), fileConflicts...)
+	}
 	return nil
 }
 
-// replaceNonKRMFiles replaces the non KRM files in localDir with the corresponding files in updatedDir,
-// it also deletes non KRM files and sub dirs which are present in localDir and not in updatedDir
-func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
+// addOpenAPIFile adds the definitions of the OpenAPI file at the path
+// relative to the package to schemas. The file from the updated package is
+// used if it exists, since it describes the updated resources.
+func addOpenAPIFile(schemas merge.Schemas, path, updatedPath, localPath string) error {
+	const op errors.Op = "update.addOpenAPIFile"
+	if filepath.IsAbs(path) || strings.HasPrefix(filepath.Clean(path), "..") {
+		return errors.E(op, errors.InvalidParam,
+			fmt.Errorf("openAPI path %q must be relative to the package and in it", path))
+	}
+	file := filepath.Join(updatedPath, path)
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		file = filepath.Join(localPath, path)
+	}
+	if err := schemas.AddOpenAPIFile(file); err != nil {
+		return errors.E(op, types.UniquePath(file), err)
+	}
+	return nil
+}
+
+// ReplaceNonKRMFiles updates the non KRM files in localDir with the changes from originalDir to
+// updatedDir. Files which weren't modified locally are replaced with the corresponding files in
+// updatedDir, and files which were modified both locally and in updatedDir are merged line by
+// line, unless they match one of the noMerge glob patterns or are binary. It also deletes non KRM
+// files and sub dirs which are present in localDir and not in updatedDir. The files that were
+// merged with conflict markers are returned as conflicts.
+func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string, noMerge []string) ([]merge.Conflict, error) {
 	const op errors.Op = "update.ReplaceNonKRMFiles"
 	updatedSubDirs, updatedFiles, err := getSubDirsAndNonKrmFiles(updatedDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
 	}
 
 	originalSubDirs, originalFiles, err := getSubDirsAndNonKrmFiles(originalDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
 	}
 
 	localSubDirs, localFiles, err := getSubDirsAndNonKrmFiles(localDir)
 	if err != nil {
-		return errors.E(op, types.UniquePath(localDir), err)
+		return nil, errors.E(op, types.UniquePath(localDir), err)
 	}
 
 	// identify all non KRM files modified locally, to leave them untouched
+	// or merge them
 	locallyModifiedFiles := sets.String{}
 	for _, file := range localFiles.List() {
 		if !originalFiles.Has(file) {
@@ -198,7 +268,7 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		}
 		same, err := compareFiles(filepath.Join(originalDir, file), filepath.Join(localDir, file))
 		if err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
+			return nil, errors.E(op, types.UniquePath(localDir), err)
 		}
 		if !same {
 			// local file has been modified
@@ -209,7 +279,7 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		// remove the file from local if it is not modified and is deleted from updated upstream
 		if !updatedFiles.Has(file) {
 			if err = os.Remove(filepath.Join(localDir, file)); err != nil {
-				return errors.E(op, types.UniquePath(localDir), err)
+				return nil, errors.E(op, types.UniquePath(localDir), err)
 			}
 		}
 	}
@@ -217,19 +287,44 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 	// make sure local has all sub-dirs present in updated
 	for _, dir := range updatedSubDirs.List() {
 		if err = os.MkdirAll(filepath.Join(localDir, dir), 0700); err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
+			return nil, errors.E(op, types.UniquePath(localDir), err)
 		}
 	}
 
-	// replace all non KRM files in local with the ones in updated
+	// replace all non KRM files in local with the ones in updated, and merge
+	// the ones which were modified in both
+	var conflicts []merge.Conflict
 	for _, file := range updatedFiles.List() {
-		if locallyModifiedFiles.Has(file) {
-			// skip syncing locally modified files
+		if !locallyModifiedFiles.Has(file) {
+			err = copyutil.SyncFile(filepath.Join(updatedDir, file), filepath.Join(localDir, file))
+			if err != nil {
+				return nil, errors.E(op, types.UniquePath(localDir), err)
+			}
+			continue
+		}
+		if !originalFiles.Has(file) {
+			// skip syncing files added both locally and in updated
+			continue
+		}
+		rel := strings.TrimPrefix(filepath.ToSlash(file), "/")
+		skip, err := matchesAny(noMerge, rel)
+		if err != nil {
+			return nil, errors.E(op, types.UniquePath(localDir), err)
+		}
+		if skip {
+			// skip syncing locally modified files which shouldn't be merged
 			continue
 		}
-		err = copyutil.SyncFile(filepath.Join(updatedDir, file), filepath.Join(localDir, file))
+		conflict, err := mergeFile(filepath.Join(originalDir, file), filepath.Join(updatedDir, file),
+			filepath.Join(localDir, file))
 		if err != nil {
-			return errors.E(op, types.UniquePath(localDir), err)
+			return nil, errors.E(op, types.UniquePath(localDir), err)
+		}
+		if conflict {
+			conflicts = append(conflicts, merge.Conflict{
+				File:       rel,
+				Resolution: merge.ResolutionConflictMarkers,
+			})
 		}
 	}
 
@@ -241,7 +336,71 @@ func ReplaceNonKRMFiles(updatedDir, originalDir, localDir string) error {
 		}
 	}
 
-	return nil
+	return conflicts, nil
+}
+
+// mergeFile merges the changes from the original to the updated file into
+// the local file, and returns true if the merge has conflicts. Binary files
+// aren't merged, so local changes to them are kept.
+func mergeFile(originalFile, updatedFile, localFile string) (bool, error) {
+	const op errors.Op = "update.mergeFile"
+	var contents [][]byte
+	for _, f := range []string{originalFile, updatedFile, localFile} {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return false, errors.E(op, errors.IO, err)
+		}
+		if bytes.IndexByte(b, 0) >= 0 {
+			return false, nil
+		}
+		contents = append(contents, b)
+	}
+	merged, conflicts := merge.Text(contents[0], contents[2], contents[1])
+	if bytes.Equal(merged, contents[2]) {
+		return false, nil
+	}
+	info, err := os.Stat(localFile)
+	if err != nil {
+		return false, errors.E(op, errors.IO, err)
+	}
+	if err := ioutil.WriteFile(localFile, merged, info.Mode()); err != nil {
+		return false, errors.E(op, errors.IO, err)
+	}
+	return conflicts > 0, nil
+}
+
+// validateUpstream validates the upstream section of the Kptfile of the
+// package in path, if the package has a Kptfile.
+func validateUpstream(path string) error {
+	kf, err := pkg.ReadKptfile(path)
+	if err != nil {
+		if goerrors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return kf.Upstream.Validate()
+}
+
+// matchesAny returns true if the slash-separated path of a file relative to
+// the package matches any of the glob patterns. Patterns without a slash are
+// matched against the file name.
+func matchesAny(patterns []string, file string) (bool, error) {
+	const op errors.Op = "update.matchesAny"
+	for _, p := range patterns {
+		name := file
+		if !strings.Contains(p, "/") {
+			name = path.Base(file)
+		}
+		match, err := path.Match(strings.TrimPrefix(p, "/"), name)
+		if err != nil {
+			return false, errors.E(op, errors.InvalidParam, fmt.Errorf("invalid noMerge pattern %q: %w", p, err))
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // getSubDirsAndNonKrmFiles returns the list of all non git sub dirs and, non git+non KRM files
@@ -16,6 +16,7 @@
 package update_test
 
 import (
+	"io/ioutil"
 	"path/filepath"
 	"testing"
 
@@ -300,3 +301,84 @@ func TestUpdate_ResourceMerge(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdate_ResourceMerge_noMerge(t *testing.T) {
+	kptfile := func(ref string, noMerge string) string {
+		return `apiVersion: kpt.dev/v1
+kind: Kptfile
+metadata:
+  name: foo
+upstream:
+  type: git
+  git:
+    repo: https://github.com/GoogleContainerTools/kpt
+    directory: /foo
+    ref: ` + ref + `
+  updateStrategy: resource-merge
+` + noMerge
+	}
+	testCases := map[string]struct {
+		updatedNoMerge  string
+		expectedErr     string
+		expectedReadme  string
+		expectedKptfile string
+	}{
+		"noMerge patterns added in upstream are used": {
+			updatedNoMerge:  "  noMerge:\n    - '*.md'\n",
+			expectedReadme:  "local\n",
+			expectedKptfile: kptfile("v2", "  noMerge:\n    - '*.md'\n"),
+		},
+		"invalid noMerge patterns fail before the package is changed": {
+			updatedNoMerge:  "  noMerge:\n    - '[docs'\n",
+			expectedErr:     "upstream.noMerge[0]",
+			expectedReadme:  "local\n",
+			expectedKptfile: kptfile("v1", ""),
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			files := map[string]map[string]string{
+				"origin":  {"Kptfile": kptfile("v1", ""), "README.md": "origin\n"},
+				"local":   {"Kptfile": kptfile("v1", ""), "README.md": "local\n"},
+				"updated": {"Kptfile": kptfile("v2", tc.updatedNoMerge), "README.md": "updated\n"},
+			}
+			paths := map[string]string{}
+			for name, content := range files {
+				paths[name] = t.TempDir()
+				for file, c := range content {
+					err := ioutil.WriteFile(filepath.Join(paths[name], file), []byte(c), 0600)
+					if !assert.NoError(t, err) {
+						t.FailNow()
+					}
+				}
+			}
+
+			err := (&ResourceMergeUpdater{}).Update(UpdateOptions{
+				RelPackagePath: "/",
+				OriginPath:     paths["origin"],
+				LocalPath:      paths["local"],
+				UpdatedPath:    paths["updated"],
+			})
+			if tc.expectedErr != "" {
+				if !assert.Error(t, err) {
+					t.FailNow()
+				}
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(paths["local"], "README.md"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedReadme, string(b))
+			b, err = ioutil.ReadFile(filepath.Join(paths["local"], "Kptfile"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedKptfile, string(b))
+		})
+	}
+}
@@ -31,11 +31,15 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/util/addmergecomment"
 	"github.com/GoogleContainerTools/kpt/internal/util/fetch"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/local"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
 	"github.com/GoogleContainerTools/kpt/internal/util/pkgutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/stack"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 // PkgNotGitRepoError is the error type returned if the package being updated is not inside
@@ -78,6 +82,15 @@ type UpdateOptions struct {
 	// updated and origin were fetched based on the information in the
 	// Kptfile from this package.
 	IsRoot bool
+
+	// Conflicts collects the resources and fields which were changed both
+	// upstream and locally, and how they were merged. Paths are relative to
+	// LocalPath. It is optional.
+	Conflicts *merge.ConflictReport
+
+	// Resolver chooses the values of fields which were changed both upstream
+	// and locally. It is optional.
+	Resolver merge.ConflictResolver
 }
 
 // Updater updates a local package
@@ -101,6 +114,32 @@ type Command struct {
 
 	// Strategy is the update strategy to use
 	Strategy kptfilev1.UpdateStrategyType
+
+	// DryRun performs the update in a staging copy of the package and
+	// prints the changes, instead of changing the local package.
+	DryRun bool
+
+	// FailOnConflict aborts the update without changing the local package if
+	// any resources or fields were changed both upstream and locally.
+	FailOnConflict bool
+
+	// ConflictReportPath is the path of the file the conflicts of the update
+	// are written to as YAML. It is optional.
+	ConflictReportPath string
+
+	// Resolver chooses the values of fields which were changed both upstream
+	// and locally with the resource-merge strategy, e.g. by prompting the
+	// user. The upstream values are used if it isn't set.
+	Resolver merge.ConflictResolver
+
+	// conflicts collects the conflicts of the update.
+	conflicts *merge.ConflictReport
+
+	// localPkg is the local package if Pkg is a staging copy of it.
+	localPkg *pkg.Pkg
+
+	// preview collects the changes of the update if Pkg is a staging copy.
+	preview *Preview
 }
 
 // Run runs the Command.
@@ -112,22 +151,55 @@ func (u Command) Run(ctx context.Context) error {
 		return errors.E(op, errors.MissingParam, "pkg must be provided")
 	}
 
-	// require package is checked into git before trying to update it
-	if err := checkIfCommitted(ctx, u.Pkg); err != nil {
-		return errors.E(op, u.Pkg.UniquePath, err)
+	if u.DryRun {
+		return u.printPreview(ctx)
+	}
+
+	// require package is checked into git before trying to update it, unless
+	// it is a staging copy
+	if u.localPkg == nil {
+		if err := checkIfCommitted(ctx, u.Pkg); err != nil {
+			return errors.E(op, u.Pkg.UniquePath, err)
+		}
+		if u.FailOnConflict {
+			if err := u.updateFromStaged(ctx); err != nil {
+				return errors.E(op, u.Pkg.UniquePath, err)
+			}
+			return nil
+		}
 	}
+	u.conflicts = &merge.ConflictReport{}
 
 	rootKf, err := u.Pkg.Kptfile()
 	if err != nil {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
 
-	if rootKf.Upstream == nil || rootKf.Upstream.Git == nil {
+	if !hasUpstream(rootKf) {
 		return errors.E(op, u.Pkg.UniquePath,
 			fmt.Errorf("package must have an upstream reference"))
 	}
 	if u.Ref != "" {
-		rootKf.Upstream.Git.Ref = u.Ref
+		switch {
+		case rootKf.Upstream.Oci != nil:
+			// the ref is the tag of the image
+			image, err := oci.WithTag(rootKf.Upstream.Oci.Image, u.Ref)
+			if err != nil {
+				return errors.E(op, u.Pkg.UniquePath, err)
+			}
+			rootKf.Upstream.Oci.Image = image
+		case rootKf.Upstream.Local != nil:
+			// the ref is the path of the new version of the package,
+			// relative to the current working directory. Like in kpt pkg
+			// get, relative paths are stored relative to the package.
+			path, err := localUpstreamPath(u.Ref, u.Pkg.UniquePath.String())
+			if err != nil {
+				return errors.E(op, u.Pkg.UniquePath, err)
+			}
+			rootKf.Upstream.Local.Path = path
+		default:
+			rootKf.Upstream.Git.Ref = u.Ref
+		}
 	}
 	if u.Strategy != "" {
 		rootKf.Upstream.UpdateStrategy = u.Strategy
@@ -162,17 +234,33 @@ func (u Command) Run(ctx context.Context) error {
 				return errors.E(op, p.UniquePath, err)
 			}
 
-			if subKf.Upstream != nil && subKf.Upstream.Git != nil {
+			if hasUpstream(subKf) {
 				s.Push(subPkg)
 			}
 		}
 	}
-	pr.Printf("\nUpdated %d package(s).\n", packageCount)
+	if u.localPkg != nil {
+		u.preview.Conflicts = u.conflicts.Conflicts
+		u.preview.packageCount = packageCount
+	} else {
+		pr.Printf("\nUpdated %d package(s).\n", packageCount)
+		printConflicts(ctx, u.conflicts.Conflicts)
+		if err := u.writeConflictReport(); err != nil {
+			return errors.E(op, u.Pkg.UniquePath, err)
+		}
+	}
 
 	// finally, make sure that the merge comments are added to all resources in the updated package
 	if err := addmergecomment.Process(string(u.Pkg.UniquePath)); err != nil {
 		return errors.E(op, u.Pkg.UniquePath, err)
 	}
+
+	// files merged with conflict markers fail the update after it is done,
+	// so they can be resolved before the package is committed
+	if n := u.conflicts.Unresolved(); n > 0 && u.localPkg == nil {
+		return errors.E(op, u.Pkg.UniquePath,
+			fmt.Errorf("%d file(s) have conflicts, resolve the conflict markers and commit the package", n))
+	}
 	return nil
 }
 
@@ -247,29 +335,15 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 	pr := printer.FromContextOrDie(ctx)
 	pr.PrintPackage(p, !(p == u.Pkg))
 
-	g := kf.Upstream.Git
-	updated := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
-	pr.Printf("Fetching upstream from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-	if err := fetch.ClonerUsingGitExec(ctx, updated); err != nil {
+	localDir, err := u.localDir(p)
+	if err != nil {
 		return errors.E(op, p.UniquePath, err)
 	}
-	defer os.RemoveAll(updated.AbsPath())
-
-	var origin repoClone
-	if kf.UpstreamLock != nil {
-		gLock := kf.UpstreamLock.Git
-		originRepoSpec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
-		pr.Printf("Fetching origin from %s@%s\n", kf.Upstream.Git.Repo, kf.Upstream.Git.Ref)
-		if err := fetch.ClonerUsingGitExec(ctx, originRepoSpec); err != nil {
-			return errors.E(op, p.UniquePath, err)
-		}
-		origin = originRepoSpec
-	} else {
-		origin, err = newNilRepoClone()
-		if err != nil {
-			return errors.E(op, p.UniquePath, err)
-		}
+	updated, origin, err := fetchUpstreamAndOrigin(ctx, kf, localDir)
+	if err != nil {
+		return errors.E(op, p.UniquePath, err)
 	}
+	defer os.RemoveAll(updated.AbsPath())
 	defer os.RemoveAll(origin.AbsPath())
 
 	s := stack.New()
@@ -300,12 +374,130 @@ func (u Command) updateRootPackage(ctx context.Context, p *pkg.Pkg) error {
 		}
 	}
 
-	if err := kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updated); err != nil {
+	if u.preview != nil {
+		if err := u.recordKept(p, origin.AbsPath(), updated.AbsPath()); err != nil {
+			return errors.E(op, p.UniquePath, err)
+		}
+	}
+
+	switch updated := updated.(type) {
+	case *oci.ImageSpec:
+		err = kptfileutil.UpdateUpstreamLockFromOci(p.UniquePath.String(), updated)
+	case *local.PackageSpec:
+		err = kptfileutil.UpdateUpstreamLockFromLocal(p.UniquePath.String(), updated)
+	case *git.RepoSpec:
+		err = kptfileutil.UpdateUpstreamLockFromGit(p.UniquePath.String(), updated)
+	}
+	if err != nil {
 		return errors.E(op, p.UniquePath, err)
 	}
 	return nil
 }
 
+// hasUpstream returns true if the Kptfile has an upstream of a known type.
+func hasUpstream(kf *kptfilev1.KptFile) bool {
+	return kf.Upstream != nil &&
+		(kf.Upstream.Git != nil || kf.Upstream.Oci != nil || kf.Upstream.Local != nil)
+}
+
+// localUpstreamPath returns the path of a local upstream given relative to
+// the current working directory, relative to pkgDir. Absolute paths are
+// returned unchanged.
+func localUpstreamPath(path, pkgDir string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(pkgDir, absPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// fetchUpstreamAndOrigin fetches the upstream version of the package that
+// the package is updated to, and the origin version that the package was
+// last fetched from. The origin is empty if the package hasn't been fetched.
+// Relative local paths are relative to pkgDir.
+func fetchUpstreamAndOrigin(ctx context.Context, kf *kptfilev1.KptFile, pkgDir string) (repoClone, repoClone, error) {
+	const op errors.Op = "update.fetchUpstreamAndOrigin"
+	pr := printer.FromContextOrDie(ctx)
+
+	var updated repoClone
+	switch {
+	case kf.Upstream.Oci != nil:
+		spec := &oci.ImageSpec{Image: kf.Upstream.Oci.Image}
+		pr.Printf("Fetching upstream from %s\n", spec.Image)
+		if err := oci.Pull(ctx, spec); err != nil {
+			return nil, nil, errors.E(op, err)
+		}
+		updated = spec
+	case kf.Upstream.Local != nil:
+		spec := &local.PackageSpec{Path: kf.Upstream.Local.Path}
+		pr.Printf("Fetching upstream from %s\n", spec.Path)
+		if err := local.Load(spec, pkgDir); err != nil {
+			return nil, nil, errors.E(op, err)
+		}
+		updated = spec
+	default:
+		g := kf.Upstream.Git
+		spec := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Ref}
+		pr.Printf("Fetching upstream from %s@%s\n", g.Repo, g.Ref)
+		if err := fetch.ClonerUsingGitExec(ctx, spec); err != nil {
+			return nil, nil, errors.E(op, err)
+		}
+		updated = spec
+	}
+
+	var origin repoClone
+	var err error
+	switch {
+	case kf.UpstreamLock != nil && kf.UpstreamLock.Oci != nil:
+		lock := kf.UpstreamLock.Oci
+		// the origin is pulled by digest, since the tag may have moved
+		image, err := oci.WithDigest(lock.Image, lock.Digest)
+		if err != nil {
+			os.RemoveAll(updated.AbsPath())
+			return nil, nil, errors.E(op, err)
+		}
+		spec := &oci.ImageSpec{Image: image}
+		pr.Printf("Fetching origin from %s\n", lock.Image)
+		if err := oci.Pull(ctx, spec); err != nil {
+			os.RemoveAll(updated.AbsPath())
+			return nil, nil, errors.E(op, err)
+		}
+		origin = spec
+	case kf.UpstreamLock != nil && kf.UpstreamLock.Local != nil:
+		lock := kf.UpstreamLock.Local
+		spec := &local.PackageSpec{Path: lock.Path, Hash: lock.Hash}
+		pr.Printf("Fetching origin from %s\n", lock.Path)
+		if err := local.LoadSnapshot(spec, pkgDir); err != nil {
+			os.RemoveAll(updated.AbsPath())
+			return nil, nil, errors.E(op, err)
+		}
+		origin = spec
+	case kf.UpstreamLock != nil && kf.UpstreamLock.Git != nil:
+		gLock := kf.UpstreamLock.Git
+		spec := &git.RepoSpec{OrgRepo: gLock.Repo, Path: gLock.Directory, Ref: gLock.Commit}
+		pr.Printf("Fetching origin from %s@%s\n", gLock.Repo, gLock.Ref)
+		if err := fetch.ClonerUsingGitExec(ctx, spec); err != nil {
+			os.RemoveAll(updated.AbsPath())
+			return nil, nil, errors.E(op, err)
+		}
+		origin = spec
+	default:
+		origin, err = newNilRepoClone()
+		if err != nil {
+			os.RemoveAll(updated.AbsPath())
+			return nil, nil, errors.E(op, err)
+		}
+	}
+	return updated, origin, nil
+}
+
 // updatePackage takes care of updating a single package. The absolute paths to
 // the local, updated and origin packages are provided, as well as the path to the
 // package relative to the root.
@@ -452,15 +644,27 @@ func (u Command) mergePackage(ctx context.Context, localPath, updatedPath, origi
 			fmt.Errorf("unrecognized update strategy %s", u.Strategy))
 	}
 	pr.Printf("Updating package %q with strategy %q.\n", packageName(localPath), pkgKf.Upstream.UpdateStrategy)
+	conflicts := &merge.ConflictReport{}
 	if err := updater().Update(UpdateOptions{
 		RelPackagePath: relPath,
 		LocalPath:      localPath,
 		UpdatedPath:    updatedPath,
 		OriginPath:     originPath,
 		IsRoot:         isRootPkg,
+		Conflicts:      conflicts,
+		Resolver:       u.Resolver,
 	}); err != nil {
 		return errors.E(op, types.UniquePath(localPath), err)
 	}
+	if u.conflicts != nil {
+		// conflicts are reported with paths relative to the parent directory
+		// of the package being updated, like the display paths of packages
+		rel, err := filepath.Rel(filepath.Dir(u.Pkg.UniquePath.String()), localPath)
+		if err != nil {
+			return errors.E(op, types.UniquePath(localPath), err)
+		}
+		u.conflicts.Add(filepath.ToSlash(rel), conflicts.Conflicts...)
+	}
 
 	return nil
 }
@@ -468,3 +672,111 @@ func (u Command) mergePackage(ctx context.Context, localPath, updatedPath, origi
 func packageName(path string) string {
 	return filepath.Base(path)
 }
+
+// updateFromStaged performs the update in a staging copy of the package,
+// and replaces the content of the local package with the result if the
+// update has no conflicts. Otherwise the conflicts are reported like after
+// an update, and the local package is not changed. Upstream is only fetched
+// once, so the package can't be updated to a different version than the one
+// checked for conflicts.
+func (u Command) updateFromStaged(ctx context.Context) error {
+	const op errors.Op = "update.updateFromStaged"
+	pr := printer.FromContextOrDie(ctx)
+	dir, err := ioutil.TempDir("", "kpt-update-")
+	if err != nil {
+		return errors.E(op, errors.IO, fmt.Errorf("error creating a temporary directory: %w", err))
+	}
+	defer os.RemoveAll(dir)
+
+	u.Resolver = nil
+	stagedPath, p, err := u.stage(ctx, dir)
+	if err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	u.conflicts = &merge.ConflictReport{Conflicts: p.Conflicts}
+	if len(p.Conflicts) > 0 {
+		printConflicts(ctx, p.Conflicts)
+		if err := u.writeConflictReport(); err != nil {
+			return errors.E(op, u.Pkg.UniquePath, err)
+		}
+		return errors.E(op, u.Pkg.UniquePath,
+			fmt.Errorf("update has %d conflict(s), the package was not changed", len(p.Conflicts)))
+	}
+
+	if err := replaceContent(stagedPath, u.Pkg.UniquePath.String()); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	pr.Printf("\nUpdated %d package(s).\n", p.packageCount)
+	if err := u.writeConflictReport(); err != nil {
+		return errors.E(op, u.Pkg.UniquePath, err)
+	}
+	return nil
+}
+
+// replaceContent makes the content of the directory dst the same as the
+// content of src. The .git directories in dst are kept.
+func replaceContent(src, dst string) error {
+	err := filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil || path == dst {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+		srcInfo, err := os.Lstat(filepath.Join(src, rel))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil && srcInfo.IsDir() == info.IsDir() {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return copyutil.CopyDir(src, dst)
+}
+
+// printConflicts prints the conflicts of an update.
+func printConflicts(ctx context.Context, conflicts []merge.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	pr := printer.FromContextOrDie(ctx)
+	pr.Printf("\nFound %d conflict(s):\n", len(conflicts))
+	for _, c := range conflicts {
+		pr.Printf("  %s\n", c)
+	}
+}
+
+// writeConflictReport writes the conflicts of the update to the
+// ConflictReportPath as YAML, if it is set.
+func (u Command) writeConflictReport() error {
+	const op errors.Op = "update.writeConflictReport"
+	if u.ConflictReportPath == "" {
+		return nil
+	}
+	report := u.conflicts
+	if report == nil || report.Conflicts == nil {
+		report = &merge.ConflictReport{Conflicts: []merge.Conflict{}}
+	}
+	b, err := yaml.Marshal(report)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if err := ioutil.WriteFile(u.ConflictReportPath, b, 0600); err != nil {
+		return errors.E(op, errors.IO, types.UniquePath(u.ConflictReportPath), err)
+	}
+	return nil
+}
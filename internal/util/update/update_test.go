@@ -15,11 +15,16 @@
 package update_test
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
@@ -27,9 +32,13 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/printer/fake"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
 	. "github.com/GoogleContainerTools/kpt/internal/util/update"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/google/go-containerregistry/pkg/registry"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/kio"
@@ -1724,13 +1733,8 @@ func TestCommand_Run_local_subpackages(t *testing.T) {
 					strategies: []kptfilev1.UpdateStrategyType{
 						kptfilev1.ResourceMerge,
 					},
-					expectedLocal: pkgbuilder.NewRootPkg().
-						WithKptfile(
-							pkgbuilder.NewKptfile().
-								WithUpstreamRef("upstream", "/", masterBranch, "PLACEHOLDER").
-								WithUpstreamLockRef("upstream", "/", masterBranch, 1),
-						).
-						WithFile("data.txt", "local content"),
+					// the file is merged with conflict markers
+					expectedErrMsg: "1 file(s) have conflicts",
 				},
 				{
 					strategies: []kptfilev1.UpdateStrategyType{
@@ -3543,10 +3547,842 @@ func TestReplaceNonKRMFiles(t *testing.T) {
 			// expectedLocal.
 			err = ioutil.WriteFile(filepath.Join(updated, "new.yaml"), []byte("a: b"), 0600)
 			assert.NoError(t, err)
-			err = ReplaceNonKRMFiles(updated, original, local)
+			_, err = ReplaceNonKRMFiles(updated, original, local, nil)
 			assert.NoError(t, err)
 			tg := testutil.TestGitRepo{}
 			tg.AssertEqual(t, local, filepath.Join(expectedLocal), false)
 		})
 	}
 }
+
+// TestCommand_Run_oci updates a package fetched from an OCI registry to a
+// new tag with each of the update strategies.
+func TestCommand_Run_oci(t *testing.T) {
+	testCases := map[string]struct {
+		strategy kptfilev1.UpdateStrategyType
+		// localChange is appended to the local ConfigMap before the update.
+		localChange    string
+		expectedData   []string
+		removedData    []string
+		expectedErrMsg string
+	}{
+		"resource-merge keeps local changes": {
+			strategy:     kptfilev1.ResourceMerge,
+			localChange:  "  b: local\n",
+			expectedData: []string{`a: "2"`, "b: local"},
+		},
+		"fast-forward without local changes": {
+			strategy:     kptfilev1.FastForward,
+			expectedData: []string{`a: "2"`},
+		},
+		"fast-forward with local changes": {
+			strategy:       kptfilev1.FastForward,
+			localChange:    "  b: local\n",
+			expectedErrMsg: "local package files have been modified",
+		},
+		"force-delete-replace discards local changes": {
+			strategy:     kptfilev1.ForceDeleteReplace,
+			localChange:  "  b: local\n",
+			expectedData: []string{`a: "2"`},
+			removedData:  []string{"b: local"},
+		},
+	}
+
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			s := httptest.NewServer(registry.New(registry.Logger(log.New(ioutil.Discard, "", 0))))
+			defer s.Close()
+			repo := strings.TrimPrefix(s.URL, "http://") + "/packages/app"
+
+			upstream, err := ioutil.TempDir("", "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(upstream)
+			w, clean := testutil.SetupWorkspace(t)
+			defer clean()
+
+			ctx := fake.CtxWithDefaultPrinter()
+			push := func(tag, value string) string {
+				kptfile := "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n"
+				configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  a: \"" + value + "\"\n"
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(upstream, kptfilev1.KptFileName), []byte(kptfile), 0600))
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(upstream, "cm.yaml"), []byte(configMap), 0600))
+				digest, err := oci.Push(ctx, upstream, repo+":"+tag)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				return digest
+			}
+			push("v1", "1")
+
+			local := filepath.Join(w.WorkspaceDirectory, "app")
+			err = get.Command{
+				Oci:         &kptfilev1.Oci{Image: repo + ":v1"},
+				Destination: local,
+			}.Run(ctx)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			if tc.localChange != "" {
+				b, err := ioutil.ReadFile(filepath.Join(local, "cm.yaml"))
+				assert.NoError(t, err)
+				b = append(b, []byte(tc.localChange)...)
+				assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "cm.yaml"), b, 0600))
+			}
+			add := exec.Command("git", "add", ".")
+			add.Dir = w.WorkspaceDirectory
+			if !assert.NoError(t, add.Run()) {
+				t.FailNow()
+			}
+			_, err = w.Commit("fetch package")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			digest := push("v2", "2")
+			err = Command{
+				Pkg:      pkgtest.CreatePkgOrFail(t, local),
+				Ref:      "v2",
+				Strategy: tc.strategy,
+			}.Run(ctx)
+			if tc.expectedErrMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expectedErrMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(local, "cm.yaml"))
+			assert.NoError(t, err)
+			for _, d := range tc.expectedData {
+				assert.Contains(t, string(b), d)
+			}
+			for _, d := range tc.removedData {
+				assert.NotContains(t, string(b), d)
+			}
+
+			kf, err := pkg.ReadKptfile(local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, &kptfilev1.Oci{Image: repo + ":v2"}, kf.Upstream.Oci)
+			assert.Equal(t, &kptfilev1.OciLock{Image: repo + ":v2", Digest: digest}, kf.UpstreamLock.Oci)
+		})
+	}
+}
+
+// TestCommand_Run_local updates a package fetched from a local archive to a
+// new archive after the original archive has been removed, and verifies that
+// local changes are kept.
+func TestCommand_Run_local(t *testing.T) {
+	vendor, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(vendor)
+	w, clean := testutil.SetupWorkspace(t)
+	defer clean()
+
+	writeArchive := func(name, value string) {
+		src := filepath.Join(vendor, "src")
+		assert.NoError(t, os.MkdirAll(src, 0700))
+		defer os.RemoveAll(src)
+		kptfile := "apiVersion: kpt.dev/v1\nkind: Kptfile\nmetadata:\n  name: app\n"
+		configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  a: \"" + value + "\"\n"
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, kptfilev1.KptFileName), []byte(kptfile), 0600))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(src, "cm.yaml"), []byte(configMap), 0600))
+		cmd := exec.Command("tar", "-czf", filepath.Join(vendor, name), "-C", src, ".")
+		if out, err := cmd.CombinedOutput(); !assert.NoError(t, err, string(out)) {
+			t.FailNow()
+		}
+	}
+	writeArchive("app-v1.tar.gz", "1")
+
+	ctx := fake.CtxWithDefaultPrinter()
+	local := filepath.Join(w.WorkspaceDirectory, "app")
+	err = get.Command{
+		Local:       &kptfilev1.Local{Path: filepath.Join(vendor, "app-v1.tar.gz")},
+		Destination: local,
+	}.Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(local, "cm.yaml"))
+	assert.NoError(t, err)
+	b = append(b, []byte("  b: local\n")...)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(local, "cm.yaml"), b, 0600))
+	add := exec.Command("git", "add", ".")
+	add.Dir = w.WorkspaceDirectory
+	if !assert.NoError(t, add.Run()) {
+		t.FailNow()
+	}
+	_, err = w.Commit("fetch package")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the origin is read from the snapshot in the cache
+	assert.NoError(t, os.Remove(filepath.Join(vendor, "app-v1.tar.gz")))
+	writeArchive("app-v2.tar.gz", "2")
+	err = Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, local),
+		Ref: filepath.Join(vendor, "app-v2.tar.gz"),
+	}.Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(local, "cm.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `a: "2"`)
+	assert.Contains(t, string(b), "b: local")
+
+	kf, err := pkg.ReadKptfile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, &kptfilev1.Local{Path: filepath.Join(vendor, "app-v2.tar.gz")}, kf.Upstream.Local)
+	assert.Equal(t, filepath.Join(vendor, "app-v2.tar.gz"), kf.UpstreamLock.Local.Path)
+	assert.Regexp(t, "^sha256:[0-9a-f]{64}$", kf.UpstreamLock.Local.Hash)
+
+	// relative refs are relative to the current directory, and are
+	// recorded relative to the package
+	add = exec.Command("git", "add", ".")
+	add.Dir = w.WorkspaceDirectory
+	if !assert.NoError(t, add.Run()) {
+		t.FailNow()
+	}
+	_, err = w.Commit("update package")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	writeArchive("app-v3.tar.gz", "3")
+	cwd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer func() {
+		_ = os.Chdir(cwd)
+	}()
+	if !assert.NoError(t, os.Chdir(vendor)) {
+		t.FailNow()
+	}
+	err = Command{
+		Pkg: pkgtest.CreatePkgOrFail(t, local),
+		Ref: "app-v3.tar.gz",
+	}.Run(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err = ioutil.ReadFile(filepath.Join(local, "cm.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `a: "3"`)
+	kf, err = pkg.ReadKptfile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	relPath, err := filepath.Rel(local, filepath.Join(vendor, "app-v3.tar.gz"))
+	assert.NoError(t, err)
+	assert.Equal(t, &kptfilev1.Local{Path: filepath.ToSlash(relPath)}, kf.Upstream.Local)
+}
+
+// TestCommand_Run_semverConstraint updates a package to the highest tag
+// matching a semantic version constraint, and verifies that the constraint is
+// kept in the upstream and the tag is recorded in the upstreamLock.
+func TestCommand_Run_semverConstraint(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Data:   testutil.Dataset1,
+					Branch: masterBranch,
+				},
+				{
+					Data: testutil.Dataset2,
+					Tag:  "v1.0.0",
+				},
+				{
+					Data: testutil.Dataset3,
+					Tag:  "v1.1.0",
+				},
+				{
+					Data: testutil.Dataset4,
+					Tag:  "v2.0.0",
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		return
+	}
+	upstreamRepo := g.Repos[testutil.Upstream]
+
+	if !assert.NoError(t, Command{
+		Pkg:      pkgtest.CreatePkgOrFail(t, g.LocalWorkspace.FullPackagePath()),
+		Strategy: kptfilev1.ResourceMerge,
+		Ref:      "^1.0",
+	}.Run(fake.CtxWithDefaultPrinter())) {
+		return
+	}
+
+	if !g.AssertLocalDataEquals(testutil.Dataset3, true) {
+		return
+	}
+	if !assert.NoError(t, upstreamRepo.CheckoutBranch("v1.1.0", false)) {
+		return
+	}
+	commit, err := upstreamRepo.GetCommit()
+	if !assert.NoError(t, err) {
+		return
+	}
+	kf, err := pkg.ReadKptfile(g.LocalWorkspace.FullPackagePath())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "^1.0", kf.Upstream.Git.Ref)
+	assert.Equal(t, "v1.1.0", kf.UpstreamLock.Git.Ref)
+	assert.Equal(t, commit, kf.UpstreamLock.Git.Commit)
+}
+
+func TestCommand_Preview(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource).
+						WithResource(pkgbuilder.ConfigMapResource),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource,
+							pkgbuilder.SetFieldPath("42", "spec", "replicas")).
+						WithResource(pkgbuilder.SecretResource),
+				},
+			},
+		},
+		LocalChanges: []testutil.Content{
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource).
+					WithResource(pkgbuilder.ConfigMapResource,
+						pkgbuilder.SetFieldPath("baz", "data", "foo")),
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		t.FailNow()
+	}
+	name := g.Repos[testutil.Upstream].RepoName
+	localPath := g.LocalWorkspace.FullPackagePath()
+	before, err := ioutil.ReadFile(filepath.Join(localPath, kptfilev1.KptFileName))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	p, err := Command{
+		Pkg:      pkgtest.CreatePkgOrFail(t, localPath),
+		Ref:      masterBranch,
+		Strategy: kptfilev1.ResourceMerge,
+	}.Preview(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, []string{fmt.Sprintf("Secret/secret (%s/secret.yaml)", name)}, p.Added)
+	assert.Empty(t, p.Deleted)
+	assert.Equal(t, []string{fmt.Sprintf("Deployment/myspace/mysql-deployment (%s/deployment.yaml)", name)}, p.Modified)
+	assert.Equal(t, []string{fmt.Sprintf("ConfigMap/configmap (%s/configmap.yaml)", name)}, p.Kept)
+	assert.Contains(t, p.Diff, fmt.Sprintf("+++ b/%s/Kptfile", name))
+	assert.Contains(t, p.Diff, "+  replicas: 42")
+	assert.Contains(t, p.Diff, fmt.Sprintf("--- /dev/null\n+++ b/%s/secret.yaml", name))
+
+	// the local package is unchanged
+	after, err := ioutil.ReadFile(filepath.Join(localPath, kptfilev1.KptFileName))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, string(before), string(after))
+	_, err = os.Stat(filepath.Join(localPath, "secret.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCommand_Run_conflicts(t *testing.T) {
+	testCases := map[string]struct {
+		failOnConflict bool
+		expectedErr    string
+	}{
+		"conflicts are reported": {},
+		"fail on conflict": {
+			failOnConflict: true,
+			expectedErr:    "update has 2 conflict(s), the package was not changed",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				ReposChanges: map[string][]testutil.Content{
+					testutil.Upstream: {
+						{
+							Pkg: pkgbuilder.NewRootPkg().
+								WithResource(pkgbuilder.DeploymentResource).
+								WithResource(pkgbuilder.ConfigMapResource),
+							Branch: masterBranch,
+						},
+						{
+							Pkg: pkgbuilder.NewRootPkg().
+								WithResource(pkgbuilder.DeploymentResource,
+									pkgbuilder.SetFieldPath("42", "spec", "replicas")),
+						},
+					},
+				},
+				LocalChanges: []testutil.Content{
+					{
+						Pkg: pkgbuilder.NewRootPkg().
+							WithResource(pkgbuilder.DeploymentResource,
+								pkgbuilder.SetFieldPath("21", "spec", "replicas")).
+							WithResource(pkgbuilder.ConfigMapResource,
+								pkgbuilder.SetFieldPath("baz", "data", "foo")),
+					},
+				},
+			}
+			defer g.Clean()
+			if !g.Init() {
+				t.FailNow()
+			}
+			name := g.Repos[testutil.Upstream].RepoName
+			localPath := g.LocalWorkspace.FullPackagePath()
+			before, err := ioutil.ReadFile(filepath.Join(localPath, "deployment.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			reportPath := filepath.Join(t.TempDir(), "conflicts.yaml")
+
+			err = Command{
+				Pkg:                pkgtest.CreatePkgOrFail(t, localPath),
+				Ref:                masterBranch,
+				Strategy:           kptfilev1.ResourceMerge,
+				FailOnConflict:     tc.failOnConflict,
+				ConflictReportPath: reportPath,
+			}.Run(fake.CtxWithDefaultPrinter())
+			if tc.expectedErr != "" {
+				if !assert.Error(t, err) {
+					t.FailNow()
+				}
+				assert.Contains(t, err.Error(), tc.expectedErr)
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			report, err := ioutil.ReadFile(reportPath)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, fmt.Sprintf(`conflicts:
+- resource: ConfigMap/configmap
+  file: %[1]s/configmap.yaml
+  resolution: kept-local
+- resource: apps/Deployment/myspace/mysql-deployment
+  file: %[1]s/deployment.yaml
+  field: spec.replicas
+  origin: "3"
+  local: "21"
+  upstream: "42"
+  resolution: took-upstream
+`, name), string(report))
+
+			after, err := ioutil.ReadFile(filepath.Join(localPath, "deployment.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if tc.failOnConflict {
+				assert.Equal(t, string(before), string(after))
+			} else {
+				assert.Contains(t, string(after), "replicas: 42")
+			}
+		})
+	}
+}
+
+func TestCommand_Run_failOnConflictUpdates(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource).
+						WithResource(pkgbuilder.ConfigMapResource),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource,
+							pkgbuilder.SetFieldPath("42", "spec", "replicas")).
+						WithResource(pkgbuilder.SecretResource),
+				},
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		t.FailNow()
+	}
+	localPath := g.LocalWorkspace.FullPackagePath()
+
+	// the package is updated from the staged update, which has no conflicts
+	err := Command{
+		Pkg:            pkgtest.CreatePkgOrFail(t, localPath),
+		Ref:            masterBranch,
+		Strategy:       kptfilev1.ResourceMerge,
+		FailOnConflict: true,
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	deployment, err := ioutil.ReadFile(filepath.Join(localPath, "deployment.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(deployment), "replicas: 42")
+	_, err = os.Stat(filepath.Join(localPath, "secret.yaml"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(localPath, "configmap.yaml"))
+	assert.True(t, os.IsNotExist(err))
+
+	commit, err := g.Repos[testutil.Upstream].GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	kf, err := pkg.ReadKptfile(localPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, commit, kf.UpstreamLock.Git.Commit)
+}
+
+func TestCommand_Run_interactive(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource,
+							pkgbuilder.SetFieldPath("42", "spec", "replicas")),
+				},
+			},
+		},
+		LocalChanges: []testutil.Content{
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource,
+						pkgbuilder.SetFieldPath("21", "spec", "replicas")),
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		t.FailNow()
+	}
+	localPath := g.LocalWorkspace.FullPackagePath()
+
+	prompts := &bytes.Buffer{}
+	err := Command{
+		Pkg:      pkgtest.CreatePkgOrFail(t, localPath),
+		Ref:      masterBranch,
+		Strategy: kptfilev1.ResourceMerge,
+		Resolver: NewPromptResolver(strings.NewReader("x\ne\n7\n"), prompts),
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, prompts.String(), "field spec.replicas")
+	assert.Contains(t, prompts.String(), "[o] origin:   3")
+	assert.Contains(t, prompts.String(), "[u] upstream: 42")
+	assert.Contains(t, prompts.String(), "[l] local:    21")
+	assert.Contains(t, prompts.String(), `Unknown choice "x"`)
+
+	after, err := ioutil.ReadFile(filepath.Join(localPath, "deployment.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(after), "replicas: 7")
+}
+
+func TestPromptResolver_Resolve(t *testing.T) {
+	c := merge.Conflict{Field: "data.foo", Origin: "bar", Upstream: "baz"}
+	testCases := map[string]struct {
+		conflict           merge.Conflict
+		input              string
+		expectedResolution string
+		expectedValue      string
+		expectedOutput     string
+		expectedErr        string
+	}{
+		"default":  {input: "\n", expectedResolution: merge.ResolutionTookUpstream},
+		"local":    {input: "l\n", expectedResolution: merge.ResolutionTookLocal},
+		"origin":   {input: "O\n", expectedResolution: merge.ResolutionTookOrigin},
+		"edit":     {input: "e\nqux", expectedResolution: merge.ResolutionEdited, expectedValue: "qux"},
+		"no input": {input: "", expectedErr: "error reading the choice: EOF"},
+		"edit list": {
+			conflict:           merge.Conflict{Field: "data.foo", Origin: "[a]", Upstream: "[a, b]"},
+			input:              "e\nl\n",
+			expectedResolution: merge.ResolutionTookLocal,
+			expectedOutput:     "Only scalar values can be edited, must be one of: o,u,l\n",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			if tc.conflict.Field == "" {
+				tc.conflict = c
+			}
+			out := &bytes.Buffer{}
+			resolution, value, err := NewPromptResolver(strings.NewReader(tc.input), out).Resolve(tc.conflict)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedResolution, resolution)
+			assert.Equal(t, tc.expectedValue, value)
+			assert.Contains(t, out.String(), "[l] local:    <none>")
+			assert.Contains(t, out.String(), tc.expectedOutput)
+		})
+	}
+}
+
+func TestReplaceNonKRMFiles_merge(t *testing.T) {
+	testCases := map[string]struct {
+		noMerge           []string
+		local             string
+		expectedLocal     string
+		expectedConflicts []merge.Conflict
+	}{
+		"changes on different lines are merged": {
+			local:         "# local title\n\nsetup\n\nrun\n",
+			expectedLocal: "# local title\n\nsetup\n\nrun upstream\n",
+		},
+		"changes on the same lines have conflict markers": {
+			local: "# title\n\nsetup\n\nrun local\n",
+			expectedLocal: "# title\n\nsetup\n\n" +
+				"<<<<<<< local\nrun local\n=======\nrun upstream\n>>>>>>> upstream\n",
+			expectedConflicts: []merge.Conflict{
+				{File: "docs/README.md", Resolution: merge.ResolutionConflictMarkers},
+			},
+		},
+		"files matching noMerge by name are kept": {
+			noMerge:       []string{"*.md"},
+			local:         "# local title\n\nsetup\n\nrun\n",
+			expectedLocal: "# local title\n\nsetup\n\nrun\n",
+		},
+		"files matching noMerge by path are kept": {
+			noMerge:       []string{"docs/README.md"},
+			local:         "# local title\n\nsetup\n\nrun\n",
+			expectedLocal: "# local title\n\nsetup\n\nrun\n",
+		},
+	}
+	for tn, tc := range testCases {
+		tc := tc
+		t.Run(tn, func(t *testing.T) {
+			dirs := map[string]string{
+				"original": "# title\n\nsetup\n\nrun\n",
+				"updated":  "# title\n\nsetup\n\nrun upstream\n",
+				"local":    tc.local,
+			}
+			paths := map[string]string{}
+			for name, content := range dirs {
+				paths[name] = t.TempDir()
+				if !assert.NoError(t, os.MkdirAll(filepath.Join(paths[name], "docs"), 0700)) {
+					t.FailNow()
+				}
+				err := ioutil.WriteFile(filepath.Join(paths[name], "docs", "README.md"), []byte(content), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+
+			conflicts, err := ReplaceNonKRMFiles(paths["updated"], paths["original"], paths["local"], tc.noMerge)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedConflicts, conflicts)
+			b, err := ioutil.ReadFile(filepath.Join(paths["local"], "docs", "README.md"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, tc.expectedLocal, string(b))
+		})
+	}
+}
+
+func TestCommand_Run_nonKRMConflicts(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource).
+						WithFile("setup.sh", "echo setup\necho run\n"),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithResource(pkgbuilder.DeploymentResource).
+						WithFile("setup.sh", "echo setup\necho run upstream\n"),
+				},
+			},
+		},
+		LocalChanges: []testutil.Content{
+			{
+				Pkg: pkgbuilder.NewRootPkg().
+					WithResource(pkgbuilder.DeploymentResource).
+					WithFile("setup.sh", "echo setup\necho run local\n"),
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		t.FailNow()
+	}
+	name := g.Repos[testutil.Upstream].RepoName
+	localPath := g.LocalWorkspace.FullPackagePath()
+	reportPath := filepath.Join(t.TempDir(), "conflicts.yaml")
+
+	err := Command{
+		Pkg:                pkgtest.CreatePkgOrFail(t, localPath),
+		Ref:                masterBranch,
+		Strategy:           kptfilev1.ResourceMerge,
+		ConflictReportPath: reportPath,
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "1 file(s) have conflicts")
+
+	b, err := ioutil.ReadFile(filepath.Join(localPath, "setup.sh"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "echo setup\n<<<<<<< local\necho run local\n=======\necho run upstream\n>>>>>>> upstream\n", string(b))
+
+	report, err := ioutil.ReadFile(reportPath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, fmt.Sprintf(`conflicts:
+- file: %s/setup.sh
+  resolution: conflict-markers
+`, name), string(report))
+}
+
+func TestCommand_Run_openAPI(t *testing.T) {
+	openAPI := `definitions:
+  com.example.v1.App:
+    x-kubernetes-group-version-kind:
+    - group: example.com
+      version: v1
+      kind: App
+    properties:
+      spec:
+        properties:
+          containers:
+            type: array
+            x-kubernetes-list-type: map
+            x-kubernetes-list-map-keys: [name]
+            items:
+              type: object
+`
+	app := func(containers string) string {
+		return `apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  containers:
+` + containers
+	}
+	g := &testutil.TestSetupManager{
+		T: t,
+		ReposChanges: map[string][]testutil.Content{
+			testutil.Upstream: {
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithFile("app.yaml", app("  - name: web\n    image: web:1\n")).
+						WithFile("openapi.yaml", openAPI),
+					Branch: masterBranch,
+				},
+				{
+					Pkg: pkgbuilder.NewRootPkg().
+						WithFile("app.yaml", app("  - name: web\n    image: web:2\n")).
+						WithFile("openapi.yaml", openAPI),
+				},
+			},
+		},
+	}
+	g.LocalChanges = []testutil.Content{
+		{
+			Pkg: pkgbuilder.NewRootPkg().
+				WithFile("app.yaml", app("  - name: web\n    image: web:1\n  - name: sidecar\n    image: sidecar:1\n")).
+				WithFile("openapi.yaml", openAPI),
+			UpdateFunc: func(path string) error {
+				path = filepath.Join(path, g.LocalWorkspace.PackageDir)
+				kf, err := pkg.ReadKptfile(path)
+				if err != nil {
+					return err
+				}
+				kf.Upstream.OpenAPI = "openapi.yaml"
+				if err := kptfileutil.WriteFile(path, kf); err != nil {
+					return err
+				}
+				cmd := exec.Command("git", "add", ".")
+				cmd.Dir = path
+				return cmd.Run()
+			},
+		},
+	}
+	defer g.Clean()
+	if !g.Init() {
+		t.FailNow()
+	}
+	localPath := g.LocalWorkspace.FullPackagePath()
+
+	err := Command{
+		Pkg:      pkgtest.CreatePkgOrFail(t, localPath),
+		Ref:      masterBranch,
+		Strategy: kptfilev1.ResourceMerge,
+	}.Run(fake.CtxWithDefaultPrinter())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(localPath, "app.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "  - name: web\n    image: web:2\n  - name: sidecar\n    image: sidecar:1\n")
+}
@@ -23,12 +23,18 @@ import (
 // Result contains the structured result from an individual function
 type Result struct {
 	// Image is the full name of the image that generates this result
-	// Image and Exec are mutually exclusive
+	// Image, Exec and Starlark are mutually exclusive
 	Image string `yaml:"image,omitempty"`
+	// RewrittenImage is the image that was run if Image was rewritten by the
+	// image rewrite rules of the function runtime config, e.g. to use a mirror.
+	RewrittenImage string `yaml:"rewrittenImage,omitempty"`
 	// ExecPath is the the absolute os-specific path to the executable file
 	// If user provides an executable file with commands, ExecPath should
 	// contain the entire input string.
 	ExecPath string `yaml:"exec,omitempty"`
+	// Starlark is the path of the Starlark script relative to the package,
+	// or "inline" if the script is specified in the Kptfile.
+	Starlark string `yaml:"starlark,omitempty"`
 	// TODO(droot): This is required for making structured results subpackage aware.
 	// Enable this once test harness supports filepath based assertions.
 	// Pkg is OS specific Absolute path to the package.
@@ -37,6 +43,9 @@ type Result struct {
 	Stderr string `yaml:"stderr,omitempty"`
 	// ExitCode is the exit code from running the function
 	ExitCode int `yaml:"exitCode"`
+	// Skipped is true if the function was not run, because its `when`
+	// condition didn't match.
+	Skipped bool `yaml:"skipped,omitempty"`
 	// Results is the list of results for the function
 	Results []framework.ResultItem `yaml:"results,omitempty"`
 }
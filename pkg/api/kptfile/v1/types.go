@@ -27,6 +27,10 @@ const (
 	KptFileGroup      = "kpt.dev"
 	KptFileVersion    = "v1"
 	KptFileAPIVersion = KptFileGroup + "/" + KptFileVersion
+
+	// PipelineFragmentKind is the kind of a file containing a pipeline
+	// fragment, which can be included in the pipeline of a Kptfile.
+	PipelineFragmentKind = "PipelineFragment"
 )
 
 // TypeMeta is the TypeMeta for KptFile instances.
@@ -62,6 +66,14 @@ type OriginType string
 const (
 	// GitOrigin specifies a package as having been cloned from a git repository.
 	GitOrigin OriginType = "git"
+
+	// OciOrigin specifies a package as having been pulled from an OCI
+	// registry.
+	OciOrigin OriginType = "oci"
+
+	// LocalOrigin specifies a package as having been copied from a local
+	// directory or archive.
+	LocalOrigin OriginType = "local"
 )
 
 // UpdateStrategyType defines the strategy for updating a package from upstream.
@@ -118,8 +130,31 @@ type Upstream struct {
 	// Git is the locator for a package stored on Git.
 	Git *Git `yaml:"git,omitempty"`
 
+	// Oci is the locator for a package stored in an OCI registry.
+	Oci *Oci `yaml:"oci,omitempty"`
+
+	// Local is the locator for a package stored on the local filesystem.
+	Local *Local `yaml:"local,omitempty"`
+
 	// UpdateStrategy declares how a package will be updated from upstream.
 	UpdateStrategy UpdateStrategyType `yaml:"updateStrategy,omitempty"`
+
+	// NoMerge is a list of glob patterns of non-KRM files which are not
+	// merged line by line when the package is updated with the
+	// resource-merge strategy. Such a file is kept if it was changed
+	// locally, and replaced with the upstream file otherwise. Patterns
+	// without a slash match the file name in any directory of the package.
+	// The patterns of the local Kptfile are used, after the changes to it
+	// from upstream have been merged in.
+	// e.g. ['*.md', 'scripts/setup.sh']
+	NoMerge []string `yaml:"noMerge,omitempty"`
+
+	// OpenAPI is the path of a file in the package, relative to the
+	// package, with OpenAPI definitions of custom resources. They are used,
+	// in addition to the CustomResourceDefinitions in the package, to merge
+	// the lists of custom resources when the package is updated with the
+	// resource-merge strategy.
+	OpenAPI string `yaml:"openAPI,omitempty"`
 }
 
 // Git is the user-specified locator for a package on Git.
@@ -143,6 +178,12 @@ type UpstreamLock struct {
 
 	// Git is the resolved locator for a package on Git.
 	Git *GitLock `yaml:"git,omitempty"`
+
+	// Oci is the resolved locator for a package in an OCI registry.
+	Oci *OciLock `yaml:"oci,omitempty"`
+
+	// Local is the resolved locator for a package on the local filesystem.
+	Local *LocalLock `yaml:"local,omitempty"`
 }
 
 // GitLock is the resolved locator for a package on Git.
@@ -164,6 +205,43 @@ type GitLock struct {
 	Commit string `yaml:"commit,omitempty"`
 }
 
+// Oci is the user-specified locator for a package in an OCI registry.
+type Oci struct {
+	// Image is the image of the package, including the tag.
+	// e.g. 'us-docker.pkg.dev/example/packages/mysql:v1'
+	Image string `yaml:"image,omitempty"`
+}
+
+// OciLock is the resolved locator for a package in an OCI registry.
+type OciLock struct {
+	// Image is the image of the package that was pulled, including the tag.
+	// e.g. 'us-docker.pkg.dev/example/packages/mysql:v1'
+	Image string `yaml:"image,omitempty"`
+
+	// Digest is the digest of the image manifest that was pulled.
+	// This is set by kpt for bookkeeping purposes.
+	Digest string `yaml:"digest,omitempty"`
+}
+
+// Local is the user-specified locator for a package on the local filesystem.
+type Local struct {
+	// Path is the path of the directory containing the package, or of a
+	// .tar.gz archive of the package. Relative paths are relative to the
+	// directory of the package.
+	// e.g. '../vendor/mysql-v1.tar.gz'
+	Path string `yaml:"path,omitempty"`
+}
+
+// LocalLock is the resolved locator for a package on the local filesystem.
+type LocalLock struct {
+	// Path is the path of the directory or archive that was copied.
+	Path string `yaml:"path,omitempty"`
+
+	// Hash is the hash of the content of the package that was copied.
+	// This is set by kpt for bookkeeping purposes.
+	Hash string `yaml:"hash,omitempty"`
+}
+
 // PackageInfo contains optional information about the package such as license, documentation, etc.
 // These fields are not consumed by any functionality in kpt and are simply passed through.
 // Note that like any other KRM resource, humans and automation can also use `metadata.labels` and
@@ -224,6 +302,11 @@ type Pipeline struct {
 	// Input of the second function is the output of the first function, and so on.
 	// Order of operation: mutators, validators
 
+	// Include lists pipeline fragments whose mutators and validators are
+	// run before the mutators and validators of this pipeline, in the order
+	// they are listed.
+	Include []PipelineInclude `yaml:"include,omitempty"`
+
 	// Mutators defines a list of of KRM functions that mutate resources.
 	Mutators []Function `yaml:"mutators,omitempty"`
 
@@ -232,6 +315,46 @@ type Pipeline struct {
 	Validators []Function `yaml:"validators,omitempty"`
 }
 
+// PipelineInclude refers to a pipeline fragment. Exactly one of `Path` and
+// `Git` must be specified.
+type PipelineInclude struct {
+	// `Path` is the slash-separated path of a local pipeline fragment file,
+	// relative to the directory of the file which includes it.
+	Path string `yaml:"path,omitempty"`
+
+	// `Git` refers to a pipeline fragment file in a git repository.
+	Git *GitInclude `yaml:"git,omitempty"`
+}
+
+// GitInclude is the location of a pipeline fragment file in a git repository.
+type GitInclude struct {
+	// Repo is the git repository of the fragment.
+	// e.g. 'https://github.com/example/policies.git'
+	Repo string `yaml:"repo,omitempty"`
+
+	// Path is the slash-separated path of the fragment file in the repository.
+	// e.g. 'security/validators.yaml'
+	Path string `yaml:"path,omitempty"`
+
+	// Ref can be a Git branch, tag, or a commit SHA-1. The default branch of
+	// the repository is used if it is empty.
+	Ref string `yaml:"ref,omitempty"`
+
+	// Commit is the SHA-1 the ref was resolved to when the fragment was last
+	// included. This is set by kpt for bookkeeping purposes.
+	Commit string `yaml:"commit,omitempty"`
+}
+
+// PipelineFragment is a reusable part of a pipeline, which can be included
+// in the pipelines of many packages.
+type PipelineFragment struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	// Pipeline contains the functions of the fragment, and may include
+	// other fragments.
+	Pipeline Pipeline `yaml:"pipeline,omitempty"`
+}
+
 // String returns the string representation of Pipeline struct
 // The string returned is the struct content in Go default format.
 func (p *Pipeline) String() string {
@@ -273,6 +396,82 @@ type Function struct {
 
 	// `ConfigMap` is a convenient way to specify a function config of kind ConfigMap.
 	ConfigMap map[string]string `yaml:"configMap,omitempty"`
+
+	// `ConfigSchemaPath` specifies a slash-delimited relative path to a file in the
+	// current directory containing the OpenAPI schema of the function config.
+	// The function config is validated against the schema before the function
+	// container is run. If it is not specified, the schema published in the
+	// `dev.kpt.fn.config-schema` label of the function image is used, if any.
+	ConfigSchemaPath string `yaml:"configSchemaPath,omitempty"`
+
+	// `Starlark` specifies a Starlark script which is run in-process instead of
+	// a function container. `Image` and `Starlark` are mutually exclusive.
+	Starlark *StarlarkScript `yaml:"starlark,omitempty"`
+
+	// `Limits` restricts the resources available to the function container.
+	Limits *FunctionLimits `yaml:"limits,omitempty"`
+
+	// `Env` is a list of environment variables exposed to the function container.
+	// An entry is either a `KEY=VALUE` pair, or only the name of an environment
+	// variable of the kpt process which is passed through to the container.
+	// Passing through variables must be allowed explicitly, e.g. with
+	// `kpt fn render --allow-env`.
+	Env []string `yaml:"env,omitempty"`
+
+	// `When` is a condition which must match for the function to run. If it
+	// doesn't match, the function is skipped.
+	When *Condition `yaml:"when,omitempty"`
+}
+
+// Condition decides whether a function is run. All of the specified
+// conditions must match.
+type Condition struct {
+	// `ResourceExists` matches if the input of the function contains a
+	// resource matching the selector, e.g. a resource of kind `Ingress`.
+	ResourceExists *ResourceSelector `yaml:"resourceExists,omitempty"`
+
+	// `PackageContext` matches if the package context has all of the given
+	// values, e.g. `name: frontend`. The keys are the keys of the package
+	// context ConfigMap added to the input of functions by render.
+	PackageContext map[string]string `yaml:"packageContext,omitempty"`
+}
+
+// ResourceSelector selects resources. All of the specified fields must match.
+type ResourceSelector struct {
+	APIVersion string            `yaml:"apiVersion,omitempty"`
+	Kind       string            `yaml:"kind,omitempty"`
+	Name       string            `yaml:"name,omitempty"`
+	Namespace  string            `yaml:"namespace,omitempty"`
+	Labels     map[string]string `yaml:"labels,omitempty"`
+}
+
+// FunctionLimits restricts the resources available to a function container.
+type FunctionLimits struct {
+	// `Memory` is the maximum amount of memory, e.g. `512m` or `1g`.
+	Memory string `yaml:"memory,omitempty"`
+
+	// `CPUs` is the number of CPUs the function may use, e.g. `0.5`.
+	CPUs string `yaml:"cpus,omitempty"`
+
+	// `PidsLimit` is the maximum number of processes in the container, or
+	// -1 for no limit.
+	PidsLimit int `yaml:"pidsLimit,omitempty"`
+
+	// `ReadOnlyRootFilesystem` mounts the root filesystem of the container
+	// as read-only. `/tmp` remains writable.
+	ReadOnlyRootFilesystem *bool `yaml:"readOnlyRootFilesystem,omitempty"`
+}
+
+// StarlarkScript specifies the program for a Starlark function. The program
+// transforms `ctx.resource_list` and exactly one of `Source` and `Path` must
+// be set.
+type StarlarkScript struct {
+	// `Source` is the inline Starlark program.
+	Source string `yaml:"source,omitempty"`
+
+	// `Path` is a slash-delimited relative path to a file in the current
+	// directory containing the Starlark program.
+	Path string `yaml:"path,omitempty"`
 }
 
 // Inventory encapsulates the parameters for the inventory resource applied to a cluster.
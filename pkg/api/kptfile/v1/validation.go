@@ -17,8 +17,10 @@ package v1
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/types"
@@ -31,10 +33,31 @@ func (kf *KptFile) Validate(pkgPath types.UniquePath) error {
 	if err := kf.Pipeline.validate(pkgPath); err != nil {
 		return fmt.Errorf("invalid pipeline: %w", err)
 	}
+	if err := kf.Upstream.Validate(); err != nil {
+		return fmt.Errorf("invalid upstream: %w", err)
+	}
 	// TODO: validate other fields
 	return nil
 }
 
+// Validate validates the fields of the Upstream which are used when the
+// package is updated. The noMerge patterns must be valid glob patterns.
+func (u *Upstream) Validate() error {
+	if u == nil {
+		return nil
+	}
+	for i, p := range u.NoMerge {
+		if _, err := path.Match(strings.TrimPrefix(p, "/"), ""); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("upstream.noMerge[%d]", i),
+				Value:  p,
+				Reason: "must be a valid glob pattern",
+			}
+		}
+	}
+	return nil
+}
+
 // validate will validate all fields in the Pipeline
 // 'mutators' and 'validators' share same schema and
 // they are valid if all functions in them are ALL valid.
@@ -42,6 +65,11 @@ func (p *Pipeline) validate(pkgPath types.UniquePath) error {
 	if p == nil {
 		return nil
 	}
+	for i := range p.Include {
+		if err := p.Include[i].validate(i); err != nil {
+			return err
+		}
+	}
 	for i := range p.Mutators {
 		f := p.Mutators[i]
 		err := f.validate("mutators", i, pkgPath)
@@ -59,9 +87,66 @@ func (p *Pipeline) validate(pkgPath types.UniquePath) error {
 	return nil
 }
 
+func (inc *PipelineInclude) validate(idx int) error {
+	if (inc.Path == "") == (inc.Git == nil) {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.include[%d]", idx),
+			Reason: "exactly one of `path` and `git` must be specified",
+		}
+	}
+	if inc.Path != "" && filepath.IsAbs(inc.Path) {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.include[%d].path", idx),
+			Value:  inc.Path,
+			Reason: "path must be relative",
+		}
+	}
+	if inc.Git != nil && (inc.Git.Repo == "" || inc.Git.Path == "") {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.include[%d].git", idx),
+			Reason: "`repo` and `path` must be specified",
+		}
+	}
+	return nil
+}
+
+// Validate validates the pipeline fragment. Functions in fragments must not
+// refer to files, since the files would be resolved relative to the packages
+// including the fragment.
+func (f *PipelineFragment) Validate() error {
+	if f.APIVersion != KptFileAPIVersion || f.Kind != PipelineFragmentKind {
+		return fmt.Errorf("expected %s %s", KptFileAPIVersion, PipelineFragmentKind)
+	}
+	chains := []struct {
+		fnType string
+		fns    []Function
+	}{
+		{"mutators", f.Pipeline.Mutators},
+		{"validators", f.Pipeline.Validators},
+	}
+	for _, c := range chains {
+		for i, fn := range c.fns {
+			if fn.ConfigPath != "" || fn.ConfigSchemaPath != "" ||
+				(fn.Starlark != nil && fn.Starlark.Path != "") {
+				return &ValidateError{
+					Field:  fmt.Sprintf("pipeline.%s[%d]", c.fnType, i),
+					Reason: "functions in pipeline fragments must not refer to files",
+				}
+			}
+		}
+	}
+	if err := f.Pipeline.validate(""); err != nil {
+		return fmt.Errorf("invalid pipeline: %w", err)
+	}
+	return nil
+}
+
 func (f *Function) validate(fnType string, idx int, pkgPath types.UniquePath) error {
-	err := ValidateFunctionImageURL(f.Image)
-	if err != nil {
+	if f.Starlark != nil {
+		if err := f.validateStarlark(fnType, idx, pkgPath); err != nil {
+			return err
+		}
+	} else if err := ValidateFunctionImageURL(f.Image); err != nil {
 		return &ValidateError{
 			Field:  fmt.Sprintf("pipeline.%s[%d].image", fnType, idx),
 			Value:  f.Image,
@@ -69,6 +154,32 @@ func (f *Function) validate(fnType string, idx int, pkgPath types.UniquePath) er
 		}
 	}
 
+	if f.Limits != nil {
+		if err := ValidateFunctionLimits(*f.Limits); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].limits", fnType, idx),
+				Reason: err.Error(),
+			}
+		}
+	}
+
+	for i, e := range f.Env {
+		if !validEnvVar(e) {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].env[%d]", fnType, idx, i),
+				Value:  e,
+				Reason: "must be a `KEY=VALUE` pair or the name of an environment variable",
+			}
+		}
+	}
+
+	if f.When != nil && f.When.ResourceExists == nil && len(f.When.PackageContext) == 0 {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.%s[%d].when", fnType, idx),
+			Reason: "must specify `resourceExists` or `packageContext`",
+		}
+	}
+
 	if len(f.ConfigMap) != 0 && f.ConfigPath != "" {
 		return &ValidateError{
 			Field:  fmt.Sprintf("pipeline.%s[%d]", fnType, idx),
@@ -76,6 +187,23 @@ func (f *Function) validate(fnType string, idx int, pkgPath types.UniquePath) er
 		}
 	}
 
+	if f.ConfigSchemaPath != "" {
+		if err := validateFnConfigPathSyntax(f.ConfigSchemaPath); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].configSchemaPath", fnType, idx),
+				Value:  f.ConfigSchemaPath,
+				Reason: err.Error(),
+			}
+		}
+		if _, err := os.Stat(filepath.Join(string(pkgPath), f.ConfigSchemaPath)); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].configSchemaPath", fnType, idx),
+				Value:  f.ConfigSchemaPath,
+				Reason: "function config schema must exist in the current package",
+			}
+		}
+	}
+
 	if f.ConfigPath != "" {
 		if err := validateFnConfigPathSyntax(f.ConfigPath); err != nil {
 			return &ValidateError{
@@ -95,6 +223,58 @@ func (f *Function) validate(fnType string, idx int, pkgPath types.UniquePath) er
 	return nil
 }
 
+// validEnvVar returns true if e is a `KEY=VALUE` pair or the name of an
+// environment variable.
+func validEnvVar(e string) bool {
+	key := strings.SplitN(e, "=", 2)[0]
+	return key != "" && !strings.ContainsAny(key, " \t\n")
+}
+
+// validateStarlark validates the Starlark script of a function.
+func (f *Function) validateStarlark(fnType string, idx int, pkgPath types.UniquePath) error {
+	if f.Image != "" {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.%s[%d]", fnType, idx),
+			Reason: "function must not specify both `image` and `starlark` at the same time",
+		}
+	}
+	if len(f.Env) != 0 {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.%s[%d].env", fnType, idx),
+			Reason: "environment variables are only supported for function containers",
+		}
+	}
+	if f.ConfigSchemaPath != "" {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.%s[%d].configSchemaPath", fnType, idx),
+			Reason: "function config schemas are only supported for function containers",
+		}
+	}
+	if (f.Starlark.Source == "") == (f.Starlark.Path == "") {
+		return &ValidateError{
+			Field:  fmt.Sprintf("pipeline.%s[%d].starlark", fnType, idx),
+			Reason: "exactly one of `source` and `path` must be specified",
+		}
+	}
+	if f.Starlark.Path != "" {
+		if err := validateFnConfigPathSyntax(f.Starlark.Path); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].starlark.path", fnType, idx),
+				Value:  f.Starlark.Path,
+				Reason: err.Error(),
+			}
+		}
+		if _, err := os.Stat(filepath.Join(string(pkgPath), f.Starlark.Path)); err != nil {
+			return &ValidateError{
+				Field:  fmt.Sprintf("pipeline.%s[%d].starlark.path", fnType, idx),
+				Value:  f.Starlark.Path,
+				Reason: "starlark script must exist in the current package",
+			}
+		}
+	}
+	return nil
+}
+
 // ValidateFunctionImageURL validates the function name.
 // According to Docker implementation
 // https://github.com/docker/distribution/blob/master/reference/reference.go. A valid
@@ -115,7 +295,8 @@ func ValidateFunctionImageURL(name string) error {
 	tagRegexp := `(?:[\w][\w.-]{0,127})`
 	shaRegexp := `(sha256:[a-zA-Z0-9]{64})`
 	versionRegexp := fmt.Sprintf(`(%s|%s)`, tagRegexp, shaRegexp)
-	r := fmt.Sprintf(`^(?:%s(?:(\:|@)%s)?)$`, nameRegexp, versionRegexp)
+	// the tag is kept when an image is pinned to a digest, e.g. name:tag@sha256:...
+	r := fmt.Sprintf(`^(?:%s(?:(\:|@)%s|\:%s@%s)?)$`, nameRegexp, versionRegexp, tagRegexp, shaRegexp)
 
 	matched, err := regexp.MatchString(r, name)
 	if err != nil {
@@ -127,6 +308,27 @@ func ValidateFunctionImageURL(name string) error {
 	return nil
 }
 
+// memoryLimitRegexp matches memory limits in the format of docker,
+// e.g. 512m or 1g.
+var memoryLimitRegexp = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// ValidateFunctionLimits validates the resource limits of a function.
+func ValidateFunctionLimits(l FunctionLimits) error {
+	if l.Memory != "" && !memoryLimitRegexp.MatchString(l.Memory) {
+		return fmt.Errorf("memory %q must be a number with an optional unit b, k, m or g", l.Memory)
+	}
+	if l.CPUs != "" {
+		cpus, err := strconv.ParseFloat(l.CPUs, 64)
+		if err != nil || cpus <= 0 {
+			return fmt.Errorf("cpus %q must be a positive number", l.CPUs)
+		}
+	}
+	if l.PidsLimit < -1 {
+		return fmt.Errorf("pidsLimit must be positive, or -1 for no limit")
+	}
+	return nil
+}
+
 // validateFnConfigPathSyntax validates syntactic correctness of given functionConfig path
 // and return an error if it's invalid.
 func validateFnConfigPathSyntax(p string) error {
@@ -142,6 +142,307 @@ func TestKptfileValidate(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "pipeline: limits",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Limits: &FunctionLimits{
+								Memory:    "512m",
+								CPUs:      "0.5",
+								PidsLimit: 100,
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: invalid memory limit",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Limits: &FunctionLimits{
+								Memory: "512Mi",
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: invalid cpus limit",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Limits: &FunctionLimits{
+								CPUs: "-1",
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: no pids limit",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Limits: &FunctionLimits{
+								PidsLimit: -1,
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: invalid pids limit",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Limits: &FunctionLimits{
+								PidsLimit: -2,
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: missing config schema",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:            "image",
+							ConfigSchemaPath: "missing-schema.yaml",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: config schema outside of package",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image:            "image",
+							ConfigSchemaPath: "../schema.yaml",
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: env",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Env:   []string{"FEATURE_FOO=true", "HOME"},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: env without key",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Env:   []string{"=true"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: include",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Include: []PipelineInclude{
+						{Path: "../policies/validators.yaml"},
+						{Git: &GitInclude{Repo: "https://github.com/example/policies", Path: "validators.yaml"}},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: include with path and git",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Include: []PipelineInclude{
+						{
+							Path: "validators.yaml",
+							Git:  &GitInclude{Repo: "https://github.com/example/policies", Path: "validators.yaml"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: git include without path",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Include: []PipelineInclude{
+						{Git: &GitInclude{Repo: "https://github.com/example/policies"}},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: when",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							When: &Condition{
+								ResourceExists: &ResourceSelector{Kind: "Ingress"},
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: empty when",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							When:  &Condition{},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: env with starlark",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkScript{
+								Source: "print(ctx.resource_list)",
+							},
+							Env: []string{"FOO=bar"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: inline starlark",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkScript{
+								Source: "print(ctx.resource_list)",
+							},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "pipeline: starlark with image",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Image: "image",
+							Starlark: &StarlarkScript{
+								Source: "print(ctx.resource_list)",
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: starlark with source and path",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkScript{
+								Source: "print(ctx.resource_list)",
+								Path:   "script.star",
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "pipeline: starlark path referring file in parent",
+			kptfile: KptFile{
+				Pipeline: &Pipeline{
+					Mutators: []Function{
+						{
+							Starlark: &StarlarkScript{
+								Path: "../script.star",
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "upstream: valid noMerge patterns",
+			kptfile: KptFile{
+				Upstream: &Upstream{
+					NoMerge: []string{"*.md", "/scripts/setup.sh"},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "upstream: invalid noMerge pattern",
+			kptfile: KptFile{
+				Upstream: &Upstream{
+					NoMerge: []string{"*.md", "[scripts"},
+				},
+			},
+			valid: false,
+		},
 	}
 
 	for _, c := range cases {
@@ -229,6 +530,14 @@ func TestValidateFunctionName(t *testing.T) {
 			"example.com/foo/generate-folders@sha256:3434a5299f8fcb2c2ade9975e56ca5a622427b9d5a9a971640765e830fb90a0e",
 			true,
 		},
+		{
+			"example.com/foo/generate-folders:v0.1@sha256:3434a5299f8fcb2c2ade9975e56ca5a622427b9d5a9a971640765e830fb90a0e",
+			true,
+		},
+		{
+			"example.com/foo/generate-folders@v0.1@sha256:3434a5299f8fcb2c2ade9975e56ca5a622427b9d5a9a971640765e830fb90a0e",
+			false,
+		},
 	}
 
 	for _, n := range inputs {
@@ -27,6 +27,8 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/types"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/local"
+	"github.com/GoogleContainerTools/kpt/internal/util/oci"
 	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/api/kptfile/v1"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 	"sigs.k8s.io/kustomize/kyaml/yaml/merge3"
@@ -223,6 +225,54 @@ func UpdateUpstreamLockFromGit(path string, spec *git.RepoSpec) error {
 	return nil
 }
 
+// UpdateUpstreamLockFromOci updates the upstreamLock of the package specified
+// by path by using the values from spec, including the digest of the image
+// that was pulled.
+func UpdateUpstreamLockFromOci(path string, spec *oci.ImageSpec) error {
+	const op errors.Op = "kptfileutil.UpdateUpstreamLockFromOci"
+	kf, err := pkg.ReadKptfile(path)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+
+	kf.UpstreamLock = &kptfilev1.UpstreamLock{
+		Type: kptfilev1.OciOrigin,
+		Oci: &kptfilev1.OciLock{
+			Image:  spec.Image,
+			Digest: spec.Digest,
+		},
+	}
+	err = WriteFile(path, kf)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+	return nil
+}
+
+// UpdateUpstreamLockFromLocal updates the upstreamLock of the package
+// specified by path by using the values from spec, including the hash of the
+// content that was copied.
+func UpdateUpstreamLockFromLocal(path string, spec *local.PackageSpec) error {
+	const op errors.Op = "kptfileutil.UpdateUpstreamLockFromLocal"
+	kf, err := pkg.ReadKptfile(path)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+
+	kf.UpstreamLock = &kptfilev1.UpstreamLock{
+		Type: kptfilev1.LocalOrigin,
+		Local: &kptfilev1.LocalLock{
+			Path: spec.Path,
+			Hash: spec.Hash,
+		},
+	}
+	err = WriteFile(path, kf)
+	if err != nil {
+		return errors.E(op, types.UniquePath(path), err)
+	}
+	return nil
+}
+
 func merge(localKf, updatedKf, originalKf *kptfilev1.KptFile) error {
 	localBytes, err := yaml.Marshal(localKf)
 	if err != nil {
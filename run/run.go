@@ -26,6 +26,8 @@ import (
 	kptcommands "github.com/GoogleContainerTools/kpt/commands"
 	"github.com/GoogleContainerTools/kpt/internal/cmdcomplete"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/overview"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/pkg"
 	"github.com/GoogleContainerTools/kpt/internal/printer"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
 	"github.com/spf13/cobra"
@@ -76,6 +78,8 @@ func GetMain(ctx context.Context) *cobra.Command {
 
 	// create context with associated printer
 	ctx = printer.WithContext(ctx, pr)
+	// pipeline fragments in git repos are read through the repo cache
+	ctx = pkg.WithGitFileReader(ctx, gitutil.ReadFile)
 
 	cmd.Flags().BoolVar(&installComp, "install-completion", false,
 		"Install shell completion")
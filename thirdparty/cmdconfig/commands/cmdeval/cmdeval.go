@@ -62,6 +62,7 @@ func GetEvalFnRunner(ctx context.Context, parent string) *EvalFnRunner {
 		&r.AsCurrentUser, "as-current-user", false, "use the uid and gid that kpt is running with to run the function in the container")
 	r.Command.Flags().StringVar(&r.ImagePullPolicy, "image-pull-policy", "always",
 		"pull image before running the container. It should be one of always, ifNotPresent and never.")
+	cmdutil.AddFunctionLimitsFlags(r.Command, &r.Limits)
 	cmdutil.FixDocs("kpt", parent, c)
 	return r
 }
@@ -87,6 +88,7 @@ type EvalFnRunner struct {
 	Env                  []string
 	AsCurrentUser        bool
 	IncludeMetaResources bool
+	Limits               kptfile.FunctionLimits
 	Ctx                  context.Context
 }
 
@@ -213,7 +215,6 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		return errors.Errorf("must specify --image or --exec")
 	}
 	if r.Image != "" {
-		r.Image = fnruntime.AddDefaultImagePathPrefix(r.Image)
 		err := cmdutil.DockerCmdAvailable()
 		if err != nil {
 			return err
@@ -222,6 +223,9 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 	if err := cmdutil.ValidateImagePullPolicyValue(r.ImagePullPolicy); err != nil {
 		return err
 	}
+	if err := kptfile.ValidateFunctionLimits(r.Limits); err != nil {
+		return err
+	}
 	if r.ResultsDir != "" {
 		err := os.MkdirAll(r.ResultsDir, 0755)
 		if err != nil {
@@ -244,6 +248,20 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		return fmt.Errorf("function arguments can only be specified without function config file")
 	}
 
+	// function runtime configs are looked up relative to the package, or
+	// the current directory if resources are read from stdin
+	configDir := args[0]
+	if configDir == "-" {
+		configDir = "."
+	}
+	runtimeConfigs, err := fnruntime.LoadRuntimeConfigs(configDir)
+	if err != nil {
+		return err
+	}
+	if r.Image != "" {
+		r.Image = runtimeConfigs.AddDefaultImagePathPrefix(r.Image)
+	}
+
 	fnConfig, err := r.getCLIFunctionConfig(dataItems)
 	if err != nil {
 		return err
@@ -302,6 +320,8 @@ func (r *EvalFnRunner) preRunE(c *cobra.Command, args []string) error {
 		FnConfigPath:         r.FnConfigPath,
 		IncludeMetaResources: r.IncludeMetaResources,
 		ImagePullPolicy:      cmdutil.StringToImagePullPolicy(r.ImagePullPolicy),
+		RuntimeConfigs:       runtimeConfigs,
+		Limits:               r.Limits,
 		// fn eval should remove all files when all resources
 		// are deleted.
 		ContinueOnEmptyResult: true,
@@ -94,6 +94,13 @@ type RunFns struct {
 	OriginalExec string
 
 	ImagePullPolicy fnruntime.ImagePullPolicy
+
+	// RuntimeConfigs restrict and rewrite the function images which are run.
+	// If not set, the configs are loaded for Path.
+	RuntimeConfigs fnruntime.RuntimeConfigs
+
+	// Limits override the default resource limits of function containers.
+	Limits kptfile.FunctionLimits
 }
 
 // Execute runs the command
@@ -258,6 +265,20 @@ func (r *RunFns) init() error {
 		r.functionFilterProvider = r.defaultFnFilterProvider
 	}
 
+	if r.RuntimeConfigs == nil {
+		// function runtime configs are looked up relative to the package, or
+		// the current directory if resources are read from stdin
+		configDir := r.Path
+		if configDir == "" {
+			configDir = "."
+		}
+		configs, err := fnruntime.LoadRuntimeConfigs(configDir)
+		if err != nil {
+			return err
+		}
+		r.RuntimeConfigs = configs
+	}
+
 	// fn config path should be absolute
 	if r.FnConfigPath != "" && !filepath.IsAbs(r.FnConfigPath) {
 		// if the FnConfigPath is relative, we should use the
@@ -313,6 +334,18 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 		// Pkg: string(r.uniquePath),
 	}
 	if spec.Container.Image != "" {
+		image := r.RuntimeConfigs.AddDefaultImagePathPrefix(spec.Container.Image)
+		if err := r.RuntimeConfigs.CheckImage(image); err != nil {
+			return nil, err
+		}
+		rewrittenImage := r.RuntimeConfigs.RewriteImage(image)
+		ctx := r.Ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := fnruntime.ValidateImageFnConfig(ctx, rewrittenImage, fnConfig); err != nil {
+			return nil, err
+		}
 		// TODO: Add a test for this behavior
 		uidgid, err := getUIDGID(r.AsCurrentUser, currentUser)
 		if err != nil {
@@ -320,11 +353,12 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 		}
 		c := &fnruntime.ContainerFn{
 			Path:            r.uniquePath,
-			Image:           spec.Container.Image,
+			Image:           rewrittenImage,
 			ImagePullPolicy: r.ImagePullPolicy,
 			UIDGID:          uidgid,
 			StorageMounts:   r.StorageMounts,
 			Env:             spec.Container.Env,
+			Limits:          r.RuntimeConfigs.FunctionLimits(nil, r.Limits),
 			FnResult:        fnResult,
 			Perm: fnruntime.ContainerFnPermission{
 				AllowNetwork: r.Network,
@@ -338,7 +372,10 @@ func (r *RunFns) defaultFnFilterProvider(spec runtimeutil.FunctionSpec, fnConfig
 			FunctionConfig: fnConfig,
 			DeferFailure:   spec.DeferFailure,
 		}
-		fnResult.Image = spec.Container.Image
+		fnResult.Image = image
+		if rewrittenImage != image {
+			fnResult.RewrittenImage = rewrittenImage
+		}
 	}
 
 	if spec.Exec.Path != "" {